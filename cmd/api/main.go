@@ -2,6 +2,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"os/signal"
@@ -12,6 +13,7 @@ import (
 	"cams.dev/video_upload_backend/internal/adapter/http/handler"
 	"cams.dev/video_upload_backend/internal/adapter/http/middleware"
 	"cams.dev/video_upload_backend/internal/adapter/repository"
+	"cams.dev/video_upload_backend/internal/domain/entity"
 	"cams.dev/video_upload_backend/internal/infrastructure/auth"
 	"cams.dev/video_upload_backend/internal/infrastructure/database"
 	"cams.dev/video_upload_backend/internal/infrastructure/storage"
@@ -19,6 +21,7 @@ import (
 	"cams.dev/video_upload_backend/internal/usecase"
 	"cams.dev/video_upload_backend/pkg/config"
 	applogger "cams.dev/video_upload_backend/pkg/logger"
+	"cams.dev/video_upload_backend/pkg/rtmp"
 )
 
 func main() {
@@ -59,7 +62,15 @@ func main() {
 	// Initialize repositories
 	videoRepo := repository.NewVideoRepository(db.DB())
 	segmentRepo := repository.NewSegmentRepository(db.DB())
+	renditionRepo := repository.NewRenditionRepository(db.DB())
+	captionRepo := repository.NewCaptionRepository(db.DB())
+	assetRepo := repository.NewAssetRepository(db.DB())
+	chapterRepo := repository.NewChapterRepository(db.DB())
+	qcRepo := repository.NewQCRepository(db.DB())
+	keyRepo := repository.NewKeyRepository(db.DB(), cfg.Auth.KeyEncryptionSecret)
+	liveRepo := repository.NewLiveStreamRepository(db.DB())
 	userRepo := repository.NewUserRepository(db.DB())
+	transactor := repository.NewTransactor(db.DB())
 
 	// Initialize storage
 	storageRepo := storage.NewS3Storage(
@@ -78,43 +89,262 @@ func main() {
 	}
 
 	jwtService := auth.NewJWTService(cfg.Auth.JWTSecret, jwtDuration)
+	urlSigner := auth.NewURLSigner(cfg.Auth.URLSigningSecret)
+
+	playbackURLMode, err := entity.ParsePlaybackURLMode(cfg.Storage.PlaybackURLMode)
+	if err != nil {
+		logger.Fatal("Invalid playback URL mode: " + err.Error())
+	}
+
+	playbackURLExpiry, err := time.ParseDuration(cfg.Storage.PlaybackURLExpiry)
+	if err != nil {
+		logger.Fatal("Invalid playback URL expiry duration: " + err.Error())
+	}
+
+	playbackTokenExpiry, err := time.ParseDuration(cfg.Auth.PlaybackTokenExpiry)
+	if err != nil {
+		logger.Fatal("Invalid playback token expiry duration: " + err.Error())
+	}
+
+	playbackTokenMaxExpiry, err := time.ParseDuration(cfg.Auth.PlaybackTokenMaxExpiry)
+	if err != nil {
+		logger.Fatal("Invalid playback token max expiry duration: " + err.Error())
+	}
 
 	// Initialize transcode service
 	transcodeRepo := transcode.NewFFmpegService(
 		cfg.Transcode.FFmpegPath,
 		cfg.Transcode.FFprobePath,
+		cfg.Transcode.AV1Encoder,
 	)
 
+	// Build the encoding ladder from the configured codecs
+	var codecs []entity.VideoCodec
+	for _, name := range cfg.Transcode.Codecs {
+		codec, err := entity.ParseVideoCodec(name)
+		if err != nil {
+			logger.Fatal("Invalid transcode codec: " + err.Error())
+		}
+		codecs = append(codecs, codec)
+	}
+
+	rateControl, err := entity.ParseRateControlMode(cfg.Transcode.RateControl)
+	if err != nil {
+		logger.Fatal("Invalid rate control mode: " + err.Error())
+	}
+
+	// Default watermark applied to uploads without their own
+	var watermark *entity.Watermark
+	if cfg.Transcode.WatermarkImage != "" {
+		watermark = &entity.Watermark{
+			ImageURL: cfg.Transcode.WatermarkImage,
+			Position: entity.WatermarkPosition(cfg.Transcode.WatermarkPosition),
+			Opacity:  cfg.Transcode.WatermarkOpacity,
+			Margin:   cfg.Transcode.WatermarkMargin,
+		}
+		if err := watermark.Validate(); err != nil {
+			logger.Fatal("Invalid default watermark: " + err.Error())
+		}
+	}
+
+	encryption, err := entity.ParseEncryptionMethod(cfg.Transcode.Encryption)
+	if err != nil {
+		logger.Fatal("Invalid HLS encryption method: " + err.Error())
+	}
+
 	// Initialize use cases
 	transcodeUseCase := usecase.NewTranscodeUseCase(
 		videoRepo,
 		segmentRepo,
+		renditionRepo,
+		captionRepo,
+		assetRepo,
+		chapterRepo,
+		qcRepo,
+		keyRepo,
 		storageRepo,
 		transcodeRepo,
+		usecase.TranscodeConfig{
+			Loudnorm: cfg.Transcode.LoudnormEnabled,
+			LoudnessTarget: entity.LoudnessTarget{
+				IntegratedLUFS: cfg.Transcode.LoudnormTargetI,
+				TruePeak:       cfg.Transcode.LoudnormTargetTP,
+				LoudnessRange:  cfg.Transcode.LoudnormTargetLRA,
+			},
+			HDRRendition: cfg.Transcode.HDRRendition,
+			Ladder: entity.DefaultLadder(
+				codecs,
+				rateControl,
+				cfg.Transcode.EncoderPreset,
+				cfg.Transcode.EncoderTune,
+			),
+			QualityMetrics: cfg.Transcode.QualityMetrics,
+			PerTitle:       cfg.Transcode.PerTitle,
+			Watermark:      watermark,
+			AudioAssets:    cfg.Transcode.AudioAssets,
+			WaveformMs:     cfg.Transcode.WaveformMs,
+			MP4Downloads:   cfg.Transcode.MP4Downloads,
+			SceneDetection: cfg.Transcode.SceneDetection,
+			SceneThreshold: cfg.Transcode.SceneThreshold,
+			MinChapter:     cfg.Transcode.MinChapter,
+			QC:             cfg.Transcode.QC,
+			QCThresholds: entity.QCThresholds{
+				MaxBlackPercent:   cfg.Transcode.QCMaxBlackPercent,
+				MaxFreezePercent:  cfg.Transcode.QCMaxFreezePercent,
+				MaxSilencePercent: cfg.Transcode.QCMaxSilencePercent,
+			},
+			PerceptualHash: cfg.Transcode.PerceptualHash,
+			Encryption:     encryption,
+			KeySealing:     cfg.Auth.KeyEncryptionSecret != "",
+		},
 	)
 
 	videoUseCase := usecase.NewVideoUseCase(
 		videoRepo,
 		segmentRepo,
+		renditionRepo,
+		assetRepo,
+		qcRepo,
+		storageRepo,
+		transcodeUseCase,
+	)
+
+	playlistUseCase := usecase.NewPlaylistUseCase(
+		videoRepo,
+		segmentRepo,
+		renditionRepo,
+		captionRepo,
+		chapterRepo,
 		storageRepo,
+		urlSigner,
+		usecase.PlaybackConfig{
+			URLMode:   playbackURLMode,
+			URLExpiry: playbackURLExpiry,
+		},
+	)
+
+	captionUseCase := usecase.NewCaptionUseCase(
+		videoRepo,
+		captionRepo,
+		storageRepo,
+	)
+
+	editUseCase := usecase.NewEditUseCase(
+		videoRepo,
+		storageRepo,
+		transcodeRepo,
 		transcodeUseCase,
 	)
 
+	assetUseCase := usecase.NewAssetUseCase(
+		videoRepo,
+		captionRepo,
+		assetRepo,
+		storageRepo,
+		transcodeUseCase,
+	)
+
+	chapterUseCase := usecase.NewChapterUseCase(
+		videoRepo,
+		chapterRepo,
+	)
+
+	duplicateUseCase := usecase.NewDuplicateUseCase(
+		videoRepo,
+		segmentRepo,
+		renditionRepo,
+		captionRepo,
+		assetRepo,
+		chapterRepo,
+		keyRepo,
+		qcRepo,
+		transactor,
+		transcodeUseCase,
+		cfg.Transcode.DuplicateMaxDistance,
+	)
+
+	keyUseCase := usecase.NewKeyUseCase(
+		videoRepo,
+		keyRepo,
+	)
+
+	playbackUseCase := usecase.NewPlaybackUseCase(
+		videoRepo,
+		liveRepo,
+		jwtService,
+		playbackTokenExpiry,
+		playbackTokenMaxExpiry,
+	)
+
+	if cfg.Live.PartDuration < 0 || cfg.Live.PartDuration > cfg.Live.SegmentDuration {
+		logger.Fatal("Invalid live part duration: must be between 0 and the live segment duration")
+	}
+
+	liveDVRWindow, err := time.ParseDuration(cfg.Live.DVRWindow)
+	if err != nil {
+		logger.Fatal("Invalid live DVR window duration: " + err.Error())
+	}
+
+	// Live renditions are H.264 with a bitrate cap, encoded for low latency
+	liveUseCase := usecase.NewLiveUseCase(
+		liveRepo,
+		videoRepo,
+		segmentRepo,
+		renditionRepo,
+		storageRepo,
+		transcodeRepo,
+		urlSigner,
+		usecase.PlaybackConfig{
+			URLMode:   playbackURLMode,
+			URLExpiry: playbackURLExpiry,
+		},
+		usecase.LiveConfig{
+			Ladder: entity.DefaultLadder(
+				[]entity.VideoCodec{entity.CodecH264},
+				entity.RateControlCappedCRF,
+				cfg.Live.EncoderPreset,
+				"zerolatency",
+			),
+			SegmentDuration: cfg.Live.SegmentDuration,
+			PartDuration:    cfg.Live.PartDuration,
+			WindowSize:      cfg.Live.PlaylistSegments,
+			DVRWindow:       liveDVRWindow,
+		},
+	)
+
 	userUseCase := usecase.NewUserUseCase(
 		userRepo,
 		jwtService,
 	)
 
 	// Initialize HTTP middleware
-	authMiddleware := middleware.NewAuthMiddleware(jwtService, logger)
+	authMiddleware := middleware.NewAuthMiddleware(jwtService, urlSigner, logger)
 
 	// Initialize HTTP handlers
 	videoHandler := handler.NewVideoHandler(videoUseCase)
+	playlistHandler := handler.NewPlaylistHandler(playlistUseCase)
+	captionHandler := handler.NewCaptionHandler(captionUseCase)
+	editHandler := handler.NewEditHandler(editUseCase)
+	assetHandler := handler.NewAssetHandler(assetUseCase)
+	chapterHandler := handler.NewChapterHandler(chapterUseCase)
+	duplicateHandler := handler.NewDuplicateHandler(duplicateUseCase)
+	keyHandler := handler.NewKeyHandler(keyUseCase)
+	playbackHandler := handler.NewPlaybackHandler(playbackUseCase)
+	liveHandler := handler.NewLiveHandler(liveUseCase)
 	userHandler := handler.NewUserHandler(userUseCase, logger)
 
 	// Initialize router
 	router := http.NewRouter(
 		videoHandler,
+		playlistHandler,
+		captionHandler,
+		editHandler,
+		assetHandler,
+		chapterHandler,
+		duplicateHandler,
+		keyHandler,
+		playbackHandler,
+		liveHandler,
 		userHandler,
 		authMiddleware,
 		logger,
@@ -123,6 +353,20 @@ func main() {
 	// Get fiber app
 	app := router.Setup()
 
+	// Streams left live by a crash have no session to finish them
+	if err := liveUseCase.RecoverStreams(context.Background()); err != nil {
+		logger.Error("Failed to recover live streams: " + err.Error())
+	}
+
+	// Start the RTMP ingest server for live streams
+	rtmpServer := rtmp.NewServer(cfg.Live.RTMPAddr, liveUseCase)
+	go func() {
+		logger.Info("RTMP ingest listening on " + cfg.Live.RTMPAddr)
+		if err := rtmpServer.ListenAndServe(); err != nil {
+			logger.Error("RTMP ingest stopped: " + err.Error())
+		}
+	}()
+
 	// Setup graceful shutdown
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
@@ -130,6 +374,7 @@ func main() {
 	go func() {
 		<-c
 		logger.Info("Shutting down server...")
+		_ = rtmpServer.Close()
 		_ = app.Shutdown()
 	}()
 
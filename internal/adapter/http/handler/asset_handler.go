@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+	"cams.dev/video_upload_backend/internal/usecase"
+)
+
+// AssetHandler handles HTTP requests related to downloadable assets
+type AssetHandler struct {
+	assetUseCase *usecase.AssetUseCase
+}
+
+// NewAssetHandler creates a new asset handler
+func NewAssetHandler(assetUseCase *usecase.AssetUseCase) *AssetHandler {
+	return &AssetHandler{
+		assetUseCase: assetUseCase,
+	}
+}
+
+// CreateBurnedSubtitles handles requests for an MP4 with a caption track burned in
+func (h *AssetHandler) CreateBurnedSubtitles(c *fiber.Ctx) error {
+	var input struct {
+		CaptionID  string `json:"caption_id"`
+		Resolution string `json:"resolution"`
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if input.CaptionID == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Caption ID is required")
+	}
+
+	userID, _ := c.Locals("userID").(string)
+
+	err := h.assetUseCase.CreateBurnedSubtitles(c.Context(), usecase.BurnedSubtitlesInput{
+		VideoID:    c.Params("id"),
+		UserID:     userID,
+		CaptionID:  input.CaptionID,
+		Resolution: entity.Resolution(input.Resolution),
+	})
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		case strings.Contains(err.Error(), "forbidden"):
+			return fiber.NewError(fiber.StatusForbidden, err.Error())
+		case strings.Contains(err.Error(), "invalid"):
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to burn in subtitles: "+err.Error())
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "Subtitle burn-in has begun. The MP4 will be listed in the video's assets.",
+	})
+}
+
+// ListAssets handles requests to list the downloadable assets of a video
+func (h *AssetHandler) ListAssets(c *fiber.Ctx) error {
+	videoID := c.Params("id")
+
+	assets, err := h.assetUseCase.ListAssets(c.Context(), videoID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to list assets: "+err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"assets": assets,
+	})
+}
+
+// DownloadMP4 handles requests for a presigned progressive MP4 download URL
+func (h *AssetHandler) DownloadMP4(c *fiber.Ctx) error {
+	videoID := c.Params("id")
+	resolution := entity.Resolution(c.Query("resolution"))
+
+	download, err := h.assetUseCase.DownloadMP4(c.Context(), videoID, resolution)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create download URL: "+err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(download)
+}
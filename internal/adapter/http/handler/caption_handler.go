@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"io"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"cams.dev/video_upload_backend/internal/usecase"
+)
+
+// maxCaptionSize is the largest caption file accepted for upload
+const maxCaptionSize = 5 << 20 // 5 MB
+
+// CaptionHandler handles HTTP requests related to caption tracks
+type CaptionHandler struct {
+	captionUseCase *usecase.CaptionUseCase
+}
+
+// NewCaptionHandler creates a new caption handler
+func NewCaptionHandler(captionUseCase *usecase.CaptionUseCase) *CaptionHandler {
+	return &CaptionHandler{
+		captionUseCase: captionUseCase,
+	}
+}
+
+// UploadCaption handles SRT or WebVTT caption upload requests
+func (h *CaptionHandler) UploadCaption(c *fiber.Ctx) error {
+	videoID := c.Params("id")
+
+	// Get file from request
+	file, err := c.FormFile("caption")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Failed to get caption file: "+err.Error())
+	}
+	if file.Size > maxCaptionSize {
+		return fiber.NewError(fiber.StatusRequestEntityTooLarge, "Caption file is too large")
+	}
+
+	// Open and read file
+	fileObj, err := file.Open()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to open caption file: "+err.Error())
+	}
+	defer fileObj.Close()
+
+	data, err := io.ReadAll(fileObj)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to read caption file: "+err.Error())
+	}
+
+	// Extract metadata from form
+	language := c.FormValue("language")
+	if language == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Caption language is required")
+	}
+
+	userID, _ := c.Locals("userID").(string)
+
+	input := usecase.CaptionUploadInput{
+		VideoID:   videoID,
+		UserID:    userID,
+		Language:  language,
+		Label:     c.FormValue("label"),
+		IsDefault: c.FormValue("default") == "true",
+		FileData:  data,
+	}
+
+	// Call use case
+	caption, err := h.captionUseCase.UploadCaption(c.Context(), input)
+	if err != nil {
+		// Check for specific errors
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		case strings.Contains(err.Error(), "forbidden"):
+			return fiber.NewError(fiber.StatusForbidden, err.Error())
+		case strings.Contains(err.Error(), "invalid"):
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to upload caption: "+err.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(caption)
+}
+
+// ListCaptions handles requests to list the caption tracks of a video
+func (h *CaptionHandler) ListCaptions(c *fiber.Ctx) error {
+	videoID := c.Params("id")
+
+	captions, err := h.captionUseCase.ListCaptions(c.Context(), videoID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to list captions: "+err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"captions": captions,
+	})
+}
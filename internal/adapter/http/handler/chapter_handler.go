@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"cams.dev/video_upload_backend/internal/usecase"
+)
+
+// ChapterHandler handles HTTP requests related to chapters
+type ChapterHandler struct {
+	chapterUseCase *usecase.ChapterUseCase
+}
+
+// NewChapterHandler creates a new chapter handler
+func NewChapterHandler(chapterUseCase *usecase.ChapterUseCase) *ChapterHandler {
+	return &ChapterHandler{
+		chapterUseCase: chapterUseCase,
+	}
+}
+
+// chapterRequest is the JSON body of chapter create and update requests
+type chapterRequest struct {
+	Title string   `json:"title"`
+	Start *float64 `json:"start"`
+	End   *float64 `json:"end"`
+}
+
+// parseChapterInput reads a chapter request into use case input
+func parseChapterInput(c *fiber.Ctx) (usecase.ChapterInput, error) {
+	var body chapterRequest
+	if err := c.BodyParser(&body); err != nil {
+		return usecase.ChapterInput{}, fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if body.Start == nil || body.End == nil {
+		return usecase.ChapterInput{}, fiber.NewError(fiber.StatusBadRequest, "Start and end times are required")
+	}
+
+	userID, _ := c.Locals("userID").(string)
+
+	return usecase.ChapterInput{
+		VideoID: c.Params("id"),
+		UserID:  userID,
+		Title:   body.Title,
+		Start:   *body.Start,
+		End:     *body.End,
+	}, nil
+}
+
+// CreateChapter handles requests to add a chapter to a video
+func (h *ChapterHandler) CreateChapter(c *fiber.Ctx) error {
+	input, err := parseChapterInput(c)
+	if err != nil {
+		return err
+	}
+
+	chapter, err := h.chapterUseCase.CreateChapter(c.Context(), input)
+	if err != nil {
+		return chapterError("Failed to create chapter", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(chapter)
+}
+
+// UpdateChapter handles requests to change a chapter
+func (h *ChapterHandler) UpdateChapter(c *fiber.Ctx) error {
+	input, err := parseChapterInput(c)
+	if err != nil {
+		return err
+	}
+
+	chapter, err := h.chapterUseCase.UpdateChapter(c.Context(), c.Params("chapterId"), input)
+	if err != nil {
+		return chapterError("Failed to update chapter", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(chapter)
+}
+
+// DeleteChapter handles requests to remove a chapter
+func (h *ChapterHandler) DeleteChapter(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userID").(string)
+
+	err := h.chapterUseCase.DeleteChapter(c.Context(), c.Params("id"), c.Params("chapterId"), userID)
+	if err != nil {
+		return chapterError("Failed to delete chapter", err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListChapters handles requests for the chapters of a video as JSON
+func (h *ChapterHandler) ListChapters(c *fiber.Ctx) error {
+	chapters, err := h.chapterUseCase.ListChapters(c.Context(), c.Params("id"))
+	if err != nil {
+		return chapterError("Failed to list chapters", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"chapters": chapters,
+	})
+}
+
+// GetChaptersWebVTT handles requests for the chapters of a video as a WebVTT track
+func (h *ChapterHandler) GetChaptersWebVTT(c *fiber.Ctx) error {
+	vtt, err := h.chapterUseCase.GetChaptersWebVTT(c.Context(), c.Params("id"))
+	if err != nil {
+		return chapterError("Failed to build chapters track", err)
+	}
+
+	c.Set(fiber.HeaderContentType, "text/vtt; charset=utf-8")
+	return c.Status(fiber.StatusOK).Send(vtt)
+}
+
+// chapterError maps chapter use case errors to HTTP errors
+func chapterError(message string, err error) error {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case strings.Contains(err.Error(), "forbidden"):
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	case strings.Contains(err.Error(), "invalid"):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return fiber.NewError(fiber.StatusInternalServerError, message+": "+err.Error())
+}
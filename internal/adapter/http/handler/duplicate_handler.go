@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"cams.dev/video_upload_backend/internal/usecase"
+)
+
+// DuplicateHandler handles HTTP requests related to duplicate uploads
+type DuplicateHandler struct {
+	duplicateUseCase *usecase.DuplicateUseCase
+}
+
+// NewDuplicateHandler creates a new duplicate handler
+func NewDuplicateHandler(duplicateUseCase *usecase.DuplicateUseCase) *DuplicateHandler {
+	return &DuplicateHandler{
+		duplicateUseCase: duplicateUseCase,
+	}
+}
+
+// LinkDuplicate handles requests to reuse the renditions of an earlier upload of the same file
+func (h *DuplicateHandler) LinkDuplicate(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userID").(string)
+
+	video, err := h.duplicateUseCase.LinkDuplicate(c.Context(), c.Params("id"), userID)
+	if err != nil {
+		return duplicateError("Failed to link duplicate", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(video)
+}
+
+// ProcessDuplicate handles requests to transcode a duplicate upload anyway
+func (h *DuplicateHandler) ProcessDuplicate(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userID").(string)
+
+	video, err := h.duplicateUseCase.ProcessDuplicate(c.Context(), c.Params("id"), userID)
+	if err != nil {
+		return duplicateError("Failed to process duplicate", err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "Video processing started",
+		"videoId": video.ID,
+		"status":  video.Status,
+	})
+}
+
+// GetNearDuplicates handles requests for videos that look like copies of a video (admin only)
+func (h *DuplicateHandler) GetNearDuplicates(c *fiber.Ctx) error {
+	videoID := c.Params("id")
+
+	candidates, err := h.duplicateUseCase.NearDuplicates(c.Context(), videoID)
+	if err != nil {
+		return duplicateError("Failed to get near-duplicates", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"video_id":   videoID,
+		"duplicates": candidates,
+	})
+}
+
+// duplicateError maps duplicate use case errors to HTTP errors
+func duplicateError(message string, err error) error {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case strings.Contains(err.Error(), "forbidden"):
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	case strings.Contains(err.Error(), "invalid"):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return fiber.NewError(fiber.StatusInternalServerError, message+": "+err.Error())
+}
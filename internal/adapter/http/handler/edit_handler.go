@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"cams.dev/video_upload_backend/internal/usecase"
+)
+
+// EditHandler handles HTTP requests that create videos by editing existing ones
+type EditHandler struct {
+	editUseCase *usecase.EditUseCase
+}
+
+// NewEditHandler creates a new edit handler
+func NewEditHandler(editUseCase *usecase.EditUseCase) *EditHandler {
+	return &EditHandler{
+		editUseCase: editUseCase,
+	}
+}
+
+// CreateClip handles requests to cut a new video out of a time range of a video
+func (h *EditHandler) CreateClip(c *fiber.Ctx) error {
+	var input struct {
+		Title       string   `json:"title"`
+		Description string   `json:"description"`
+		Start       *float64 `json:"start"`
+		End         *float64 `json:"end"`
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if input.Start == nil || input.End == nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Start and end times are required")
+	}
+
+	userID, _ := c.Locals("userID").(string)
+
+	video, err := h.editUseCase.CreateClip(c.Context(), usecase.ClipInput{
+		SourceVideoID: c.Params("id"),
+		UserID:        userID,
+		Title:         input.Title,
+		Description:   input.Description,
+		Start:         *input.Start,
+		End:           *input.End,
+	})
+	if err != nil {
+		return editError("Failed to create clip", err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "Clip created. Processing has begun.",
+		"videoId": video.ID,
+		"status":  video.Status,
+	})
+}
+
+// ConcatVideos handles requests to join videos into a new video
+func (h *EditHandler) ConcatVideos(c *fiber.Ctx) error {
+	var input struct {
+		VideoIDs    []string `json:"video_ids"`
+		Title       string   `json:"title"`
+		Description string   `json:"description"`
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	userID, _ := c.Locals("userID").(string)
+
+	video, err := h.editUseCase.ConcatVideos(c.Context(), usecase.ConcatInput{
+		VideoIDs:    input.VideoIDs,
+		UserID:      userID,
+		Title:       input.Title,
+		Description: input.Description,
+	})
+	if err != nil {
+		return editError("Failed to join videos", err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "Videos joined. Processing has begun.",
+		"videoId": video.ID,
+		"status":  video.Status,
+	})
+}
+
+// editError maps edit use case errors to HTTP errors
+func editError(message string, err error) error {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case strings.Contains(err.Error(), "forbidden"):
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	case strings.Contains(err.Error(), "invalid"):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return fiber.NewError(fiber.StatusInternalServerError, message+": "+err.Error())
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"cams.dev/video_upload_backend/internal/usecase"
+)
+
+// KeyHandler handles HTTP requests for HLS content keys
+type KeyHandler struct {
+	keyUseCase *usecase.KeyUseCase
+}
+
+// NewKeyHandler creates a new key handler
+func NewKeyHandler(keyUseCase *usecase.KeyUseCase) *KeyHandler {
+	return &KeyHandler{
+		keyUseCase: keyUseCase,
+	}
+}
+
+// GetKey handles requests for the content key of an encrypted video, as
+// referenced by the EXT-X-KEY tags of its playlists
+func (h *KeyHandler) GetKey(c *fiber.Ctx) error {
+	videoID := c.Params("videoId")
+	userID, scoped := playbackCaller(c, videoID)
+
+	key, err := h.keyUseCase.GetKey(c.Context(), videoID, userID, scoped)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		case strings.Contains(err.Error(), "forbidden"):
+			return fiber.NewError(fiber.StatusForbidden, err.Error())
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to get key: "+err.Error())
+	}
+
+	// Keys must never be cached by shared caches
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	c.Set(fiber.HeaderContentType, "application/octet-stream")
+	return c.Send(key)
+}
+
+// playbackCaller returns the JWT user of a playback request and whether a
+// signed URL or playback token scoped to videoID authorized it
+func playbackCaller(c *fiber.Ctx, videoID string) (string, bool) {
+	userID, _ := c.Locals("userID").(string)
+	scope, _ := c.Locals("playbackVideoID").(string)
+	return userID, scope != "" && scope == videoID
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+	"cams.dev/video_upload_backend/internal/usecase"
+)
+
+// LiveHandler handles HTTP requests for live streams
+type LiveHandler struct {
+	liveUseCase *usecase.LiveUseCase
+}
+
+// NewLiveHandler creates a new live handler
+func NewLiveHandler(liveUseCase *usecase.LiveUseCase) *LiveHandler {
+	return &LiveHandler{
+		liveUseCase: liveUseCase,
+	}
+}
+
+// liveStreamRequest is the JSON body of live stream creation requests
+type liveStreamRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// CreateLiveStream handles requests to create a live stream. The response
+// holds the stream key to configure in the encoder.
+func (h *LiveHandler) CreateLiveStream(c *fiber.Ctx) error {
+	var body liveStreamRequest
+	if err := c.BodyParser(&body); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	userID, _ := c.Locals("userID").(string)
+
+	stream, err := h.liveUseCase.CreateLiveStream(c.Context(), usecase.LiveStreamInput{
+		UserID:      userID,
+		Title:       body.Title,
+		Description: body.Description,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid") {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create live stream: "+err.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(stream)
+}
+
+// ListLiveStreams handles requests for the live streams of the current user
+func (h *LiveHandler) ListLiveStreams(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userID").(string)
+
+	streams, err := h.liveUseCase.ListLiveStreams(c.Context(), userID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve live streams: "+err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(streams)
+}
+
+// GetLiveStream handles requests for a single live stream
+func (h *LiveHandler) GetLiveStream(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userID").(string)
+
+	stream, err := h.liveUseCase.GetLiveStream(c.Context(), c.Params("id"), userID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "Failed to retrieve live stream: "+err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(stream)
+}
+
+// GetLiveMasterPlaylist handles requests for the master playlist of a live stream
+func (h *LiveHandler) GetLiveMasterPlaylist(c *fiber.Ctx) error {
+	playlist, err := h.liveUseCase.GetLiveMasterPlaylist(c.Context(), c.Params("id"), c.Query("token"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "Failed to build playlist: "+err.Error())
+	}
+
+	return sendLivePlaylist(c, playlist)
+}
+
+// GetLiveMediaPlaylist handles requests for the sliding-window playlist of a
+// live rendition, including LL-HLS blocking reloads with _HLS_msn and _HLS_part
+func (h *LiveHandler) GetLiveMediaPlaylist(c *fiber.Ctx) error {
+	rendition := entity.Resolution(c.Params("rendition"))
+
+	var reload *usecase.LiveReload
+	if msn := c.Query("_HLS_msn"); msn != "" {
+		reload = &usecase.LiveReload{Part: -1}
+		var err error
+		if reload.MSN, err = strconv.Atoi(msn); err != nil || reload.MSN < 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid _HLS_msn")
+		}
+		if part := c.Query("_HLS_part"); part != "" {
+			if reload.Part, err = strconv.Atoi(part); err != nil || reload.Part < 0 {
+				return fiber.NewError(fiber.StatusBadRequest, "Invalid _HLS_part")
+			}
+		}
+	} else if c.Query("_HLS_part") != "" {
+		return fiber.NewError(fiber.StatusBadRequest, "_HLS_part requires _HLS_msn")
+	}
+
+	playlist, err := h.liveUseCase.GetLiveMediaPlaylist(c.Context(), c.Params("id"), rendition, reload, c.Query("token"))
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "invalid"):
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		case strings.Contains(err.Error(), "timed out"):
+			return fiber.NewError(fiber.StatusServiceUnavailable, err.Error())
+		}
+		return fiber.NewError(fiber.StatusNotFound, "Failed to build playlist: "+err.Error())
+	}
+
+	return sendLivePlaylist(c, playlist)
+}
+
+// GetLiveSegment handles requests for a segment or low-latency part of a live rendition
+func (h *LiveHandler) GetLiveSegment(c *fiber.Ctx) error {
+	rendition := entity.Resolution(c.Params("rendition"))
+
+	object, err := h.liveUseCase.GetLiveSegment(c.Context(), c.Params("id"), rendition, c.Params("segment"), c.Get(fiber.HeaderRange), c.Get(fiber.HeaderIfNoneMatch))
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			return fiber.NewError(fiber.StatusNotFound, "Failed to get segment: "+err.Error())
+		case strings.Contains(err.Error(), "invalid range"):
+			return fiber.NewError(fiber.StatusRequestedRangeNotSatisfiable, err.Error())
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to get segment: "+err.Error())
+	}
+
+	return sendStoredObject(c, object)
+}
+
+// sendLivePlaylist sends a live playlist, which changes with every segment and must not be cached
+func sendLivePlaylist(c *fiber.Ctx, playlist string) error {
+	c.Set(fiber.HeaderContentType, hlsContentType)
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	return c.Status(fiber.StatusOK).SendString(playlist)
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+	"cams.dev/video_upload_backend/internal/usecase"
+)
+
+// PlaybackHandler handles HTTP requests for playback tokens
+type PlaybackHandler struct {
+	playbackUseCase *usecase.PlaybackUseCase
+}
+
+// NewPlaybackHandler creates a new playback handler
+func NewPlaybackHandler(playbackUseCase *usecase.PlaybackUseCase) *PlaybackHandler {
+	return &PlaybackHandler{
+		playbackUseCase: playbackUseCase,
+	}
+}
+
+// playbackTokenRequest is the JSON body of playback token requests
+type playbackTokenRequest struct {
+	ExpiresIn       int    `json:"expires_in"`
+	AllowedReferrer string `json:"allowed_referrer"`
+	AllowedIP       string `json:"allowed_ip"`
+}
+
+// CreatePlaybackToken handles requests to mint a playback token for a video.
+// All fields of the body are optional.
+func (h *PlaybackHandler) CreatePlaybackToken(c *fiber.Ctx) error {
+	return h.createToken(c, h.playbackUseCase.CreatePlaybackToken)
+}
+
+// CreateLivePlaybackToken handles requests to mint a playback token for a
+// live stream. All fields of the body are optional.
+func (h *PlaybackHandler) CreateLivePlaybackToken(c *fiber.Ctx) error {
+	return h.createToken(c, h.playbackUseCase.CreateLivePlaybackToken)
+}
+
+// createToken mints a playback token for the video or live stream in the path
+func (h *PlaybackHandler) createToken(
+	c *fiber.Ctx,
+	create func(ctx context.Context, input usecase.PlaybackTokenInput) (*entity.PlaybackToken, error),
+) error {
+	var body playbackTokenRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+	}
+
+	userID, _ := c.Locals("userID").(string)
+
+	playbackToken, err := create(c.Context(), usecase.PlaybackTokenInput{
+		VideoID:         c.Params("id"),
+		UserID:          userID,
+		ExpiresIn:       time.Duration(body.ExpiresIn) * time.Second,
+		AllowedReferrer: body.AllowedReferrer,
+		AllowedIP:       body.AllowedIP,
+	})
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		case strings.Contains(err.Error(), "forbidden"):
+			return fiber.NewError(fiber.StatusForbidden, err.Error())
+		case strings.Contains(err.Error(), "invalid"):
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create playback token: "+err.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(playbackToken)
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+	"cams.dev/video_upload_backend/internal/usecase"
+)
+
+// hlsContentType is the MIME type of HLS playlists
+const hlsContentType = "application/vnd.apple.mpegurl"
+
+// PlaylistHandler handles HTTP requests for HLS playlists
+type PlaylistHandler struct {
+	playlistUseCase *usecase.PlaylistUseCase
+}
+
+// NewPlaylistHandler creates a new playlist handler
+func NewPlaylistHandler(playlistUseCase *usecase.PlaylistUseCase) *PlaylistHandler {
+	return &PlaylistHandler{
+		playlistUseCase: playlistUseCase,
+	}
+}
+
+// GetMasterPlaylist handles requests for a video's master playlist
+func (h *PlaylistHandler) GetMasterPlaylist(c *fiber.Ctx) error {
+	videoID := c.Params("id")
+
+	playlist, err := h.playlistUseCase.GetMasterPlaylist(c.Context(), videoID, c.Query("token"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "Failed to build playlist: "+err.Error())
+	}
+
+	c.Set(fiber.HeaderContentType, hlsContentType)
+	return c.Status(fiber.StatusOK).SendString(playlist)
+}
+
+// GetMediaPlaylist handles requests for a single rendition's media playlist
+func (h *PlaylistHandler) GetMediaPlaylist(c *fiber.Ctx) error {
+	videoID := c.Params("id")
+	rendition := entity.Resolution(c.Params("resolution"))
+
+	userID, scoped := playbackCaller(c, videoID)
+
+	playlist, err := h.playlistUseCase.GetMediaPlaylist(c.Context(), videoID, rendition, userID, scoped, c.Query("token"))
+	if err != nil {
+		if strings.Contains(err.Error(), "forbidden") {
+			return fiber.NewError(fiber.StatusForbidden, err.Error())
+		}
+		return fiber.NewError(fiber.StatusNotFound, "Failed to build playlist: "+err.Error())
+	}
+
+	c.Set(fiber.HeaderContentType, hlsContentType)
+	return c.Status(fiber.StatusOK).SendString(playlist)
+}
+
+// GetCaptionPlaylist handles requests for a caption track's subtitle playlist
+func (h *PlaylistHandler) GetCaptionPlaylist(c *fiber.Ctx) error {
+	videoID := c.Params("id")
+	captionID := c.Params("captionId")
+
+	playlist, err := h.playlistUseCase.GetCaptionPlaylist(c.Context(), videoID, captionID, c.Query("token"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "Failed to build playlist: "+err.Error())
+	}
+
+	c.Set(fiber.HeaderContentType, hlsContentType)
+	return c.Status(fiber.StatusOK).SendString(playlist)
+}
+
+// GetSegment handles requests for a segment, initialization segment or caption
+// file referenced by a playlist, streaming it from storage. Segments never
+// change once written, so responses may be cached for long, by CDNs for signed URLs.
+func (h *PlaylistHandler) GetSegment(c *fiber.Ctx) error {
+	videoID := c.Params("id")
+	dir := c.Params("resolution")
+	fileName := c.Params("segment")
+
+	object, err := h.playlistUseCase.GetSegment(c.Context(), videoID, dir, fileName, c.Get(fiber.HeaderRange), c.Get(fiber.HeaderIfNoneMatch))
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			return fiber.NewError(fiber.StatusNotFound, "Failed to get segment: "+err.Error())
+		case strings.Contains(err.Error(), "invalid range"):
+			return fiber.NewError(fiber.StatusRequestedRangeNotSatisfiable, err.Error())
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to get segment: "+err.Error())
+	}
+
+	return sendStoredObject(c, object)
+}
+
+// sendStoredObject streams an immutable stored object with caching and range headers
+func sendStoredObject(c *fiber.Ctx, object *entity.StoredObject) error {
+	c.Set(fiber.HeaderCacheControl, cacheControl(c))
+	c.Set(fiber.HeaderAcceptRanges, "bytes")
+	if object.ETag != "" {
+		c.Set(fiber.HeaderETag, object.ETag)
+	}
+	if !object.LastModified.IsZero() {
+		c.Set(fiber.HeaderLastModified, object.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	// Storage checked If-None-Match without sending the object
+	if object.NotModified {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	if etagMatches(c.Get(fiber.HeaderIfNoneMatch), object.ETag) {
+		_ = object.Body.Close()
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	if object.ContentType != "" {
+		c.Set(fiber.HeaderContentType, object.ContentType)
+	}
+	status := fiber.StatusOK
+	if object.ContentRange != "" {
+		c.Set(fiber.HeaderContentRange, object.ContentRange)
+		status = fiber.StatusPartialContent
+	}
+
+	return c.Status(status).SendStream(object.Body, int(object.ContentLength))
+}
+
+// cacheControl returns the Cache-Control header of a stored object. Signed URLs
+// carry their authorization in the URL, which shared caches key on, so a CDN may
+// serve them until they expire. Responses to JWT and playback token requests
+// must stay out of shared caches, which would serve them to anyone.
+func cacheControl(c *fiber.Ctx) string {
+	// PlaybackMiddleware rejects requests whose signature does not verify
+	if c.Query("signature") == "" {
+		return "private, max-age=31536000, immutable"
+	}
+
+	expiresAt, _ := strconv.ParseInt(c.Query("expires"), 10, 64)
+	maxAge := expiresAt - time.Now().Unix()
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	return fmt.Sprintf("public, max-age=%d, immutable", maxAge)
+}
+
+// etagMatches reports whether an If-None-Match header matches an ETag, using
+// the weak comparison required for If-None-Match
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -2,12 +2,19 @@ package handler
 
 import (
 	"fmt"
+	"io"
+	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 
+	"cams.dev/video_upload_backend/internal/domain/entity"
 	"cams.dev/video_upload_backend/internal/usecase"
 )
 
+// maxWatermarkSize is the largest watermark image accepted with an upload
+const maxWatermarkSize = 2 << 20 // 2 MB
+
 // VideoHandler handles HTTP requests related to videos
 type VideoHandler struct {
 	videoUseCase *usecase.VideoUseCase
@@ -62,12 +69,69 @@ func (h *VideoHandler) UploadVideo(c *fiber.Ctx) error {
 		UserID:      userID.(string),
 	}
 
+	// Optional segment encryption overriding the default one
+	if encryption := c.FormValue("encryption"); encryption != "" {
+		method, err := entity.ParseEncryptionMethod(encryption)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid encryption method")
+		}
+		input.Encryption = method
+	}
+
+	// Optional watermark overriding the default one
+	if watermarkFile, err := c.FormFile("watermark"); err == nil {
+		if watermarkFile.Size > maxWatermarkSize {
+			return fiber.NewError(fiber.StatusRequestEntityTooLarge, "Watermark image is too large")
+		}
+
+		watermarkObj, err := watermarkFile.Open()
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to open watermark image: "+err.Error())
+		}
+		defer watermarkObj.Close()
+
+		imageData, err := io.ReadAll(watermarkObj)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to read watermark image: "+err.Error())
+		}
+
+		opacity, err := strconv.ParseFloat(c.FormValue("watermark_opacity", "0.8"), 64)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid watermark opacity")
+		}
+		margin, err := strconv.Atoi(c.FormValue("watermark_margin", "24"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid watermark margin")
+		}
+
+		input.Watermark = &usecase.WatermarkInput{
+			ImageData: imageData,
+			FileName:  watermarkFile.Filename,
+			Position:  entity.WatermarkPosition(c.FormValue("watermark_position", string(entity.WatermarkBottomRight))),
+			Opacity:   opacity,
+			Margin:    margin,
+		}
+	}
+
 	// Call use case
 	video, err := h.videoUseCase.UploadVideo(c.Context(), input)
 	if err != nil {
+		if strings.Contains(err.Error(), "invalid") {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
 		return fiber.NewError(fiber.StatusInternalServerError, "Failed to process video: "+err.Error())
 	}
 
+	// Let the user choose between the existing renditions and processing anyway
+	if video.Status == entity.StatusDuplicate {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message":      "This file was uploaded before. Link it to the existing renditions or process it again.",
+			"videoId":      video.ID,
+			"status":       video.Status,
+			"duplicate_of": video.Metadata.DuplicateOf,
+		})
+	}
+
 	// Return video information
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "Video upload successful. Processing has begun.",
@@ -92,10 +156,59 @@ func (h *VideoHandler) GetVideo(c *fiber.Ctx) error {
 		fmt.Printf("Failed to get segments: %v\n", err)
 	}
 
+	// Get renditions
+	renditions, err := h.videoUseCase.GetVideoRenditions(c.Context(), videoID)
+	if err != nil {
+		// Just log error but continue
+		fmt.Printf("Failed to get renditions: %v\n", err)
+	}
+
+	// Get downloadable assets
+	assets, err := h.videoUseCase.GetVideoAssets(c.Context(), videoID)
+	if err != nil {
+		// Just log error but continue
+		fmt.Printf("Failed to get assets: %v\n", err)
+	}
+
 	// Return response
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"video":    video,
-		"segments": segments,
+		"video":      video,
+		"segments":   segments,
+		"renditions": renditions,
+		"assets":     assets,
+	})
+}
+
+// GetQCReport handles requests for the QC report of a video
+func (h *VideoHandler) GetQCReport(c *fiber.Ctx) error {
+	videoID := c.Params("id")
+
+	report, err := h.videoUseCase.GetQCReport(c.Context(), videoID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to get QC report: "+err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(report)
+}
+
+// GetQualityReport handles requests for the quality scores of a video's renditions (admin only)
+func (h *VideoHandler) GetQualityReport(c *fiber.Ctx) error {
+	videoID := c.Params("id")
+
+	renditions, err := h.videoUseCase.GetQualityReport(c.Context(), videoID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to get quality report: "+err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"video_id":   videoID,
+		"renditions": renditions,
 	})
 }
 
@@ -122,3 +235,56 @@ func (h *VideoHandler) GetVideosByUser(c *fiber.Ctx) error {
 		"videos": videos,
 	})
 }
+
+// videoUpdateRequest is the JSON body of video update requests. Omitted fields are left unchanged.
+type videoUpdateRequest struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+}
+
+// UpdateVideo handles requests to change the title or description of a video
+func (h *VideoHandler) UpdateVideo(c *fiber.Ctx) error {
+	var body videoUpdateRequest
+	if err := c.BodyParser(&body); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	userID, _ := c.Locals("userID").(string)
+
+	video, err := h.videoUseCase.UpdateVideo(c.Context(), usecase.VideoUpdateInput{
+		VideoID:     c.Params("id"),
+		UserID:      userID,
+		Title:       body.Title,
+		Description: body.Description,
+	})
+	if err != nil {
+		return videoError("Failed to update video", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(video)
+}
+
+// DeleteVideo handles requests to delete a video along with its stored files
+func (h *VideoHandler) DeleteVideo(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userID").(string)
+
+	if err := h.videoUseCase.DeleteVideo(c.Context(), c.Params("id"), userID); err != nil {
+		return videoError("Failed to delete video", err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// videoError maps video use case errors to HTTP errors
+func videoError(message string, err error) error {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case strings.Contains(err.Error(), "forbidden"):
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	case strings.Contains(err.Error(), "invalid"):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return fiber.NewError(fiber.StatusInternalServerError, message+": "+err.Error())
+}
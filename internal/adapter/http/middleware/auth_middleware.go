@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"net"
+	"net/url"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
@@ -13,13 +15,15 @@ import (
 // AuthMiddleware handles authentication
 type AuthMiddleware struct {
 	jwtService *auth.JWTService
+	urlSigner  *auth.URLSigner
 	logger     *logger.Logger
 }
 
 // NewAuthMiddleware creates a new auth middleware
-func NewAuthMiddleware(jwtService *auth.JWTService, logger *logger.Logger) *AuthMiddleware {
+func NewAuthMiddleware(jwtService *auth.JWTService, urlSigner *auth.URLSigner, logger *logger.Logger) *AuthMiddleware {
 	return &AuthMiddleware{
 		jwtService: jwtService,
+		urlSigner:  urlSigner,
 		logger:     logger,
 	}
 }
@@ -57,6 +61,82 @@ func (m *AuthMiddleware) FiberMiddleware(c *fiber.Ctx) error {
 	return c.Next()
 }
 
+// PlaybackMiddleware authenticates playback requests by a signed URL or a
+// playback token in the query string, falling back to the JWT for requests
+// with neither. The video a signed URL or token is scoped to is stored in the
+// playbackVideoID local.
+func (m *AuthMiddleware) PlaybackMiddleware(c *fiber.Ctx) error {
+	return m.playback(c, false)
+}
+
+// LivePlaybackMiddleware is PlaybackMiddleware for live routes, whose playback
+// tokens are scoped to the live stream in the path
+func (m *AuthMiddleware) LivePlaybackMiddleware(c *fiber.Ctx) error {
+	return m.playback(c, true)
+}
+
+// playback authenticates a playback request, live tells the kind of ID in the path
+func (m *AuthMiddleware) playback(c *fiber.Ctx, live bool) error {
+	// The signature covers the path, so it is scoped to the video in it
+	if signature := c.Query("signature"); signature != "" {
+		if err := m.urlSigner.Verify(c.Path(), c.Query("expires"), signature); err != nil {
+			return fiber.NewError(fiber.StatusForbidden, "Invalid signed URL: "+err.Error())
+		}
+		c.Locals("playbackVideoID", playbackVideoID(c))
+		return c.Next()
+	}
+
+	tokenString := c.Query("token")
+	if tokenString == "" {
+		return m.FiberMiddleware(c)
+	}
+
+	claims, err := m.jwtService.ValidatePlaybackToken(tokenString)
+	if err != nil {
+		m.logger.Error("Invalid playback token", logger.Error(err))
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid playback token")
+	}
+
+	// Tokens are scoped to a single video or live stream, a referring host and a client IP
+	videoID := playbackVideoID(c)
+	scope := claims.VideoID
+	if live {
+		scope = claims.LiveStreamID
+	}
+	if scope == "" || scope != videoID {
+		return fiber.NewError(fiber.StatusForbidden, "Playback token is not valid for this video")
+	}
+	if claims.Referrer != "" && referrerHost(c.Get(fiber.HeaderReferer)) != claims.Referrer {
+		return fiber.NewError(fiber.StatusForbidden, "Playback token is not valid for this referrer")
+	}
+	if claims.IP != "" && !net.ParseIP(claims.IP).Equal(net.ParseIP(c.IP())) {
+		return fiber.NewError(fiber.StatusForbidden, "Playback token is not valid for this IP")
+	}
+
+	// Let handlers tell a scoped request from a JWT one
+	c.Locals("playbackVideoID", videoID)
+
+	// Continue to the next handler
+	return c.Next()
+}
+
+// playbackVideoID returns the video a playback route refers to
+func playbackVideoID(c *fiber.Ctx) string {
+	if videoID := c.Params("id"); videoID != "" {
+		return videoID
+	}
+	return c.Params("videoId")
+}
+
+// referrerHost returns the lowercase host of a Referer header, empty if it has none
+func referrerHost(referrer string) string {
+	parsed, err := url.Parse(referrer)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Host)
+}
+
 // AdminMiddleware ensures the user has admin role
 func (m *AuthMiddleware) AdminMiddleware(c *fiber.Ctx) error {
 	// Check if user is admin
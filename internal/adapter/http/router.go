@@ -12,16 +12,34 @@ import (
 
 // Router sets up the HTTP routes
 type Router struct {
-	app            *fiber.App
-	videoHandler   *handler.VideoHandler
-	userHandler    *handler.UserHandler
-	authMiddleware *middleware.AuthMiddleware
-	logger         *logger.Logger
+	app              *fiber.App
+	videoHandler     *handler.VideoHandler
+	playlistHandler  *handler.PlaylistHandler
+	captionHandler   *handler.CaptionHandler
+	editHandler      *handler.EditHandler
+	assetHandler     *handler.AssetHandler
+	chapterHandler   *handler.ChapterHandler
+	duplicateHandler *handler.DuplicateHandler
+	keyHandler       *handler.KeyHandler
+	playbackHandler  *handler.PlaybackHandler
+	liveHandler      *handler.LiveHandler
+	userHandler      *handler.UserHandler
+	authMiddleware   *middleware.AuthMiddleware
+	logger           *logger.Logger
 }
 
 // NewRouter creates a new router
 func NewRouter(
 	videoHandler *handler.VideoHandler,
+	playlistHandler *handler.PlaylistHandler,
+	captionHandler *handler.CaptionHandler,
+	editHandler *handler.EditHandler,
+	assetHandler *handler.AssetHandler,
+	chapterHandler *handler.ChapterHandler,
+	duplicateHandler *handler.DuplicateHandler,
+	keyHandler *handler.KeyHandler,
+	playbackHandler *handler.PlaybackHandler,
+	liveHandler *handler.LiveHandler,
 	userHandler *handler.UserHandler,
 	authMiddleware *middleware.AuthMiddleware,
 	logger *logger.Logger,
@@ -32,11 +50,20 @@ func NewRouter(
 	})
 
 	return &Router{
-		app:            app,
-		videoHandler:   videoHandler,
-		userHandler:    userHandler,
-		authMiddleware: authMiddleware,
-		logger:         logger,
+		app:              app,
+		videoHandler:     videoHandler,
+		playlistHandler:  playlistHandler,
+		captionHandler:   captionHandler,
+		editHandler:      editHandler,
+		assetHandler:     assetHandler,
+		chapterHandler:   chapterHandler,
+		duplicateHandler: duplicateHandler,
+		keyHandler:       keyHandler,
+		playbackHandler:  playbackHandler,
+		liveHandler:      liveHandler,
+		userHandler:      userHandler,
+		authMiddleware:   authMiddleware,
+		logger:           logger,
 	}
 }
 
@@ -47,7 +74,7 @@ func (r *Router) Setup() *fiber.App {
 	r.app.Use(logger.FiberLogger(r.logger))
 	r.app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
-		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
+		AllowMethods: "GET,POST,PUT,PATCH,DELETE,OPTIONS",
 		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
 	}))
 
@@ -66,14 +93,60 @@ func (r *Router) Setup() *fiber.App {
 		r.authMiddleware.AdminMiddleware,
 	)
 
+	// Playback routes accept signed URLs or playback tokens in place of a JWT. They are registered
+	// before the video group so its authentication does not apply to them, and
+	// the segment route comes after the playlist routes it would otherwise match.
+	apiV1.Get("/videos/:id/master.m3u8", r.authMiddleware.PlaybackMiddleware, r.playlistHandler.GetMasterPlaylist)
+	apiV1.Get("/videos/:id/:resolution/playlist.m3u8", r.authMiddleware.PlaybackMiddleware, r.playlistHandler.GetMediaPlaylist)
+	apiV1.Get("/videos/:id/captions/:captionId/playlist.m3u8", r.authMiddleware.PlaybackMiddleware, r.playlistHandler.GetCaptionPlaylist)
+	apiV1.Get("/videos/:id/:resolution/:segment", r.authMiddleware.PlaybackMiddleware, r.playlistHandler.GetSegment)
+	apiV1.Get("/keys/:videoId", r.authMiddleware.PlaybackMiddleware, r.keyHandler.GetKey)
+	apiV1.Get("/live/:id/master.m3u8", r.authMiddleware.LivePlaybackMiddleware, r.liveHandler.GetLiveMasterPlaylist)
+	apiV1.Get("/live/:id/:rendition/playlist.m3u8", r.authMiddleware.LivePlaybackMiddleware, r.liveHandler.GetLiveMediaPlaylist)
+	apiV1.Get("/live/:id/:rendition/:segment", r.authMiddleware.LivePlaybackMiddleware, r.liveHandler.GetLiveSegment)
+
 	// Video routes (protected)
 	videoRoutes := apiV1.Group("/videos")
 	videoRoutes.Use(r.authMiddleware.FiberMiddleware)
 
 	videoRoutes.Post("/", r.videoHandler.UploadVideo)
+	videoRoutes.Post("/concat", r.editHandler.ConcatVideos)
 	videoRoutes.Get("/:id", r.videoHandler.GetVideo)
+	videoRoutes.Patch("/:id", r.videoHandler.UpdateVideo)
+	videoRoutes.Delete("/:id", r.videoHandler.DeleteVideo)
+	videoRoutes.Post("/:id/captions", r.captionHandler.UploadCaption)
+	videoRoutes.Get("/:id/captions", r.captionHandler.ListCaptions)
+	videoRoutes.Post("/:id/clips", r.editHandler.CreateClip)
+	videoRoutes.Get("/:id/qc", r.videoHandler.GetQCReport)
+	videoRoutes.Get("/:id/assets", r.assetHandler.ListAssets)
+	videoRoutes.Get("/:id/download", r.assetHandler.DownloadMP4)
+	videoRoutes.Post("/:id/assets/burned-subtitles", r.assetHandler.CreateBurnedSubtitles)
+	videoRoutes.Get("/:id/chapters", r.chapterHandler.ListChapters)
+	videoRoutes.Get("/:id/chapters.vtt", r.chapterHandler.GetChaptersWebVTT)
+	videoRoutes.Post("/:id/chapters", r.chapterHandler.CreateChapter)
+	videoRoutes.Put("/:id/chapters/:chapterId", r.chapterHandler.UpdateChapter)
+	videoRoutes.Delete("/:id/chapters/:chapterId", r.chapterHandler.DeleteChapter)
+	videoRoutes.Post("/:id/link", r.duplicateHandler.LinkDuplicate)
+	videoRoutes.Post("/:id/process", r.duplicateHandler.ProcessDuplicate)
+	videoRoutes.Post("/:id/playback-tokens", r.playbackHandler.CreatePlaybackToken)
 	apiV1.Get("/users/videos", r.videoHandler.GetVideosByUser)
 
+	// Live stream routes (protected)
+	liveRoutes := apiV1.Group("/live")
+	liveRoutes.Use(r.authMiddleware.FiberMiddleware)
+
+	liveRoutes.Post("/", r.liveHandler.CreateLiveStream)
+	liveRoutes.Get("/", r.liveHandler.ListLiveStreams)
+	liveRoutes.Get("/:id", r.liveHandler.GetLiveStream)
+	liveRoutes.Post("/:id/playback-tokens", r.playbackHandler.CreateLivePlaybackToken)
+
+	// Admin routes
+	adminRoutes := apiV1.Group("/admin")
+	adminRoutes.Use(r.authMiddleware.FiberMiddleware, r.authMiddleware.AdminMiddleware)
+
+	adminRoutes.Get("/videos/:id/quality", r.videoHandler.GetQualityReport)
+	adminRoutes.Get("/videos/:id/duplicates", r.duplicateHandler.GetNearDuplicates)
+
 	return r.app
 }
 
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+)
+
+// AssetRepository implements domain.repository.AssetRepository
+type AssetRepository struct {
+	db *sql.DB
+}
+
+// NewAssetRepository creates a new asset repository
+func NewAssetRepository(db *sql.DB) *AssetRepository {
+	return &AssetRepository{
+		db: db,
+	}
+}
+
+// Create inserts a new asset record
+func (r *AssetRepository) Create(ctx context.Context, asset *entity.Asset) error {
+	query := `
+		INSERT INTO assets (
+			id, video_id, type, name, language, url, mime_type, file_size, created_at, updated_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+		)
+	`
+
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		query,
+		asset.ID,
+		asset.VideoID,
+		string(asset.Type),
+		asset.Name,
+		asset.Language,
+		asset.URL,
+		asset.MimeType,
+		asset.FileSize,
+		asset.CreatedAt,
+		asset.UpdatedAt,
+	)
+
+	return err
+}
+
+// GetByVideoID retrieves assets for a video
+func (r *AssetRepository) GetByVideoID(ctx context.Context, videoID string) ([]*entity.Asset, error) {
+	query := `
+		SELECT
+			id, video_id, type, name, language, url, mime_type, file_size, created_at, updated_at
+		FROM assets
+		WHERE video_id = $1
+		ORDER BY type ASC, name ASC, language ASC
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, videoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assets []*entity.Asset
+
+	for rows.Next() {
+		var asset entity.Asset
+		var assetType string
+
+		err := rows.Scan(
+			&asset.ID,
+			&asset.VideoID,
+			&assetType,
+			&asset.Name,
+			&asset.Language,
+			&asset.URL,
+			&asset.MimeType,
+			&asset.FileSize,
+			&asset.CreatedAt,
+			&asset.UpdatedAt,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		asset.Type = entity.AssetType(assetType)
+		assets = append(assets, &asset)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return assets, nil
+}
+
+// Update updates an asset record
+func (r *AssetRepository) Update(ctx context.Context, asset *entity.Asset) error {
+	query := `
+		UPDATE assets
+		SET
+			url = $1,
+			mime_type = $2,
+			file_size = $3,
+			updated_at = $4
+		WHERE id = $5
+	`
+
+	asset.UpdatedAt = time.Now()
+
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		query,
+		asset.URL,
+		asset.MimeType,
+		asset.FileSize,
+		asset.UpdatedAt,
+		asset.ID,
+	)
+
+	return err
+}
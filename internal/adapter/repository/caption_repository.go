@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+)
+
+// CaptionRepository implements domain.repository.CaptionRepository
+type CaptionRepository struct {
+	db *sql.DB
+}
+
+// NewCaptionRepository creates a new caption repository
+func NewCaptionRepository(db *sql.DB) *CaptionRepository {
+	return &CaptionRepository{
+		db: db,
+	}
+}
+
+// Create inserts a new caption record
+func (r *CaptionRepository) Create(ctx context.Context, caption *entity.Caption) error {
+	query := `
+		INSERT INTO captions (
+			id, video_id, language, label, source, url, is_default, created_at, updated_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9
+		)
+	`
+
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		query,
+		caption.ID,
+		caption.VideoID,
+		caption.Language,
+		caption.Label,
+		string(caption.Source),
+		caption.URL,
+		caption.IsDefault,
+		caption.CreatedAt,
+		caption.UpdatedAt,
+	)
+
+	return err
+}
+
+// GetByID retrieves a caption by ID
+func (r *CaptionRepository) GetByID(ctx context.Context, id string) (*entity.Caption, error) {
+	query := `
+		SELECT
+			id, video_id, language, label, source, url, is_default, created_at, updated_at
+		FROM captions
+		WHERE id = $1
+	`
+
+	row := conn(ctx, r.db).QueryRowContext(ctx, query, id)
+
+	var caption entity.Caption
+	var source string
+
+	err := row.Scan(
+		&caption.ID,
+		&caption.VideoID,
+		&caption.Language,
+		&caption.Label,
+		&source,
+		&caption.URL,
+		&caption.IsDefault,
+		&caption.CreatedAt,
+		&caption.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("caption with ID %s not found", id)
+		}
+		return nil, err
+	}
+
+	caption.Source = entity.CaptionSource(source)
+
+	return &caption, nil
+}
+
+// GetByVideoID retrieves captions for a video
+func (r *CaptionRepository) GetByVideoID(ctx context.Context, videoID string) ([]*entity.Caption, error) {
+	query := `
+		SELECT
+			id, video_id, language, label, source, url, is_default, created_at, updated_at
+		FROM captions
+		WHERE video_id = $1
+		ORDER BY created_at ASC
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, videoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var captions []*entity.Caption
+
+	for rows.Next() {
+		var caption entity.Caption
+		var source string
+
+		err := rows.Scan(
+			&caption.ID,
+			&caption.VideoID,
+			&caption.Language,
+			&caption.Label,
+			&source,
+			&caption.URL,
+			&caption.IsDefault,
+			&caption.CreatedAt,
+			&caption.UpdatedAt,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		caption.Source = entity.CaptionSource(source)
+		captions = append(captions, &caption)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return captions, nil
+}
+
+// Update updates a caption record
+func (r *CaptionRepository) Update(ctx context.Context, caption *entity.Caption) error {
+	query := `
+		UPDATE captions
+		SET
+			language = $1,
+			label = $2,
+			source = $3,
+			url = $4,
+			is_default = $5,
+			updated_at = $6
+		WHERE id = $7
+	`
+
+	caption.UpdatedAt = time.Now()
+
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		query,
+		caption.Language,
+		caption.Label,
+		string(caption.Source),
+		caption.URL,
+		caption.IsDefault,
+		caption.UpdatedAt,
+		caption.ID,
+	)
+
+	return err
+}
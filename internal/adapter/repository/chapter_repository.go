@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+)
+
+// ChapterRepository implements domain.repository.ChapterRepository
+type ChapterRepository struct {
+	db *sql.DB
+}
+
+// NewChapterRepository creates a new chapter repository
+func NewChapterRepository(db *sql.DB) *ChapterRepository {
+	return &ChapterRepository{
+		db: db,
+	}
+}
+
+// Create inserts a new chapter record
+func (r *ChapterRepository) Create(ctx context.Context, chapter *entity.Chapter) error {
+	query := `
+		INSERT INTO chapters (
+			id, video_id, title, start_time, end_time, source, created_at, updated_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8
+		)
+	`
+
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		query,
+		chapter.ID,
+		chapter.VideoID,
+		chapter.Title,
+		chapter.Start,
+		chapter.End,
+		string(chapter.Source),
+		chapter.CreatedAt,
+		chapter.UpdatedAt,
+	)
+
+	return err
+}
+
+// GetByID retrieves a chapter by ID
+func (r *ChapterRepository) GetByID(ctx context.Context, id string) (*entity.Chapter, error) {
+	query := `
+		SELECT
+			id, video_id, title, start_time, end_time, source, created_at, updated_at
+		FROM chapters
+		WHERE id = $1
+	`
+
+	row := conn(ctx, r.db).QueryRowContext(ctx, query, id)
+
+	var chapter entity.Chapter
+	var source string
+
+	err := row.Scan(
+		&chapter.ID,
+		&chapter.VideoID,
+		&chapter.Title,
+		&chapter.Start,
+		&chapter.End,
+		&source,
+		&chapter.CreatedAt,
+		&chapter.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("chapter with ID %s not found", id)
+		}
+		return nil, err
+	}
+
+	chapter.Source = entity.ChapterSource(source)
+
+	return &chapter, nil
+}
+
+// GetByVideoID retrieves the chapters of a video in playback order
+func (r *ChapterRepository) GetByVideoID(ctx context.Context, videoID string) ([]*entity.Chapter, error) {
+	query := `
+		SELECT
+			id, video_id, title, start_time, end_time, source, created_at, updated_at
+		FROM chapters
+		WHERE video_id = $1
+		ORDER BY start_time ASC
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, videoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chapters []*entity.Chapter
+
+	for rows.Next() {
+		var chapter entity.Chapter
+		var source string
+
+		err := rows.Scan(
+			&chapter.ID,
+			&chapter.VideoID,
+			&chapter.Title,
+			&chapter.Start,
+			&chapter.End,
+			&source,
+			&chapter.CreatedAt,
+			&chapter.UpdatedAt,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		chapter.Source = entity.ChapterSource(source)
+		chapters = append(chapters, &chapter)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return chapters, nil
+}
+
+// Update updates a chapter record
+func (r *ChapterRepository) Update(ctx context.Context, chapter *entity.Chapter) error {
+	query := `
+		UPDATE chapters
+		SET
+			title = $1,
+			start_time = $2,
+			end_time = $3,
+			source = $4,
+			updated_at = $5
+		WHERE id = $6
+	`
+
+	chapter.UpdatedAt = time.Now()
+
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		query,
+		chapter.Title,
+		chapter.Start,
+		chapter.End,
+		string(chapter.Source),
+		chapter.UpdatedAt,
+		chapter.ID,
+	)
+
+	return err
+}
+
+// Delete deletes a chapter record
+func (r *ChapterRepository) Delete(ctx context.Context, id string) error {
+	query := `DELETE FROM chapters WHERE id = $1`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, id)
+	return err
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+)
+
+// KeyRepository implements domain.repository.KeyRepository. Content keys are
+// sealed with AES-256-GCM so a database dump alone can't decrypt the segments.
+type KeyRepository struct {
+	db        *sql.DB
+	masterKey [32]byte
+}
+
+// NewKeyRepository creates a new encryption key repository. The master key is
+// derived from secret.
+func NewKeyRepository(db *sql.DB, secret string) *KeyRepository {
+	return &KeyRepository{
+		db:        db,
+		masterKey: sha256.Sum256([]byte(secret)),
+	}
+}
+
+// Save stores the content key of a video, replacing an earlier one
+func (r *KeyRepository) Save(ctx context.Context, key *entity.EncryptionKey) error {
+	query := `
+		INSERT INTO encryption_keys (
+			video_id, method, encrypted_key, created_at
+		) VALUES (
+			$1, $2, $3, $4
+		)
+		ON CONFLICT (video_id) DO UPDATE SET
+			method = EXCLUDED.method,
+			encrypted_key = EXCLUDED.encrypted_key,
+			created_at = EXCLUDED.created_at
+	`
+
+	sealed, err := r.seal(key.VideoID, key.Key)
+	if err != nil {
+		return fmt.Errorf("failed to seal encryption key: %w", err)
+	}
+
+	_, err = conn(ctx, r.db).ExecContext(
+		ctx,
+		query,
+		key.VideoID,
+		string(key.Method),
+		sealed,
+		key.CreatedAt,
+	)
+
+	return err
+}
+
+// GetByVideoID retrieves the content key of a video
+func (r *KeyRepository) GetByVideoID(ctx context.Context, videoID string) (*entity.EncryptionKey, error) {
+	query := `
+		SELECT video_id, method, encrypted_key, created_at
+		FROM encryption_keys
+		WHERE video_id = $1
+	`
+
+	var key entity.EncryptionKey
+	var method string
+	var sealed []byte
+
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, videoID).Scan(
+		&key.VideoID,
+		&method,
+		&sealed,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("encryption key for video with ID %s not found", videoID)
+		}
+		return nil, err
+	}
+
+	key.Method = entity.EncryptionMethod(method)
+	key.Key, err = r.open(videoID, sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open encryption key: %w", err)
+	}
+
+	return &key, nil
+}
+
+// seal encrypts a content key, binding it to its video. The nonce is prepended.
+func (r *KeyRepository) seal(videoID string, key []byte) ([]byte, error) {
+	aead, err := r.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, key, []byte(videoID)), nil
+}
+
+// open decrypts a content key sealed by seal
+func (r *KeyRepository) open(videoID string, sealed []byte) ([]byte, error) {
+	aead, err := r.aead()
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("sealed key is too short")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(videoID))
+}
+
+// aead returns the cipher sealing content keys
+func (r *KeyRepository) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(r.masterKey[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
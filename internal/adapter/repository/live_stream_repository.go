@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+)
+
+// liveStreamColumns are the columns read by every live stream query, in scanLiveStream order
+const liveStreamColumns = `
+	id, title, description, stream_key, status, user_id, video_id,
+	started_at, ended_at, created_at, updated_at
+`
+
+// LiveStreamRepository implements domain.repository.LiveStreamRepository
+type LiveStreamRepository struct {
+	db *sql.DB
+}
+
+// NewLiveStreamRepository creates a new live stream repository
+func NewLiveStreamRepository(db *sql.DB) *LiveStreamRepository {
+	return &LiveStreamRepository{
+		db: db,
+	}
+}
+
+// Create inserts a new live stream record
+func (r *LiveStreamRepository) Create(ctx context.Context, stream *entity.LiveStream) error {
+	query := `
+		INSERT INTO live_streams (
+			id, title, description, stream_key, status, user_id, video_id,
+			started_at, ended_at, created_at, updated_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+		)
+	`
+
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		query,
+		stream.ID,
+		stream.Title,
+		stream.Description,
+		stream.StreamKey,
+		string(stream.Status),
+		stream.UserID,
+		stream.VideoID,
+		stream.StartedAt,
+		stream.EndedAt,
+		stream.CreatedAt,
+		stream.UpdatedAt,
+	)
+
+	return err
+}
+
+// GetByID retrieves a live stream by ID
+func (r *LiveStreamRepository) GetByID(ctx context.Context, id string) (*entity.LiveStream, error) {
+	query := `SELECT ` + liveStreamColumns + ` FROM live_streams WHERE id = $1`
+
+	stream, err := scanLiveStream(conn(ctx, r.db).QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("live stream with ID %s not found", id)
+		}
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+// GetByStreamKey retrieves the live stream published under a stream key
+func (r *LiveStreamRepository) GetByStreamKey(ctx context.Context, streamKey string) (*entity.LiveStream, error) {
+	query := `SELECT ` + liveStreamColumns + ` FROM live_streams WHERE stream_key = $1`
+
+	stream, err := scanLiveStream(conn(ctx, r.db).QueryRowContext(ctx, query, streamKey))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("live stream for stream key not found")
+		}
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+// GetByUserID retrieves the live streams of a user, newest first
+func (r *LiveStreamRepository) GetByUserID(ctx context.Context, userID string) ([]*entity.LiveStream, error) {
+	query := `
+		SELECT ` + liveStreamColumns + `
+		FROM live_streams
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var streams []*entity.LiveStream
+
+	for rows.Next() {
+		stream, err := scanLiveStream(rows)
+		if err != nil {
+			return nil, err
+		}
+		streams = append(streams, stream)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return streams, nil
+}
+
+// GetByStatus retrieves the live streams with a status
+func (r *LiveStreamRepository) GetByStatus(ctx context.Context, status entity.LiveStreamStatus) ([]*entity.LiveStream, error) {
+	query := `
+		SELECT ` + liveStreamColumns + `
+		FROM live_streams
+		WHERE status = $1
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, string(status))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var streams []*entity.LiveStream
+
+	for rows.Next() {
+		stream, err := scanLiveStream(rows)
+		if err != nil {
+			return nil, err
+		}
+		streams = append(streams, stream)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return streams, nil
+}
+
+// Update updates a live stream record
+func (r *LiveStreamRepository) Update(ctx context.Context, stream *entity.LiveStream) error {
+	query := `
+		UPDATE live_streams
+		SET
+			title = $1,
+			description = $2,
+			status = $3,
+			video_id = $4,
+			started_at = $5,
+			ended_at = $6,
+			updated_at = $7
+		WHERE id = $8
+	`
+
+	stream.UpdatedAt = time.Now()
+
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		query,
+		stream.Title,
+		stream.Description,
+		string(stream.Status),
+		stream.VideoID,
+		stream.StartedAt,
+		stream.EndedAt,
+		stream.UpdatedAt,
+		stream.ID,
+	)
+
+	return err
+}
+
+// scanLiveStream reads a live stream selected with liveStreamColumns
+func scanLiveStream(row rowScanner) (*entity.LiveStream, error) {
+	var stream entity.LiveStream
+	var status string
+
+	err := row.Scan(
+		&stream.ID,
+		&stream.Title,
+		&stream.Description,
+		&stream.StreamKey,
+		&status,
+		&stream.UserID,
+		&stream.VideoID,
+		&stream.StartedAt,
+		&stream.EndedAt,
+		&stream.CreatedAt,
+		&stream.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stream.Status = entity.LiveStreamStatus(status)
+
+	return &stream, nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+)
+
+// QCRepository implements domain.repository.QCRepository
+type QCRepository struct {
+	db *sql.DB
+}
+
+// NewQCRepository creates a new QC report repository
+func NewQCRepository(db *sql.DB) *QCRepository {
+	return &QCRepository{
+		db: db,
+	}
+}
+
+// Save stores the QC report of a video, replacing an earlier one
+func (r *QCRepository) Save(ctx context.Context, report *entity.QCReport) error {
+	query := `
+		INSERT INTO qc_reports (
+			video_id, report, flagged, created_at
+		) VALUES (
+			$1, $2, $3, $4
+		)
+		ON CONFLICT (video_id) DO UPDATE SET
+			report = EXCLUDED.report,
+			flagged = EXCLUDED.flagged,
+			created_at = EXCLUDED.created_at
+	`
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode QC report: %w", err)
+	}
+
+	_, err = conn(ctx, r.db).ExecContext(
+		ctx,
+		query,
+		report.VideoID,
+		data,
+		report.Flagged,
+		report.CreatedAt,
+	)
+
+	return err
+}
+
+// GetByVideoID retrieves the QC report of a video
+func (r *QCRepository) GetByVideoID(ctx context.Context, videoID string) (*entity.QCReport, error) {
+	query := `
+		SELECT report
+		FROM qc_reports
+		WHERE video_id = $1
+	`
+
+	var data []byte
+	if err := conn(ctx, r.db).QueryRowContext(ctx, query, videoID).Scan(&data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("QC report for video with ID %s not found", videoID)
+		}
+		return nil, err
+	}
+
+	var report entity.QCReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to decode QC report: %w", err)
+	}
+
+	return &report, nil
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+)
+
+// RenditionRepository implements domain.repository.RenditionRepository
+type RenditionRepository struct {
+	db *sql.DB
+}
+
+// NewRenditionRepository creates a new rendition repository
+func NewRenditionRepository(db *sql.DB) *RenditionRepository {
+	return &RenditionRepository{
+		db: db,
+	}
+}
+
+// Create inserts a new rendition record
+func (r *RenditionRepository) Create(ctx context.Context, rendition *entity.Rendition) error {
+	query := `
+		INSERT INTO renditions (
+			id, video_id, name, type, width, height, bandwidth, average_bandwidth, codec, codecs,
+			video_range, init_url, language, label, channels, rate_control, quality, is_default, created_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19
+		)
+	`
+
+	// Audio renditions have no encoder settings and store NULL
+	var rateControl []byte
+	if rendition.RateControl != nil {
+		var err error
+		rateControl, err = json.Marshal(rendition.RateControl)
+		if err != nil {
+			return fmt.Errorf("failed to encode rate control: %w", err)
+		}
+	}
+
+	var quality []byte
+	if rendition.Quality != nil {
+		var err error
+		quality, err = json.Marshal(rendition.Quality)
+		if err != nil {
+			return fmt.Errorf("failed to encode quality score: %w", err)
+		}
+	}
+
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		query,
+		rendition.ID,
+		rendition.VideoID,
+		string(rendition.Name),
+		string(rendition.Type),
+		rendition.Width,
+		rendition.Height,
+		rendition.Bandwidth,
+		rendition.AverageBandwidth,
+		string(rendition.Codec),
+		rendition.Codecs,
+		rendition.VideoRange,
+		rendition.InitURL,
+		rendition.Language,
+		rendition.Label,
+		rendition.Channels,
+		rateControl,
+		quality,
+		rendition.IsDefault,
+		rendition.CreatedAt,
+	)
+
+	return err
+}
+
+// GetByVideoID retrieves renditions for a video
+func (r *RenditionRepository) GetByVideoID(ctx context.Context, videoID string) ([]*entity.Rendition, error) {
+	query := `
+		SELECT
+			id, video_id, name, type, width, height, bandwidth, average_bandwidth, codec, codecs,
+			video_range, init_url, language, label, channels, rate_control, quality, is_default, created_at
+		FROM renditions
+		WHERE video_id = $1
+		ORDER BY type DESC, height DESC, name ASC
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, videoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var renditions []*entity.Rendition
+
+	for rows.Next() {
+		var rendition entity.Rendition
+		var name, renditionType, codec string
+		var rateControl, quality []byte
+
+		err := rows.Scan(
+			&rendition.ID,
+			&rendition.VideoID,
+			&name,
+			&renditionType,
+			&rendition.Width,
+			&rendition.Height,
+			&rendition.Bandwidth,
+			&rendition.AverageBandwidth,
+			&codec,
+			&rendition.Codecs,
+			&rendition.VideoRange,
+			&rendition.InitURL,
+			&rendition.Language,
+			&rendition.Label,
+			&rendition.Channels,
+			&rateControl,
+			&quality,
+			&rendition.IsDefault,
+			&rendition.CreatedAt,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		rendition.Name = entity.Resolution(name)
+		rendition.Type = entity.RenditionType(renditionType)
+		rendition.Codec = entity.VideoCodec(codec)
+		if len(rateControl) > 0 {
+			if err := json.Unmarshal(rateControl, &rendition.RateControl); err != nil {
+				return nil, fmt.Errorf("failed to decode rate control: %w", err)
+			}
+		}
+		if len(quality) > 0 {
+			if err := json.Unmarshal(quality, &rendition.Quality); err != nil {
+				return nil, fmt.Errorf("failed to decode quality score: %w", err)
+			}
+		}
+		renditions = append(renditions, &rendition)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return renditions, nil
+}
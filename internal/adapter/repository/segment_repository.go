@@ -30,7 +30,7 @@ func (r *SegmentRepository) Create(ctx context.Context, segment *entity.Segment)
 		)
 	`
 
-	_, err := r.db.ExecContext(
+	_, err := conn(ctx, r.db).ExecContext(
 		ctx,
 		query,
 		segment.ID,
@@ -58,7 +58,7 @@ func (r *SegmentRepository) GetByVideoID(ctx context.Context, videoID string) ([
 		ORDER BY segment_index ASC
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, videoID)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, videoID)
 	if err != nil {
 		return nil, err
 	}
@@ -112,7 +112,7 @@ func (r *SegmentRepository) GetByVideoIDAndResolution(
 		ORDER BY segment_index ASC
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, videoID, string(resolution))
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, videoID, string(resolution))
 	if err != nil {
 		return nil, err
 	}
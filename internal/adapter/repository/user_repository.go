@@ -31,7 +31,7 @@ func (r *UserRepository) Create(ctx context.Context, user *entity.User) error {
 		)
 	`
 
-	_, err := r.db.ExecContext(
+	_, err := conn(ctx, r.db).ExecContext(
 		ctx,
 		query,
 		user.ID,
@@ -57,7 +57,7 @@ func (r *UserRepository) GetByID(ctx context.Context, id string) (*entity.User,
 		WHERE id = $1
 	`
 
-	row := r.db.QueryRowContext(ctx, query, id)
+	row := conn(ctx, r.db).QueryRowContext(ctx, query, id)
 	return r.scanUser(row)
 }
 
@@ -70,7 +70,7 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*entity.
 		WHERE email = $1
 	`
 
-	row := r.db.QueryRowContext(ctx, query, email)
+	row := conn(ctx, r.db).QueryRowContext(ctx, query, email)
 	return r.scanUser(row)
 }
 
@@ -83,7 +83,7 @@ func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*e
 		WHERE username = $1
 	`
 
-	row := r.db.QueryRowContext(ctx, query, username)
+	row := conn(ctx, r.db).QueryRowContext(ctx, query, username)
 	return r.scanUser(row)
 }
 
@@ -102,7 +102,7 @@ func (r *UserRepository) Update(ctx context.Context, user *entity.User) error {
 		WHERE id = $8
 	`
 
-	_, err := r.db.ExecContext(
+	_, err := conn(ctx, r.db).ExecContext(
 		ctx,
 		query,
 		user.Username,
@@ -121,7 +121,7 @@ func (r *UserRepository) Update(ctx context.Context, user *entity.User) error {
 // Delete deletes a user
 func (r *UserRepository) Delete(ctx context.Context, id string) error {
 	query := `DELETE FROM users WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, id)
 	return err
 }
 
@@ -135,7 +135,7 @@ func (r *UserRepository) List(ctx context.Context, limit, offset int) ([]*entity
 		LIMIT $1 OFFSET $2
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, limit, offset)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -169,7 +169,7 @@ func (r *UserRepository) List(ctx context.Context, limit, offset int) ([]*entity
 
 	// Get total count
 	var total int
-	countErr := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&total)
+	countErr := conn(ctx, r.db).QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&total)
 	if countErr != nil {
 		return users, 0, countErr
 	}
@@ -180,7 +180,7 @@ func (r *UserRepository) List(ctx context.Context, limit, offset int) ([]*entity
 // CountAll counts the total number of users
 func (r *UserRepository) CountAll(ctx context.Context) (int, error) {
 	var count int
-	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count)
+	err := conn(ctx, r.db).QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count)
 	return count, err
 }
 
@@ -188,7 +188,7 @@ func (r *UserRepository) CountAll(ctx context.Context) (int, error) {
 func (r *UserRepository) CheckEmailExists(ctx context.Context, email string) (bool, error) {
 	var exists bool
 	query := "SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)"
-	err := r.db.QueryRowContext(ctx, query, email).Scan(&exists)
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, email).Scan(&exists)
 	return exists, err
 }
 
@@ -196,7 +196,7 @@ func (r *UserRepository) CheckEmailExists(ctx context.Context, email string) (bo
 func (r *UserRepository) CheckUsernameExists(ctx context.Context, username string) (bool, error) {
 	var exists bool
 	query := "SELECT EXISTS(SELECT 1 FROM users WHERE username = $1)"
-	err := r.db.QueryRowContext(ctx, query, username).Scan(&exists)
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, username).Scan(&exists)
 	return exists, err
 }
 
@@ -3,12 +3,20 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"time"
 
 	"cams.dev/video_upload_backend/internal/domain/entity"
 )
 
+// videoColumns are the columns read by every video query, in scanVideo order
+const videoColumns = `
+	id, title, description, duration, original_url, thumbnail_url,
+	status, file_size, mime_type, user_id, resolution_info, checksum, perceptual_hash,
+	metadata, created_at, updated_at
+`
+
 // VideoRepository implements domain.repository.VideoRepository
 type VideoRepository struct {
 	db *sql.DB
@@ -26,13 +34,19 @@ func (r *VideoRepository) Create(ctx context.Context, video *entity.Video) error
 	query := `
 		INSERT INTO videos (
 			id, title, description, duration, original_url, thumbnail_url,
-			status, file_size, mime_type, user_id, resolution_info, created_at, updated_at
+			status, file_size, mime_type, user_id, resolution_info, checksum, perceptual_hash,
+			metadata, created_at, updated_at
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16
 		)
 	`
 
-	_, err := r.db.ExecContext(
+	metadata, err := json.Marshal(video.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode video metadata: %w", err)
+	}
+
+	_, err = conn(ctx, r.db).ExecContext(
 		ctx,
 		query,
 		video.ID,
@@ -46,6 +60,9 @@ func (r *VideoRepository) Create(ctx context.Context, video *entity.Video) error
 		video.MimeType,
 		video.UserID,
 		video.ResolutionInfo,
+		video.Checksum,
+		video.PerceptualHash,
+		metadata,
 		video.CreatedAt,
 		video.UpdatedAt,
 	)
@@ -55,35 +72,9 @@ func (r *VideoRepository) Create(ctx context.Context, video *entity.Video) error
 
 // GetByID retrieves a video by ID
 func (r *VideoRepository) GetByID(ctx context.Context, id string) (*entity.Video, error) {
-	query := `
-		SELECT
-			id, title, description, duration, original_url, thumbnail_url,
-			status, file_size, mime_type, user_id, resolution_info, created_at, updated_at
-		FROM videos
-		WHERE id = $1
-	`
-
-	row := r.db.QueryRowContext(ctx, query, id)
-
-	var video entity.Video
-	var status string
-
-	err := row.Scan(
-		&video.ID,
-		&video.Title,
-		&video.Description,
-		&video.Duration,
-		&video.OriginalURL,
-		&video.ThumbnailURL,
-		&status,
-		&video.FileSize,
-		&video.MimeType,
-		&video.UserID,
-		&video.ResolutionInfo,
-		&video.CreatedAt,
-		&video.UpdatedAt,
-	)
+	query := `SELECT ` + videoColumns + ` FROM videos WHERE id = $1`
 
+	video, err := scanVideo(conn(ctx, r.db).QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("video with ID %s not found", id)
@@ -91,9 +82,7 @@ func (r *VideoRepository) GetByID(ctx context.Context, id string) (*entity.Video
 		return nil, err
 	}
 
-	video.Status = entity.VideoStatus(status)
-
-	return &video, nil
+	return video, nil
 }
 
 // Update updates a video record
@@ -110,13 +99,21 @@ func (r *VideoRepository) Update(ctx context.Context, video *entity.Video) error
 			file_size = $7,
 			mime_type = $8,
 			resolution_info = $9,
-			updated_at = $10
-		WHERE id = $11
+			checksum = $10,
+			perceptual_hash = $11,
+			metadata = $12,
+			updated_at = $13
+		WHERE id = $14
 	`
 
 	video.UpdatedAt = time.Now()
 
-	_, err := r.db.ExecContext(
+	metadata, err := json.Marshal(video.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode video metadata: %w", err)
+	}
+
+	_, err = conn(ctx, r.db).ExecContext(
 		ctx,
 		query,
 		video.Title,
@@ -128,6 +125,9 @@ func (r *VideoRepository) Update(ctx context.Context, video *entity.Video) error
 		video.FileSize,
 		video.MimeType,
 		video.ResolutionInfo,
+		video.Checksum,
+		video.PerceptualHash,
+		metadata,
 		video.UpdatedAt,
 		video.ID,
 	)
@@ -138,16 +138,63 @@ func (r *VideoRepository) Update(ctx context.Context, video *entity.Video) error
 // List retrieves videos with pagination
 func (r *VideoRepository) List(ctx context.Context, userID string, limit, offset int) ([]*entity.Video, error) {
 	query := `
-		SELECT
-			id, title, description, duration, original_url, thumbnail_url,
-			status, file_size, mime_type, user_id, resolution_info, created_at, updated_at
+		SELECT ` + videoColumns + `
 		FROM videos
 		WHERE user_id = $1
 		ORDER BY created_at DESC
 		LIMIT $2 OFFSET $3
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, userID, limit, offset)
+	return r.query(ctx, query, userID, limit, offset)
+}
+
+// GetByChecksum retrieves a user's videos whose original has the given SHA-256
+func (r *VideoRepository) GetByChecksum(ctx context.Context, userID, checksum string) ([]*entity.Video, error) {
+	query := `
+		SELECT ` + videoColumns + `
+		FROM videos
+		WHERE user_id = $1 AND checksum = $2
+		ORDER BY created_at ASC
+	`
+
+	return r.query(ctx, query, userID, checksum)
+}
+
+// ListWithPerceptualHash retrieves every video that has a perceptual hash
+func (r *VideoRepository) ListWithPerceptualHash(ctx context.Context) ([]*entity.Video, error) {
+	query := `
+		SELECT ` + videoColumns + `
+		FROM videos
+		WHERE perceptual_hash <> ''
+		ORDER BY created_at ASC
+	`
+
+	return r.query(ctx, query)
+}
+
+// ListByDuplicateOf retrieves the videos recorded as duplicates of a video,
+// both linked and awaiting a decision
+func (r *VideoRepository) ListByDuplicateOf(ctx context.Context, videoID string) ([]*entity.Video, error) {
+	query := `
+		SELECT ` + videoColumns + `
+		FROM videos
+		WHERE metadata->>'duplicate_of' = $1
+		ORDER BY created_at ASC
+	`
+
+	return r.query(ctx, query, videoID)
+}
+
+// Delete deletes a video. Its segments, renditions and other records are deleted with it.
+func (r *VideoRepository) Delete(ctx context.Context, id string) error {
+	query := `DELETE FROM videos WHERE id = $1`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, id)
+	return err
+}
+
+// query runs a query returning video rows
+func (r *VideoRepository) query(ctx context.Context, query string, args ...interface{}) ([]*entity.Video, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -156,31 +203,11 @@ func (r *VideoRepository) List(ctx context.Context, userID string, limit, offset
 	var videos []*entity.Video
 
 	for rows.Next() {
-		var video entity.Video
-		var status string
-
-		err := rows.Scan(
-			&video.ID,
-			&video.Title,
-			&video.Description,
-			&video.Duration,
-			&video.OriginalURL,
-			&video.ThumbnailURL,
-			&status,
-			&video.FileSize,
-			&video.MimeType,
-			&video.UserID,
-			&video.ResolutionInfo,
-			&video.CreatedAt,
-			&video.UpdatedAt,
-		)
-
+		video, err := scanVideo(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		video.Status = entity.VideoStatus(status)
-		videos = append(videos, &video)
+		videos = append(videos, video)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -189,3 +216,45 @@ func (r *VideoRepository) List(ctx context.Context, userID string, limit, offset
 
 	return videos, nil
 }
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanVideo reads a video selected with videoColumns
+func scanVideo(row rowScanner) (*entity.Video, error) {
+	var video entity.Video
+	var status string
+	var metadata []byte
+
+	err := row.Scan(
+		&video.ID,
+		&video.Title,
+		&video.Description,
+		&video.Duration,
+		&video.OriginalURL,
+		&video.ThumbnailURL,
+		&status,
+		&video.FileSize,
+		&video.MimeType,
+		&video.UserID,
+		&video.ResolutionInfo,
+		&video.Checksum,
+		&video.PerceptualHash,
+		&metadata,
+		&video.CreatedAt,
+		&video.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	video.Status = entity.VideoStatus(status)
+	if err := json.Unmarshal(metadata, &video.Metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode video metadata: %w", err)
+	}
+
+	return &video, nil
+}
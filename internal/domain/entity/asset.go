@@ -0,0 +1,49 @@
+package entity
+
+import (
+	"time"
+)
+
+// AssetType defines the kind of a downloadable asset
+type AssetType string
+
+const (
+	// AssetBurnedSubtitles is an MP4 with a caption track rendered into the picture
+	AssetBurnedSubtitles AssetType = "burned_subtitles"
+	// AssetAudio is the audio track of a video as a standalone file
+	AssetAudio AssetType = "audio"
+	// AssetWaveform is JSON peak data for drawing the audio waveform
+	AssetWaveform AssetType = "waveform"
+	// AssetMP4 is a progressive download MP4 of a ladder rendition
+	AssetMP4 AssetType = "mp4"
+)
+
+// AudioFormat defines the container and codec of an extracted audio file
+type AudioFormat string
+
+const (
+	AudioFormatMP3 AudioFormat = "mp3"
+	AudioFormatM4A AudioFormat = "m4a" // AAC in MP4
+)
+
+// MimeType returns the MIME type of files in the format
+func (f AudioFormat) MimeType() string {
+	if f == AudioFormatMP3 {
+		return "audio/mpeg"
+	}
+	return "audio/mp4"
+}
+
+// Asset represents a downloadable file derived from a video
+type Asset struct {
+	ID        string    `json:"id"`
+	VideoID   string    `json:"video_id"`
+	Type      AssetType `json:"type"`
+	Name      string    `json:"name"`               // e.g. the resolution of a video asset
+	Language  string    `json:"language,omitempty"` // Caption or audio language, if any
+	URL       string    `json:"url"`
+	MimeType  string    `json:"mime_type"`
+	FileSize  int64     `json:"file_size"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,26 @@
+package entity
+
+import (
+	"time"
+)
+
+// CaptionSource defines where a caption track came from
+type CaptionSource string
+
+const (
+	CaptionSourceUpload   CaptionSource = "upload"
+	CaptionSourceEmbedded CaptionSource = "embedded"
+)
+
+// Caption represents a WebVTT subtitle or caption track of a video
+type Caption struct {
+	ID        string        `json:"id"`
+	VideoID   string        `json:"video_id"`
+	Language  string        `json:"language"`
+	Label     string        `json:"label"`
+	Source    CaptionSource `json:"source"`
+	URL       string        `json:"url"`
+	IsDefault bool          `json:"is_default"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
@@ -0,0 +1,25 @@
+package entity
+
+import (
+	"time"
+)
+
+// ChapterSource defines how a chapter was created
+type ChapterSource string
+
+const (
+	ChapterSourceScene  ChapterSource = "scene"  // Detected from scene changes
+	ChapterSourceManual ChapterSource = "manual" // Added by the owner
+)
+
+// Chapter represents a titled time range of a video
+type Chapter struct {
+	ID        string        `json:"id"`
+	VideoID   string        `json:"video_id"`
+	Title     string        `json:"title"`
+	Start     float64       `json:"start"` // Seconds
+	End       float64       `json:"end"`   // Seconds
+	Source    ChapterSource `json:"source"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
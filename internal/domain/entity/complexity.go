@@ -0,0 +1,9 @@
+package entity
+
+// ComplexityAnalysis holds the result of the per-title complexity probe. Sample
+// bitrates come from constant quality test encodes, so harder content needs more bits.
+type ComplexityAnalysis struct {
+	SampleBitrates []int   `json:"sample_bitrates"` // Bits per second of each test encode
+	Bitrate        int     `json:"bitrate"`         // Mean test encode bitrate
+	Factor         float64 `json:"factor"`          // Multiplier applied to the ladder bitrates
+}
@@ -0,0 +1,44 @@
+package entity
+
+import (
+	"math/bits"
+	"strconv"
+)
+
+// PerceptualHashFrames is the number of evenly spaced frames a perceptual hash covers.
+// Each frame contributes a 64-bit difference hash written as 16 hex digits.
+const PerceptualHashFrames = 8
+
+// frameHashLength is the number of hex digits of one frame's hash
+const frameHashLength = 16
+
+// HashDistance returns the mean number of differing bits per frame between two
+// perceptual hashes, from 0 for the same picture to about 32 for unrelated ones.
+// Hashes of a different number of frames can't be compared and return -1.
+func HashDistance(a, b string) float64 {
+	if len(a) != len(b) || len(a) == 0 || len(a)%frameHashLength != 0 {
+		return -1
+	}
+
+	total := 0
+	for i := 0; i < len(a); i += frameHashLength {
+		x, err := strconv.ParseUint(a[i:i+frameHashLength], 16, 64)
+		if err != nil {
+			return -1
+		}
+		y, err := strconv.ParseUint(b[i:i+frameHashLength], 16, 64)
+		if err != nil {
+			return -1
+		}
+		total += bits.OnesCount64(x ^ y)
+	}
+
+	return float64(total) / float64(len(a)/frameHashLength)
+}
+
+// DuplicateCandidate is a video that looks like a copy of another one
+type DuplicateCandidate struct {
+	Video    *Video  `json:"video"`
+	Exact    bool    `json:"exact"`    // Same original file checksum
+	Distance float64 `json:"distance"` // Perceptual hash distance, see HashDistance
+}
@@ -0,0 +1,22 @@
+package entity
+
+// EditOperation defines how a video was derived from other videos
+type EditOperation string
+
+const (
+	EditClip   EditOperation = "clip"
+	EditConcat EditOperation = "concat"
+)
+
+// EditSource references a source video, or a time range of it, used by an edit
+type EditSource struct {
+	VideoID string  `json:"video_id"`
+	Start   float64 `json:"start,omitempty"` // Seconds into the source
+	End     float64 `json:"end,omitempty"`   // Seconds into the source, zero for the whole video
+}
+
+// EditInfo records the sources of a video created by an edit rather than an upload
+type EditInfo struct {
+	Operation EditOperation `json:"operation"`
+	Sources   []EditSource  `json:"sources"`
+}
@@ -0,0 +1,57 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EncryptionMethod defines how the HLS segments of a video are encrypted.
+// Values match the METHOD attribute of EXT-X-KEY.
+type EncryptionMethod string
+
+const (
+	// EncryptionNone leaves segments in the clear
+	EncryptionNone EncryptionMethod = "NONE"
+	// EncryptionAES128 encrypts whole segments with AES-128-CBC
+	EncryptionAES128 EncryptionMethod = "AES-128"
+	// EncryptionSampleAES encrypts H.264 and AAC samples of MPEG-TS segments,
+	// leaving the container readable
+	EncryptionSampleAES EncryptionMethod = "SAMPLE-AES"
+)
+
+// EncryptionKeySize is the length in bytes of an AES-128 content key
+const EncryptionKeySize = 16
+
+// ParseEncryptionMethod parses an encryption method name such as "none" or "aes-128"
+func ParseEncryptionMethod(name string) (EncryptionMethod, error) {
+	switch method := EncryptionMethod(strings.ToUpper(strings.TrimSpace(name))); method {
+	case EncryptionNone, EncryptionAES128, EncryptionSampleAES:
+		return method, nil
+	default:
+		return "", fmt.Errorf("unsupported encryption method: %s", name)
+	}
+}
+
+// Enabled reports whether segments are encrypted. Videos without a method are clear.
+func (m EncryptionMethod) Enabled() bool {
+	return m != "" && m != EncryptionNone
+}
+
+// ForSegments returns the method used for the segments of a rendition. Sample
+// encryption is only implemented for MPEG-TS, so fMP4 renditions use AES-128.
+func (m EncryptionMethod) ForSegments(fmp4 bool) EncryptionMethod {
+	if m == EncryptionSampleAES && fmp4 {
+		return EncryptionAES128
+	}
+	return m
+}
+
+// EncryptionKey is the content key of an encrypted video. Each segment uses its
+// media sequence number as the IV.
+type EncryptionKey struct {
+	VideoID   string           `json:"video_id"`
+	Method    EncryptionMethod `json:"method"`
+	Key       []byte           `json:"-"`
+	CreatedAt time.Time        `json:"created_at"`
+}
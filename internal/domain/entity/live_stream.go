@@ -0,0 +1,54 @@
+package entity
+
+import (
+	"time"
+)
+
+// LiveStreamStatus represents the state of a live stream
+type LiveStreamStatus string
+
+const (
+	LiveStatusIdle  LiveStreamStatus = "idle"  // Waiting for the encoder to connect
+	LiveStatusLive  LiveStreamStatus = "live"  // Being published and played
+	LiveStatusEnded LiveStreamStatus = "ended" // Finished, the recording is available as a video
+)
+
+// LiveStream represents a live event published over RTMP under a secret stream key
+type LiveStream struct {
+	ID          string           `json:"id"`
+	Title       string           `json:"title"`
+	Description string           `json:"description"`
+	StreamKey   string           `json:"stream_key,omitempty"` // Only shown to the owner
+	Status      LiveStreamStatus `json:"status"`
+	UserID      string           `json:"user_id"`
+	VideoID     string           `json:"video_id,omitempty"` // Recording, set when the stream goes live
+	StartedAt   *time.Time       `json:"started_at,omitempty"`
+	EndedAt     *time.Time       `json:"ended_at,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// LiveSegment is a segment of a live rendition that has been uploaded to storage
+type LiveSegment struct {
+	Sequence int         `json:"sequence"`
+	Duration float64     `json:"duration"`
+	FileName string      `json:"file_name"`
+	URL      string      `json:"url"`
+	Size     int         `json:"size"`            // Bytes, to measure the bitrate of the recording
+	Parts    []*LivePart `json:"parts,omitempty"` // Low-latency parts the segment was joined from
+}
+
+// LivePart is a partial segment of a low-latency live rendition. Each part
+// starts with a keyframe.
+type LivePart struct {
+	Duration float64 `json:"duration"`
+	FileName string  `json:"file_name"`
+	URL      string  `json:"url"`
+}
+
+// LiveOptions holds the settings of a live encode
+type LiveOptions struct {
+	SegmentDuration float64 // Target segment length in seconds
+	PartDuration    float64 // Low-latency part length in seconds, zero to write whole segments
+	RecordingPath   string  // Copy of the published stream, empty for none
+}
@@ -0,0 +1,25 @@
+package entity
+
+// LoudnessTarget defines an EBU R128 loudness normalization target
+type LoudnessTarget struct {
+	IntegratedLUFS float64 `json:"integrated_lufs"`
+	TruePeak       float64 `json:"true_peak"`
+	LoudnessRange  float64 `json:"loudness_range"`
+}
+
+// LoudnessMeasurement holds the first-pass loudnorm analysis of an audio stream
+type LoudnessMeasurement struct {
+	StreamIndex    int     `json:"stream_index"`
+	IntegratedLUFS float64 `json:"integrated_lufs"`
+	TruePeak       float64 `json:"true_peak"`
+	LoudnessRange  float64 `json:"loudness_range"`
+	Threshold      float64 `json:"threshold"`
+	TargetOffset   float64 `json:"target_offset"`
+}
+
+// LoudnessNormalization pairs a target with the measured values of the stream
+// being normalized, as required by the second loudnorm pass
+type LoudnessNormalization struct {
+	Target   LoudnessTarget
+	Measured LoudnessMeasurement
+}
@@ -0,0 +1,67 @@
+package entity
+
+// MediaInfo describes the streams of a probed media file
+type MediaInfo struct {
+	Duration        float64          `json:"duration"`
+	Width           int              `json:"width"`
+	Height          int              `json:"height"`
+	Color           ColorInfo        `json:"color"`
+	AudioStreams    []AudioStream    `json:"audio_streams"`
+	SubtitleStreams []SubtitleStream `json:"subtitle_streams"`
+}
+
+// ColorInfo describes the color characteristics of a video stream using
+// ffprobe's naming, e.g. transfer "smpte2084" with primaries "bt2020"
+type ColorInfo struct {
+	Transfer  string `json:"transfer"`
+	Primaries string `json:"primaries"`
+	Matrix    string `json:"matrix"`
+}
+
+// HDRFormat returns the HLS VIDEO-RANGE of the color characteristics:
+// "PQ" for HDR10, "HLG" for hybrid log-gamma and "SDR" otherwise
+func (c ColorInfo) HDRFormat() string {
+	switch c.Transfer {
+	case "smpte2084":
+		return "PQ"
+	case "arib-std-b67":
+		return "HLG"
+	default:
+		return "SDR"
+	}
+}
+
+// IsHDR reports whether the color characteristics use an HDR transfer function
+func (c ColorInfo) IsHDR() bool {
+	return c.HDRFormat() != "SDR"
+}
+
+// AudioStream describes a single audio stream of a media file
+type AudioStream struct {
+	Index    int    `json:"index"` // Position among the audio streams (0:a:<index>)
+	Codec    string `json:"codec"`
+	Language string `json:"language"`
+	Title    string `json:"title"`
+	Channels int    `json:"channels"`
+	Default  bool   `json:"default"`
+}
+
+// SubtitleStream describes a single subtitle stream of a media file
+type SubtitleStream struct {
+	Index    int    `json:"index"` // Position among the subtitle streams (0:s:<index>)
+	Codec    string `json:"codec"`
+	Language string `json:"language"`
+	Title    string `json:"title"`
+	Default  bool   `json:"default"`
+}
+
+// IsText reports whether the subtitle stream is text based and can be converted to WebVTT.
+// Bitmap formats such as PGS and DVB subtitles cannot.
+func (s SubtitleStream) IsText() bool {
+	switch s.Codec {
+	case "subrip", "srt", "ass", "ssa", "mov_text", "webvtt", "text":
+		return true
+	default:
+		return false
+	}
+}
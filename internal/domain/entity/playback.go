@@ -0,0 +1,41 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PlaybackURLMode defines how playlists reference the stored objects of a video
+type PlaybackURLMode string
+
+const (
+	// PlaybackURLPublic references objects by their bucket URL, so the bucket must be public
+	PlaybackURLPublic PlaybackURLMode = "public"
+	// PlaybackURLPresigned references objects by presigned storage URLs
+	PlaybackURLPresigned PlaybackURLMode = "presigned"
+	// PlaybackURLSigned references objects and playlists by HMAC-signed API URLs
+	PlaybackURLSigned PlaybackURLMode = "signed"
+)
+
+// ParsePlaybackURLMode parses a playback URL mode name such as "public" or "signed"
+func ParsePlaybackURLMode(name string) (PlaybackURLMode, error) {
+	switch mode := PlaybackURLMode(strings.ToLower(strings.TrimSpace(name))); mode {
+	case PlaybackURLPublic, PlaybackURLPresigned, PlaybackURLSigned:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported playback URL mode: %s", name)
+	}
+}
+
+// PlaybackToken is a short-lived token that lets embedded players stream a
+// single video or live stream without a user JWT. It may be restricted to a
+// referring host and a client IP.
+type PlaybackToken struct {
+	Token           string    `json:"token"`
+	VideoID         string    `json:"video_id,omitempty"`
+	LiveStreamID    string    `json:"live_stream_id,omitempty"`
+	AllowedReferrer string    `json:"allowed_referrer,omitempty"`
+	AllowedIP       string    `json:"allowed_ip,omitempty"`
+	ExpiresAt       time.Time `json:"expires_at"`
+}
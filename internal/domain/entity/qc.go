@@ -0,0 +1,75 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+// QCInterval is a stretch of a video with a detected defect, in seconds
+type QCInterval struct {
+	Start    float64 `json:"start"`
+	End      float64 `json:"end"`
+	Duration float64 `json:"duration"`
+}
+
+// QCThresholds are the largest shares of a video, in percent of its duration,
+// that may be black, frozen or silent before the video is flagged
+type QCThresholds struct {
+	MaxBlackPercent   float64
+	MaxFreezePercent  float64
+	MaxSilencePercent float64
+}
+
+// QCReport holds the black frame, frozen frame and silence analysis of a video
+type QCReport struct {
+	VideoID      string       `json:"video_id"`
+	Black        []QCInterval `json:"black"`
+	Freeze       []QCInterval `json:"freeze"`
+	Silence      []QCInterval `json:"silence"`
+	BlackTotal   float64      `json:"black_total"`   // Seconds
+	FreezeTotal  float64      `json:"freeze_total"`  // Seconds
+	SilenceTotal float64      `json:"silence_total"` // Seconds
+	Flagged      bool         `json:"flagged"`
+	Reasons      []string     `json:"reasons,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
+
+// Evaluate totals the intervals and flags the report when a total exceeds its
+// threshold for a video of the given duration
+func (r *QCReport) Evaluate(duration float64, thresholds QCThresholds) {
+	r.BlackTotal = totalDuration(r.Black)
+	r.FreezeTotal = totalDuration(r.Freeze)
+	r.SilenceTotal = totalDuration(r.Silence)
+
+	r.Flagged = false
+	r.Reasons = nil
+	if duration <= 0 {
+		return
+	}
+
+	checks := []struct {
+		name  string
+		total float64
+		max   float64
+	}{
+		{"black", r.BlackTotal, thresholds.MaxBlackPercent},
+		{"frozen", r.FreezeTotal, thresholds.MaxFreezePercent},
+		{"silent", r.SilenceTotal, thresholds.MaxSilencePercent},
+	}
+	for _, check := range checks {
+		percent := check.total / duration * 100
+		if percent > check.max {
+			r.Flagged = true
+			r.Reasons = append(r.Reasons, fmt.Sprintf("%.1f%% of the video is %s (limit %.1f%%)", percent, check.name, check.max))
+		}
+	}
+}
+
+// totalDuration sums the durations of intervals
+func totalDuration(intervals []QCInterval) float64 {
+	total := 0.0
+	for _, interval := range intervals {
+		total += interval.Duration
+	}
+	return total
+}
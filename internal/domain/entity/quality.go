@@ -0,0 +1,8 @@
+package entity
+
+// QualityScore holds objective quality metrics of a rendition compared to its source
+type QualityScore struct {
+	VMAF float64 `json:"vmaf"` // Mean VMAF score, 0 to 100
+	PSNR float64 `json:"psnr"` // Average PSNR in dB
+	SSIM float64 `json:"ssim"` // Mean SSIM across all planes, 0 to 1
+}
@@ -0,0 +1,97 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RateControlMode defines how an encoder distributes bits across a rendition
+type RateControlMode string
+
+const (
+	// RateControlCRF encodes at constant quality with unconstrained bitrate
+	RateControlCRF RateControlMode = "crf"
+	// RateControlCappedCRF encodes at constant quality with a VBV maxrate/bufsize cap
+	RateControlCappedCRF RateControlMode = "capped_crf"
+	// RateControlTwoPassABR encodes to an average bitrate using an analysis pass
+	RateControlTwoPassABR RateControlMode = "abr_2pass"
+)
+
+// ParseRateControlMode parses a rate control mode name such as "crf" or "abr_2pass"
+func ParseRateControlMode(name string) (RateControlMode, error) {
+	switch mode := RateControlMode(strings.ToLower(strings.TrimSpace(name))); mode {
+	case RateControlCRF, RateControlCappedCRF, RateControlTwoPassABR:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported rate control mode: %s", name)
+	}
+}
+
+// RateControl holds the rate control settings of a ladder profile. Bitrates are in
+// bits per second.
+type RateControl struct {
+	Mode       RateControlMode `json:"mode"`
+	CRF        int             `json:"crf,omitempty"`
+	Bitrate    int             `json:"bitrate,omitempty"`     // Target average bitrate for two-pass ABR
+	MaxBitrate int             `json:"max_bitrate,omitempty"` // VBV maxrate for capped CRF
+	BufferSize int             `json:"buffer_size,omitempty"` // VBV bufsize for capped CRF
+	Preset     string          `json:"preset,omitempty"`
+	Tune       string          `json:"tune,omitempty"`
+
+	// ComplexityFactor is the per-title multiplier applied to the bitrates, zero if unused
+	ComplexityFactor float64 `json:"complexity_factor,omitempty"`
+}
+
+// Scaled returns the rate control with its bitrates multiplied by a per-title
+// complexity factor
+func (rc RateControl) Scaled(factor float64) RateControl {
+	rc.ComplexityFactor = factor
+	rc.Bitrate = int(float64(rc.Bitrate) * factor)
+	rc.MaxBitrate = int(float64(rc.MaxBitrate) * factor)
+	rc.BufferSize = int(float64(rc.BufferSize) * factor)
+	return rc
+}
+
+// referenceBitrates are the H.264 target bitrates of each resolution
+var referenceBitrates = map[Resolution]int{
+	Resolution1080p: 5000000,
+	Resolution720p:  2800000,
+}
+
+// DefaultRateControl returns rate control settings for a resolution and codec. Newer
+// codecs reach the same quality as H.264 at a fraction of its bitrate.
+func DefaultRateControl(mode RateControlMode, resolution Resolution, codec VideoCodec) RateControl {
+	crf := map[VideoCodec]int{
+		CodecH264: 23,
+		CodecHEVC: 28,
+		CodecVP9:  31,
+		CodecAV1:  35,
+	}[codec]
+	efficiency := map[VideoCodec]float64{
+		CodecH264: 1.0,
+		CodecHEVC: 0.6,
+		CodecVP9:  0.65,
+		CodecAV1:  0.5,
+	}[codec]
+
+	bitrate, ok := referenceBitrates[resolution]
+	if !ok {
+		bitrate = referenceBitrates[Resolution720p]
+	}
+	bitrate = int(float64(bitrate) * efficiency)
+
+	rc := RateControl{Mode: mode}
+	switch mode {
+	case RateControlCappedCRF:
+		rc.CRF = crf
+		rc.MaxBitrate = bitrate
+		rc.BufferSize = 2 * bitrate
+	case RateControlTwoPassABR:
+		rc.Bitrate = bitrate
+	default:
+		rc.Mode = RateControlCRF
+		rc.CRF = crf
+	}
+
+	return rc
+}
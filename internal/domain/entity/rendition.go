@@ -0,0 +1,47 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+// RenditionType defines the kind of media a rendition carries
+type RenditionType string
+
+const (
+	RenditionVideo RenditionType = "video"
+	RenditionAudio RenditionType = "audio"
+)
+
+// Rendition represents a single playable stream of a video in the HLS ladder
+type Rendition struct {
+	ID               string        `json:"id"`
+	VideoID          string        `json:"video_id"`
+	Name             Resolution    `json:"name"` // Matches Segment.Resolution of the rendition's segments
+	Type             RenditionType `json:"type"`
+	Width            int           `json:"width,omitempty"`
+	Height           int           `json:"height,omitempty"`
+	Bandwidth        int           `json:"bandwidth"`         // Peak bitrate in bits per second
+	AverageBandwidth int           `json:"average_bandwidth"` // Average bitrate in bits per second
+	Codec            VideoCodec    `json:"codec,omitempty"`   // Video codec of video renditions
+	Codecs           string        `json:"codecs"`
+	VideoRange       string        `json:"video_range,omitempty"` // SDR, PQ or HLG for video renditions
+	InitURL          string        `json:"init_url,omitempty"`    // fMP4 initialization segment, empty for MPEG-TS
+	Language         string        `json:"language,omitempty"`
+	Label            string        `json:"label,omitempty"`
+	Channels         int           `json:"channels,omitempty"`
+	RateControl      *RateControl  `json:"rate_control,omitempty"` // Encoder settings of video renditions
+	Quality          *QualityScore `json:"quality,omitempty"`      // Quality analysis of video renditions, if enabled
+	IsDefault        bool          `json:"is_default"`
+	CreatedAt        time.Time     `json:"created_at"`
+}
+
+// HDRRenditionName returns the rendition name used for the HDR encode of a resolution
+func HDRRenditionName(resolution Resolution) Resolution {
+	return resolution + "_hdr"
+}
+
+// AudioRenditionName returns the rendition name used for the audio track at index
+func AudioRenditionName(index int) Resolution {
+	return Resolution(fmt.Sprintf("audio_%d", index))
+}
@@ -12,6 +12,18 @@ const (
 	Resolution720p  Resolution = "720p"
 )
 
+// Dimensions returns the frame width and height for a video resolution
+func (r Resolution) Dimensions() (int, int) {
+	switch r {
+	case Resolution1080p:
+		return 1920, 1080
+	case Resolution720p:
+		return 1280, 720
+	default:
+		return 1280, 720 // Default to 720p
+	}
+}
+
 // Segment represents a transcoded video segment
 type Segment struct {
 	ID           string     `json:"id"`
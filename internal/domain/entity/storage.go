@@ -0,0 +1,19 @@
+package entity
+
+import (
+	"io"
+	"time"
+)
+
+// StoredObject is an object streamed from storage. ContentRange is set when
+// only part of the object was requested. NotModified is set, and Body is nil,
+// when the object matched the If-None-Match condition of the request.
+type StoredObject struct {
+	NotModified   bool
+	Body          io.ReadCloser
+	ContentType   string
+	ContentLength int64
+	ContentRange  string
+	ETag          string
+	LastModified  time.Time
+}
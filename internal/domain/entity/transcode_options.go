@@ -0,0 +1,21 @@
+package entity
+
+// TranscodeOptions holds per-video settings applied when encoding renditions
+type TranscodeOptions struct {
+	Loudness *LoudnessNormalization // Nil disables loudness normalization
+	ToneMap  *ColorInfo             // HDR source characteristics to tone map to SDR BT.709
+	HDR      *ColorInfo             // HDR characteristics to preserve in a 10-bit HEVC encode
+
+	// Watermark is overlaid using the local image at WatermarkPath, nil disables it
+	Watermark     *Watermark
+	WatermarkPath string
+
+	// SubtitlePath is a local caption file rendered into the picture, empty disables it
+	SubtitlePath string
+
+	// NoAudio leaves audio out, for renditions played with a separate audio group
+	NoAudio bool
+
+	// KeyframeInterval forces a keyframe every n seconds so segments split evenly, zero disables it
+	KeyframeInterval int
+}
@@ -0,0 +1,80 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VideoCodec defines the video codec of a rendition
+type VideoCodec string
+
+const (
+	CodecH264 VideoCodec = "h264"
+	CodecHEVC VideoCodec = "hevc"
+	CodecVP9  VideoCodec = "vp9"
+	CodecAV1  VideoCodec = "av1"
+)
+
+// ParseVideoCodec parses a codec name such as "h264" or "av1"
+func ParseVideoCodec(name string) (VideoCodec, error) {
+	switch codec := VideoCodec(strings.ToLower(strings.TrimSpace(name))); codec {
+	case CodecH264, CodecHEVC, CodecVP9, CodecAV1:
+		return codec, nil
+	default:
+		return "", fmt.Errorf("unsupported video codec: %s", name)
+	}
+}
+
+// UsesFMP4 reports whether HLS segments of the codec must be fragmented MP4.
+// Only H.264 is widely supported in MPEG-TS.
+func (c VideoCodec) UsesFMP4() bool {
+	return c != CodecH264
+}
+
+// TranscodeProfile describes a single rung of the encoding ladder
+type TranscodeProfile struct {
+	Name        Resolution // Rendition name, e.g. "1080p" or "1080p_hevc"
+	Resolution  Resolution
+	Codec       VideoCodec
+	FPS         int
+	RateControl RateControl
+}
+
+// ProfileName returns the rendition name of a resolution encoded with a codec.
+// H.264 renditions keep the bare resolution name.
+func ProfileName(resolution Resolution, codec VideoCodec) Resolution {
+	if codec == CodecH264 {
+		return resolution
+	}
+	return Resolution(fmt.Sprintf("%s_%s", resolution, codec))
+}
+
+// DefaultLadder returns the standard 1080p and 720p ladder at 24fps for each codec.
+// preset and tune use x264/x265 names, so they only apply to H.264 and HEVC rungs.
+func DefaultLadder(codecs []VideoCodec, mode RateControlMode, preset, tune string) []TranscodeProfile {
+	resolutions := []Resolution{
+		Resolution1080p,
+		Resolution720p,
+	}
+
+	var ladder []TranscodeProfile
+	for _, codec := range codecs {
+		for _, resolution := range resolutions {
+			rc := DefaultRateControl(mode, resolution, codec)
+			if codec == CodecH264 || codec == CodecHEVC {
+				rc.Preset = preset
+				rc.Tune = tune
+			}
+
+			ladder = append(ladder, TranscodeProfile{
+				Name:        ProfileName(resolution, codec),
+				Resolution:  resolution,
+				Codec:       codec,
+				FPS:         24,
+				RateControl: rc,
+			})
+		}
+	}
+
+	return ladder
+}
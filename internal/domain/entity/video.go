@@ -15,21 +15,40 @@ const (
 	StatusSegmented  VideoStatus = "segmented"
 	StatusComplete   VideoStatus = "complete"
 	StatusFailed     VideoStatus = "failed"
+	StatusDuplicate  VideoStatus = "duplicate" // Identical to an earlier upload, awaiting link or process
 )
 
 // Video represents a video entity in the system
 type Video struct {
-	ID             string      `json:"id"`
-	Title          string      `json:"title"`
-	Description    string      `json:"description"`
-	Duration       float64     `json:"duration"`
-	OriginalURL    string      `json:"original_url"`
-	ThumbnailURL   string      `json:"thumbnail_url"`
-	Status         VideoStatus `json:"status"`
-	FileSize       int64       `json:"file_size"`
-	MimeType       string      `json:"mime_type"`
-	UserID         string      `json:"user_id"`
-	ResolutionInfo string      `json:"resolution_info"`
-	CreatedAt      time.Time   `json:"created_at"`
-	UpdatedAt      time.Time   `json:"updated_at"`
+	ID             string        `json:"id"`
+	Title          string        `json:"title"`
+	Description    string        `json:"description"`
+	Duration       float64       `json:"duration"`
+	OriginalURL    string        `json:"original_url"`
+	ThumbnailURL   string        `json:"thumbnail_url"`
+	Status         VideoStatus   `json:"status"`
+	FileSize       int64         `json:"file_size"`
+	MimeType       string        `json:"mime_type"`
+	UserID         string        `json:"user_id"`
+	ResolutionInfo string        `json:"resolution_info"`
+	Checksum       string        `json:"checksum"`                  // SHA-256 of the original file
+	PerceptualHash string        `json:"perceptual_hash,omitempty"` // Hash of sampled frames, see HashDistance
+	Metadata       VideoMetadata `json:"metadata"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+// VideoMetadata holds analysis results gathered while processing a video
+type VideoMetadata struct {
+	Loudness   []LoudnessMeasurement `json:"loudness,omitempty"`
+	Color      *ColorInfo            `json:"color,omitempty"`
+	VideoRange string                `json:"video_range,omitempty"` // SDR, PQ or HLG
+	Complexity *ComplexityAnalysis   `json:"complexity,omitempty"`  // Per-title encoding probe
+	Edit       *EditInfo             `json:"edit,omitempty"`        // Sources of clipped or concatenated videos
+	Watermark  *Watermark            `json:"watermark,omitempty"`   // Overlay applied to the renditions
+	Scenes     []float64             `json:"scenes,omitempty"`      // Scene change times in seconds
+	Encryption EncryptionMethod      `json:"encryption,omitempty"`  // Segment encryption, empty for the configured default
+
+	// DuplicateOf is the earlier upload of the same file, offered or linked in place of processing
+	DuplicateOf string `json:"duplicate_of,omitempty"`
 }
@@ -0,0 +1,42 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+)
+
+// WatermarkPosition defines where a watermark is placed on the frame
+type WatermarkPosition string
+
+const (
+	WatermarkTopLeft     WatermarkPosition = "top-left"
+	WatermarkTopRight    WatermarkPosition = "top-right"
+	WatermarkBottomLeft  WatermarkPosition = "bottom-left"
+	WatermarkBottomRight WatermarkPosition = "bottom-right"
+	WatermarkCenter      WatermarkPosition = "center"
+)
+
+// Watermark describes an image overlaid on every video rendition. Sizes are in
+// pixels of a 1080p frame and scale with the rendition.
+type Watermark struct {
+	ImageURL string            `json:"image_url"`
+	Position WatermarkPosition `json:"position"`
+	Opacity  float64           `json:"opacity"` // 0 (invisible) to 1 (opaque)
+	Margin   int               `json:"margin"`  // Distance from the frame edges
+}
+
+// Validate checks that the watermark settings are usable
+func (w *Watermark) Validate() error {
+	switch w.Position {
+	case WatermarkTopLeft, WatermarkTopRight, WatermarkBottomLeft, WatermarkBottomRight, WatermarkCenter:
+	default:
+		return fmt.Errorf("invalid watermark position: %s", w.Position)
+	}
+	if w.Opacity <= 0 || w.Opacity > 1 {
+		return errors.New("invalid watermark opacity: must be greater than 0 and at most 1")
+	}
+	if w.Margin < 0 || w.Margin > 540 {
+		return errors.New("invalid watermark margin: must be between 0 and 540")
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package entity
+
+// Waveform holds min/max peak pairs of an audio track in the JSON layout produced
+// by audiowaveform, so existing waveform viewers can draw it
+type Waveform struct {
+	Version         int    `json:"version"`
+	Channels        int    `json:"channels"`
+	SampleRate      int    `json:"sample_rate"`
+	SamplesPerPixel int    `json:"samples_per_pixel"` // Audio samples summarized by each pair
+	Bits            int    `json:"bits"`
+	Length          int    `json:"length"` // Number of min/max pairs
+	Data            []int8 `json:"data"`   // Alternating min and max values
+}
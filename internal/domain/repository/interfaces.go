@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"io"
 	"time"
 
 	"cams.dev/video_upload_backend/internal/domain/entity"
@@ -13,6 +14,10 @@ type VideoRepository interface {
 	GetByID(ctx context.Context, id string) (*entity.Video, error)
 	Update(ctx context.Context, video *entity.Video) error
 	List(ctx context.Context, userID string, limit, offset int) ([]*entity.Video, error)
+	GetByChecksum(ctx context.Context, userID, checksum string) ([]*entity.Video, error)
+	ListWithPerceptualHash(ctx context.Context) ([]*entity.Video, error)
+	ListByDuplicateOf(ctx context.Context, videoID string) ([]*entity.Video, error)
+	Delete(ctx context.Context, id string) error
 }
 
 // SegmentRepository defines methods for segment persistence
@@ -22,18 +27,91 @@ type SegmentRepository interface {
 	GetByVideoIDAndResolution(ctx context.Context, videoID string, resolution entity.Resolution) ([]*entity.Segment, error)
 }
 
+// RenditionRepository defines methods for rendition persistence
+type RenditionRepository interface {
+	Create(ctx context.Context, rendition *entity.Rendition) error
+	GetByVideoID(ctx context.Context, videoID string) ([]*entity.Rendition, error)
+}
+
+// CaptionRepository defines methods for caption persistence
+type CaptionRepository interface {
+	Create(ctx context.Context, caption *entity.Caption) error
+	GetByID(ctx context.Context, id string) (*entity.Caption, error)
+	GetByVideoID(ctx context.Context, videoID string) ([]*entity.Caption, error)
+	Update(ctx context.Context, caption *entity.Caption) error
+}
+
+// AssetRepository defines methods for downloadable asset persistence
+type AssetRepository interface {
+	Create(ctx context.Context, asset *entity.Asset) error
+	GetByVideoID(ctx context.Context, videoID string) ([]*entity.Asset, error)
+	Update(ctx context.Context, asset *entity.Asset) error
+}
+
+// ChapterRepository defines methods for chapter persistence
+type ChapterRepository interface {
+	Create(ctx context.Context, chapter *entity.Chapter) error
+	GetByID(ctx context.Context, id string) (*entity.Chapter, error)
+	GetByVideoID(ctx context.Context, videoID string) ([]*entity.Chapter, error)
+	Update(ctx context.Context, chapter *entity.Chapter) error
+	Delete(ctx context.Context, id string) error
+}
+
+// QCRepository defines methods for QC report persistence
+type QCRepository interface {
+	Save(ctx context.Context, report *entity.QCReport) error
+	GetByVideoID(ctx context.Context, videoID string) (*entity.QCReport, error)
+}
+
+// KeyRepository defines methods for HLS content key persistence
+type KeyRepository interface {
+	Save(ctx context.Context, key *entity.EncryptionKey) error
+	GetByVideoID(ctx context.Context, videoID string) (*entity.EncryptionKey, error)
+}
+
+// LiveStreamRepository defines methods for live stream persistence
+type LiveStreamRepository interface {
+	Create(ctx context.Context, stream *entity.LiveStream) error
+	GetByID(ctx context.Context, id string) (*entity.LiveStream, error)
+	GetByStreamKey(ctx context.Context, streamKey string) (*entity.LiveStream, error)
+	GetByUserID(ctx context.Context, userID string) ([]*entity.LiveStream, error)
+	GetByStatus(ctx context.Context, status entity.LiveStreamStatus) ([]*entity.LiveStream, error)
+	Update(ctx context.Context, stream *entity.LiveStream) error
+}
+
 // StorageRepository defines methods for object storage operations
 type StorageRepository interface {
 	UploadFile(ctx context.Context, fileName string, data []byte, contentType string) (string, error)
 	GetFile(ctx context.Context, fileName string) ([]byte, error)
-	GeneratePresignedURL(ctx context.Context, fileName string, expiry time.Duration) (string, error)
+	OpenFile(ctx context.Context, fileName string, byteRange string, ifNoneMatch string) (*entity.StoredObject, error)
+	GeneratePresignedURL(ctx context.Context, fileName string, downloadName string, expiry time.Duration) (string, error)
+	DeleteFile(ctx context.Context, fileName string) error
+	DeletePrefix(ctx context.Context, prefix string) error
 }
 
 // TranscodeRepository defines methods for video transcoding operations
 type TranscodeRepository interface {
-	Transcode(ctx context.Context, inputURL string, outputPath string, resolution entity.Resolution, fps int) error
+	Transcode(ctx context.Context, inputURL string, outputPath string, profile entity.TranscodeProfile, opts entity.TranscodeOptions) error
+	Trim(ctx context.Context, inputPath string, outputPath string, start float64, end float64, reencode bool) error
+	Concat(ctx context.Context, inputPaths []string, outputPath string, resolution entity.Resolution, fps int) error
+	Keyframes(ctx context.Context, videoPath string) ([]float64, error)
+	DetectScenes(ctx context.Context, videoPath string, threshold float64) ([]float64, error)
+	PerceptualHash(ctx context.Context, videoPath string, duration float64) (string, error)
+	DetectDefects(ctx context.Context, videoPath string, duration float64, audio bool) (*entity.QCReport, error)
 	Segment(ctx context.Context, videoPath string, segmentDuration int, outputPath string) ([]string, error)
+	SegmentFMP4(ctx context.Context, videoPath string, segmentDuration int, outputDir string) (initPath string, segments []string, err error)
+	TranscodeAudio(ctx context.Context, inputPath string, outputPath string, streamIndex int, bitrate int, opts entity.TranscodeOptions) error
+	MuxAudio(ctx context.Context, videoPath string, audioPath string, outputPath string) error
+	ExtractAudio(ctx context.Context, inputPath string, outputPath string, streamIndex int, format entity.AudioFormat, opts entity.TranscodeOptions) error
+	Waveform(ctx context.Context, inputPath string, streamIndex int, intervalMs int) (*entity.Waveform, error)
+	MeasureLoudness(ctx context.Context, inputPath string, streamIndex int, target entity.LoudnessTarget) (*entity.LoudnessMeasurement, error)
+	ExtractSubtitle(ctx context.Context, inputPath string, outputPath string, streamIndex int) error
 	GetVideoInfo(ctx context.Context, videoPath string) (duration float64, width int, height int, err error)
+	Probe(ctx context.Context, videoPath string) (*entity.MediaInfo, error)
+	CodecString(ctx context.Context, videoPath string) (string, error)
+	EncodeSample(ctx context.Context, inputPath string, start float64, duration float64, profile entity.TranscodeProfile, opts entity.TranscodeOptions) (bitrate int, err error)
+	MeasureQuality(ctx context.Context, renditionPath string, sourcePath string, profile entity.TranscodeProfile, opts entity.TranscodeOptions) (*entity.QualityScore, error)
+	TranscodeLive(ctx context.Context, input io.Reader, outputDir string, ladder []entity.TranscodeProfile, opts entity.LiveOptions) error
 }
 
 // UserRepository defines methods for user persistence
@@ -49,3 +127,8 @@ type UserRepository interface {
 	CheckEmailExists(ctx context.Context, email string) (bool, error)
 	CheckUsernameExists(ctx context.Context, username string) (bool, error)
 }
+
+// Transactor runs repository calls in a single database transaction
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -20,6 +20,18 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
+// playbackTokenAudience marks playback tokens so they are never accepted as user tokens
+const playbackTokenAudience = "playback"
+
+// PlaybackClaims defines the claims of a playback token
+type PlaybackClaims struct {
+	VideoID      string `json:"videoId,omitempty"`
+	LiveStreamID string `json:"liveStreamId,omitempty"`
+	Referrer     string `json:"referrer,omitempty"`
+	IP           string `json:"ip,omitempty"`
+	jwt.RegisteredClaims
+}
+
 // JWTService provides JWT authentication functionality
 type JWTService struct {
 	secretKey     string
@@ -75,6 +87,63 @@ func (s *JWTService) ValidateToken(tokenString string) (*Claims, error) {
 	}
 
 	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+		if claims.VerifyAudience(playbackTokenAudience, true) {
+			return nil, errors.New("playback tokens cannot authenticate users")
+		}
+		return claims, nil
+	}
+
+	return nil, errors.New("invalid token")
+}
+
+// GeneratePlaybackToken generates a JWT token scoped to the video or live stream of a playback token
+func (s *JWTService) GeneratePlaybackToken(playbackToken *entity.PlaybackToken) (string, error) {
+	subject := playbackToken.VideoID
+	if subject == "" {
+		subject = playbackToken.LiveStreamID
+	}
+
+	claims := &PlaybackClaims{
+		VideoID:      playbackToken.VideoID,
+		LiveStreamID: playbackToken.LiveStreamID,
+		Referrer:     playbackToken.AllowedReferrer,
+		IP:           playbackToken.AllowedIP,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(playbackToken.ExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    "cams.dev/video_upload_backend",
+			Subject:   subject,
+			Audience:  jwt.ClaimStrings{playbackTokenAudience},
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(s.secretKey))
+	if err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+// ValidatePlaybackToken validates a playback token and returns its claims
+func (s *JWTService) ValidatePlaybackToken(tokenString string) (*PlaybackClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &PlaybackClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(s.secretKey), nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := token.Claims.(*PlaybackClaims); ok && token.Valid {
+		if !claims.VerifyAudience(playbackTokenAudience, true) {
+			return nil, errors.New("not a playback token")
+		}
 		return claims, nil
 	}
 
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestURLSignerVerify(t *testing.T) {
+	const path = "/api/v1/videos/abc/hls/720p/segment_0.ts"
+
+	signer := NewURLSigner("test-secret")
+	signed := func(signer *URLSigner, expiresAt time.Time) (string, string) {
+		query, err := url.ParseQuery(signer.SignedQuery(path, expiresAt))
+		if err != nil {
+			t.Fatalf("ParseQuery() error = %v", err)
+		}
+		return query.Get("expires"), query.Get("signature")
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	expires, signature := signed(signer, expiresAt)
+	expiredExpires, expiredSignature := signed(signer, time.Now().Add(-time.Minute))
+	_, otherSecretSignature := signed(NewURLSigner("other-secret"), expiresAt)
+	laterExpires := strconv.FormatInt(time.Now().Add(48*time.Hour).Unix(), 10)
+
+	// Change the first character of the signature
+	tampered := []byte(signature)
+	if tampered[0] == 'A' {
+		tampered[0] = 'B'
+	} else {
+		tampered[0] = 'A'
+	}
+
+	tests := []struct {
+		name      string
+		signer    *URLSigner
+		path      string
+		expires   string
+		signature string
+		wantErr   bool
+	}{
+		{
+			name:      "valid",
+			signer:    signer,
+			path:      path,
+			expires:   expires,
+			signature: signature,
+		},
+		{
+			name:      "expired",
+			signer:    signer,
+			path:      path,
+			expires:   expiredExpires,
+			signature: expiredSignature,
+			wantErr:   true,
+		},
+		{
+			name:      "tampered path",
+			signer:    signer,
+			path:      "/api/v1/videos/xyz/hls/720p/segment_0.ts",
+			expires:   expires,
+			signature: signature,
+			wantErr:   true,
+		},
+		{
+			name:      "tampered expiry",
+			signer:    signer,
+			path:      path,
+			expires:   laterExpires,
+			signature: signature,
+			wantErr:   true,
+		},
+		{
+			name:      "tampered signature",
+			signer:    signer,
+			path:      path,
+			expires:   expires,
+			signature: string(tampered),
+			wantErr:   true,
+		},
+		{
+			name:      "signed with another secret",
+			signer:    signer,
+			path:      path,
+			expires:   expires,
+			signature: otherSecretSignature,
+			wantErr:   true,
+		},
+		{
+			name:      "missing signature",
+			signer:    signer,
+			path:      path,
+			expires:   expires,
+			signature: "",
+			wantErr:   true,
+		},
+		{
+			name:      "malformed expiry",
+			signer:    signer,
+			path:      path,
+			expires:   "tomorrow",
+			signature: signature,
+			wantErr:   true,
+		},
+		{
+			name:      "empty secret",
+			signer:    NewURLSigner(""),
+			path:      path,
+			expires:   expires,
+			signature: NewURLSigner("").signature(path, expires),
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.signer.Verify(tt.path, tt.expires, tt.signature)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -3,15 +3,21 @@ package storage
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 
+	"cams.dev/video_upload_backend/internal/domain/entity"
 	"cams.dev/video_upload_backend/internal/domain/repository"
 )
 
@@ -72,25 +78,32 @@ func (s *S3Storage) UploadFile(
 		return "", fmt.Errorf("failed to upload file: %w", err)
 	}
 
-	// Build the URL
-	var url string
+	return s.objectURL(fileName), nil
+}
+
+// objectURL builds the public URL of an object key
+func (s *S3Storage) objectURL(key string) string {
 	if s.endpoint != "" {
 		// For Minio or custom S3 endpoint
-		url = fmt.Sprintf("%s/%s/%s", s.endpoint, s.bucketName, fileName)
-	} else {
-		// For AWS S3
-		url = fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucketName, s.region, fileName)
+		return fmt.Sprintf("%s/%s/%s", s.endpoint, s.bucketName, key)
 	}
 
-	return url, nil
+	// For AWS S3
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucketName, s.region, key)
 }
 
-// GetFile retrieves a file from S3/Minio
+// objectKey returns the object key of a file name, which may also be a URL
+// returned by UploadFile
+func (s *S3Storage) objectKey(fileName string) string {
+	return strings.TrimPrefix(fileName, s.objectURL(""))
+}
+
+// GetFile retrieves a file from S3/Minio by key or URL
 func (s *S3Storage) GetFile(ctx context.Context, fileName string) ([]byte, error) {
 	// Create input for S3 GetObject
 	input := &s3.GetObjectInput{
 		Bucket: aws.String(s.bucketName),
-		Key:    aws.String(fileName),
+		Key:    aws.String(s.objectKey(fileName)),
 	}
 
 	// Get the object
@@ -109,17 +122,74 @@ func (s *S3Storage) GetFile(ctx context.Context, fileName string) ([]byte, error
 	return data, nil
 }
 
-// GeneratePresignedURL generates a presigned URL for a file
+// OpenFile streams a file from S3/Minio by key or URL. byteRange and ifNoneMatch
+// are optional HTTP Range and If-None-Match header values. The caller must close
+// the body, unless the object is not modified and there is none.
+func (s *S3Storage) OpenFile(ctx context.Context, fileName string, byteRange string, ifNoneMatch string) (*entity.StoredObject, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(s.objectKey(fileName)),
+	}
+	if byteRange != "" {
+		input.Range = aws.String(byteRange)
+	}
+	if ifNoneMatch != "" {
+		input.IfNoneMatch = aws.String(ifNoneMatch)
+	}
+
+	req, result := s.client.GetObjectRequest(input)
+	req.SetContext(ctx)
+	if err := req.Send(); err != nil {
+		var failure awserr.RequestFailure
+		if errors.As(err, &failure) {
+			switch failure.StatusCode() {
+			case http.StatusNotModified:
+				// The error drops the validators S3 sent along with the 304
+				object := &entity.StoredObject{NotModified: true}
+				if req.HTTPResponse != nil {
+					object.ETag = req.HTTPResponse.Header.Get("ETag")
+					object.LastModified, _ = http.ParseTime(req.HTTPResponse.Header.Get("Last-Modified"))
+				}
+				return object, nil
+			case http.StatusNotFound:
+				return nil, fmt.Errorf("file %s not found", fileName)
+			case http.StatusRequestedRangeNotSatisfiable:
+				return nil, fmt.Errorf("invalid range %s for file %s", byteRange, fileName)
+			}
+		}
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+
+	return &entity.StoredObject{
+		Body:          result.Body,
+		ContentType:   aws.StringValue(result.ContentType),
+		ContentLength: aws.Int64Value(result.ContentLength),
+		ContentRange:  aws.StringValue(result.ContentRange),
+		ETag:          aws.StringValue(result.ETag),
+		LastModified:  aws.TimeValue(result.LastModified),
+	}, nil
+}
+
+// GeneratePresignedURL generates a presigned URL for a file by key or URL. A
+// non-empty downloadName makes browsers save the file under that name.
 func (s *S3Storage) GeneratePresignedURL(
 	ctx context.Context,
 	fileName string,
+	downloadName string,
 	expiry time.Duration,
 ) (string, error) {
-	// Create a request for the presigned URL
-	req, _ := s.client.GetObjectRequest(&s3.GetObjectInput{
+	input := &s3.GetObjectInput{
 		Bucket: aws.String(s.bucketName),
-		Key:    aws.String(fileName),
-	})
+		Key:    aws.String(s.objectKey(fileName)),
+	}
+	if downloadName != "" {
+		// S3 returns this header in place of the stored one
+		disposition := mime.FormatMediaType("attachment", map[string]string{"filename": downloadName})
+		input.ResponseContentDisposition = aws.String(disposition)
+	}
+
+	// Create a request for the presigned URL
+	req, _ := s.client.GetObjectRequest(input)
 
 	// Generate the presigned URL
 	url, err := req.Presign(expiry)
@@ -129,3 +199,68 @@ func (s *S3Storage) GeneratePresignedURL(
 
 	return url, nil
 }
+
+// DeleteFile deletes a file from S3/Minio by key or URL. Deleting a missing
+// file is not an error.
+func (s *S3Storage) DeleteFile(ctx context.Context, fileName string) error {
+	input := &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(s.objectKey(fileName)),
+	}
+
+	if _, err := s.client.DeleteObjectWithContext(ctx, input); err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	return nil
+}
+
+// DeletePrefix deletes every file whose key starts with prefix, such as "videos/<id>/"
+func (s *S3Storage) DeletePrefix(ctx context.Context, prefix string) error {
+	// An empty prefix would match the whole bucket
+	if prefix == "" {
+		return errors.New("invalid prefix: must not be empty")
+	}
+
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucketName),
+		Prefix: aws.String(prefix),
+	}
+
+	// A page lists at most 1000 keys, as many as one DeleteObjects call takes
+	var deleteErr error
+	err := s.client.ListObjectsV2PagesWithContext(ctx, input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		if len(page.Contents) == 0 {
+			return true
+		}
+
+		objects := make([]*s3.ObjectIdentifier, 0, len(page.Contents))
+		for _, object := range page.Contents {
+			objects = append(objects, &s3.ObjectIdentifier{Key: object.Key})
+		}
+
+		output, err := s.client.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucketName),
+			Delete: &s3.Delete{
+				Objects: objects,
+				Quiet:   aws.Bool(true),
+			},
+		})
+		if err != nil {
+			deleteErr = fmt.Errorf("failed to delete files: %w", err)
+			return false
+		}
+		if len(output.Errors) > 0 {
+			failure := output.Errors[0]
+			deleteErr = fmt.Errorf("failed to delete file %s: %s", aws.StringValue(failure.Key), aws.StringValue(failure.Message))
+			return false
+		}
+
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+
+	return deleteErr
+}
@@ -0,0 +1,161 @@
+package transcode
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+)
+
+// waveformSampleRate is the rate audio is resampled to before computing peaks,
+// plenty for the resolution of a drawn waveform
+const waveformSampleRate = 8000
+
+// ExtractAudio encodes a single audio stream of the input into a standalone MP3 or
+// M4A file at a quality suited to editing rather than streaming
+func (s *FFmpegService) ExtractAudio(
+	ctx context.Context,
+	inputPath string,
+	outputPath string,
+	streamIndex int,
+	format entity.AudioFormat,
+	opts entity.TranscodeOptions,
+) error {
+	// Prepare the FFmpeg command
+	args := []string{
+		"-i", inputPath,
+		"-map", fmt.Sprintf("0:a:%d", streamIndex),
+		"-vn",
+	}
+	if opts.Loudness != nil {
+		args = append(args, "-af", loudnormFilter(opts.Loudness))
+	}
+	switch format {
+	case entity.AudioFormatMP3:
+		args = append(args,
+			"-c:a", "libmp3lame",
+			"-q:a", "2", // VBR around 190 kbit/s
+		)
+	case entity.AudioFormatM4A:
+		args = append(args,
+			"-c:a", "aac",
+			"-b:a", "256k",
+			"-movflags", "+faststart",
+		)
+	default:
+		return fmt.Errorf("unsupported audio format: %s", format)
+	}
+	args = append(args,
+		"-y",
+		outputPath,
+	)
+
+	// Create the command
+	cmd := exec.CommandContext(ctx, s.ffmpegPath, args...)
+
+	// Capture stdout and stderr
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// Run the command
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg audio extraction failed: %w", err)
+	}
+
+	return nil
+}
+
+// Waveform decodes an audio stream to mono PCM and returns the minimum and maximum
+// sample of every intervalMs milliseconds as 8-bit values
+func (s *FFmpegService) Waveform(
+	ctx context.Context,
+	inputPath string,
+	streamIndex int,
+	intervalMs int,
+) (*entity.Waveform, error) {
+	samplesPerPixel := waveformSampleRate * intervalMs / 1000
+	if samplesPerPixel < 1 {
+		return nil, fmt.Errorf("waveform interval of %d ms is too short", intervalMs)
+	}
+
+	// Prepare the FFmpeg command, writing raw samples to stdout
+	args := []string{
+		"-i", inputPath,
+		"-map", fmt.Sprintf("0:a:%d", streamIndex),
+		"-vn",
+		"-ac", "1",
+		"-ar", strconv.Itoa(waveformSampleRate),
+		"-f", "s16le",
+		"-c:a", "pcm_s16le",
+		"-",
+	}
+
+	// Create the command
+	cmd := exec.CommandContext(ctx, s.ffmpegPath, args...)
+
+	// Capture stderr and read samples from stdout as they are decoded
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ffmpeg output: %w", err)
+	}
+
+	// Run the command
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("ffmpeg waveform failed: %w", err)
+	}
+
+	waveform := &entity.Waveform{
+		Version:         2,
+		Channels:        1,
+		SampleRate:      waveformSampleRate,
+		SamplesPerPixel: samplesPerPixel,
+		Bits:            8,
+	}
+
+	reader := bufio.NewReader(stdout)
+	buf := make([]byte, 2)
+	var low, high int16
+	count := 0
+	for {
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			cmd.Wait()
+			return nil, fmt.Errorf("failed to read audio samples: %w", err)
+		}
+		sample := int16(binary.LittleEndian.Uint16(buf))
+
+		if count == 0 || sample < low {
+			low = sample
+		}
+		if count == 0 || sample > high {
+			high = sample
+		}
+		count++
+
+		if count == samplesPerPixel {
+			waveform.Data = append(waveform.Data, int8(low>>8), int8(high>>8))
+			count = 0
+		}
+	}
+	if count > 0 {
+		waveform.Data = append(waveform.Data, int8(low>>8), int8(high>>8))
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("ffmpeg waveform failed: %w", err)
+	}
+
+	waveform.Length = len(waveform.Data) / 2
+
+	return waveform, nil
+}
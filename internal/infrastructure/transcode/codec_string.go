@@ -0,0 +1,136 @@
+package transcode
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// CodecString returns the RFC 6381 CODECS value describing the streams of an
+// encoded rendition, e.g. "avc1.640028,mp4a.40.2"
+func (s *FFmpegService) CodecString(ctx context.Context, videoPath string) (string, error) {
+	probe, err := s.probe(ctx, videoPath)
+	if err != nil {
+		return "", err
+	}
+
+	var codecs []string
+	for _, stream := range probe.Streams {
+		var codec string
+		switch stream.CodecName {
+		case "h264":
+			codec = avcCodecString(stream.Profile, stream.Level)
+		case "hevc":
+			codec = hevcCodecString(stream.Profile, stream.Level)
+		case "vp9":
+			codec = vp9CodecString(stream.Profile, stream.Width, stream.Height, stream.PixFmt)
+		case "av1":
+			codec = av1CodecString(stream.Level, stream.Width, stream.Height, stream.PixFmt)
+		case "aac":
+			codec = "mp4a.40.2"
+		default:
+			continue
+		}
+		codecs = append(codecs, codec)
+	}
+
+	if len(codecs) == 0 {
+		return "", fmt.Errorf("no supported streams found in %s", videoPath)
+	}
+
+	return strings.Join(codecs, ","), nil
+}
+
+// avcCodecString builds "avc1.PPCCLL" from the H.264 profile and level
+func avcCodecString(profile string, level int) string {
+	// profile_idc and constraint flags as written by libx264
+	profiles := map[string][2]int{
+		"Constrained Baseline":  {0x42, 0xC0},
+		"Baseline":              {0x42, 0x00},
+		"Main":                  {0x4D, 0x40},
+		"High":                  {0x64, 0x00},
+		"High 10":               {0x6E, 0x00},
+		"High 4:2:2":            {0x7A, 0x00},
+		"High 4:4:4 Predictive": {0xF4, 0x00},
+	}
+
+	p, ok := profiles[profile]
+	if !ok {
+		p = profiles["High"]
+	}
+
+	return fmt.Sprintf("avc1.%02X%02X%02X", p[0], p[1], level)
+}
+
+// hevcCodecString builds "hvc1.P.C.LXX.B0" from the HEVC profile and level
+func hevcCodecString(profile string, level int) string {
+	// general_profile_idc and the matching compatibility flags in reverse bit order
+	switch profile {
+	case "Main 10":
+		return fmt.Sprintf("hvc1.2.4.L%d.B0", level)
+	default:
+		return fmt.Sprintf("hvc1.1.6.L%d.B0", level)
+	}
+}
+
+// vp9CodecString builds "vp09.PP.LL.DD" from the VP9 profile, frame size and depth
+func vp9CodecString(profile string, width, height int, pixFmt string) string {
+	profileNumber := 0
+	if n := strings.TrimPrefix(profile, "Profile "); n != profile && len(n) == 1 {
+		profileNumber = int(n[0] - '0')
+	}
+
+	// VP9 levels by maximum picture size
+	level := 10
+	samples := width * height
+	switch {
+	case samples > 8912896:
+		level = 60
+	case samples > 2228224:
+		level = 50
+	case samples > 983040:
+		level = 40
+	case samples > 552960:
+		level = 31
+	case samples > 245760:
+		level = 30
+	case samples > 122880:
+		level = 21
+	case samples > 36864:
+		level = 20
+	}
+
+	return fmt.Sprintf("vp09.%02d.%02d.%02d", profileNumber, level, bitDepth(pixFmt))
+}
+
+// av1CodecString builds "av01.P.LLT.DD" for the Main profile from the AV1 level and depth
+func av1CodecString(level int, width, height int, pixFmt string) string {
+	// ffprobe reports seq_level_idx; estimate it from the frame size when missing
+	if level < 0 {
+		samples := width * height
+		switch {
+		case samples > 2228224:
+			level = 12 // 5.0
+		case samples > 983040:
+			level = 8 // 4.0
+		case samples > 665856:
+			level = 5 // 3.1
+		default:
+			level = 4 // 3.0
+		}
+	}
+
+	return fmt.Sprintf("av01.0.%02dM.%02d", level, bitDepth(pixFmt))
+}
+
+// bitDepth returns the bit depth of a pixel format such as "yuv420p10le"
+func bitDepth(pixFmt string) int {
+	switch {
+	case strings.Contains(pixFmt, "p12"):
+		return 12
+	case strings.Contains(pixFmt, "p10"):
+		return 10
+	default:
+		return 8
+	}
+}
@@ -0,0 +1,192 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+)
+
+// Trim cuts the range [start, end) seconds out of the input. Without reencode the
+// streams are copied, which is only frame accurate when start is on a keyframe.
+func (s *FFmpegService) Trim(
+	ctx context.Context,
+	inputPath string,
+	outputPath string,
+	start float64,
+	end float64,
+	reencode bool,
+) error {
+	// Prepare the FFmpeg command, seeking before the input for speed
+	args := []string{
+		"-ss", strconv.FormatFloat(start, 'f', 3, 64),
+		"-i", inputPath,
+		"-t", strconv.FormatFloat(end-start, 'f', 3, 64),
+		"-map", "0:v:0",
+		"-map", "0:a?",
+	}
+	if reencode {
+		// High quality mezzanine for the normal processing pipeline
+		args = append(args,
+			"-c:v", "libx264",
+			"-crf", "18",
+			"-preset", "veryfast",
+			"-c:a", "aac",
+			"-b:a", "192k",
+		)
+	} else {
+		args = append(args,
+			"-c", "copy",
+			"-avoid_negative_ts", "make_zero",
+		)
+	}
+	args = append(args,
+		"-movflags", "+faststart",
+		"-y",
+		outputPath,
+	)
+
+	// Create the command
+	cmd := exec.CommandContext(ctx, s.ffmpegPath, args...)
+
+	// Capture stdout and stderr
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// Run the command
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg trim failed: %w", err)
+	}
+
+	return nil
+}
+
+// Keyframes returns the presentation times in seconds of the keyframes of the first
+// video stream
+func (s *FFmpegService) Keyframes(ctx context.Context, videoPath string) ([]float64, error) {
+	// Packet flags are read from the container, so no decoding is needed
+	args := []string{
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "packet=pts_time,flags",
+		"-of", "csv=p=0",
+		videoPath,
+	}
+
+	// Create the command
+	cmd := exec.CommandContext(ctx, s.ffprobePath, args...)
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+
+	// Run the command
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffprobe keyframes failed: %w", err)
+	}
+
+	var keyframes []float64
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		fields := strings.Split(strings.TrimSpace(line), ",")
+		if len(fields) < 2 || !strings.Contains(fields[1], "K") {
+			continue
+		}
+
+		pts, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue // Packets without a timestamp
+		}
+		keyframes = append(keyframes, pts)
+	}
+
+	return keyframes, nil
+}
+
+// Concat joins the inputs in order with the concat filter. Every input is scaled and
+// padded to the resolution, resampled to fps and given 48 kHz stereo audio, with
+// silence for inputs without an audio stream. HDR inputs are tone mapped to SDR
+// so they match the others.
+func (s *FFmpegService) Concat(
+	ctx context.Context,
+	inputPaths []string,
+	outputPath string,
+	resolution entity.Resolution,
+	fps int,
+) error {
+	width, height := s.getResolutionParams(resolution)
+
+	var args []string
+	for _, path := range inputPaths {
+		args = append(args, "-i", path)
+	}
+
+	// Normalize every input to the common profile, then concatenate
+	var filters, pairs []string
+	silence := len(inputPaths)
+	for i, path := range inputPaths {
+		info, err := s.Probe(ctx, path)
+		if err != nil {
+			return err
+		}
+
+		toneMap := ""
+		if info.Color.IsHDR() {
+			toneMap = toneMapFilter(&info.Color) + ","
+		}
+		filters = append(filters, fmt.Sprintf(
+			"[%d:v:0]%sscale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=%d,format=yuv420p[v%d]",
+			i, toneMap, width, height, width, height, fps, i,
+		))
+
+		if len(info.AudioStreams) > 0 {
+			filters = append(filters, fmt.Sprintf(
+				"[%d:a:0]aformat=sample_rates=48000:channel_layouts=stereo,asetpts=PTS-STARTPTS[a%d]", i, i,
+			))
+		} else {
+			// Generated silence as long as the video
+			args = append(args,
+				"-f", "lavfi",
+				"-t", strconv.FormatFloat(info.Duration, 'f', 3, 64),
+				"-i", "anullsrc=r=48000:cl=stereo",
+			)
+			filters = append(filters, fmt.Sprintf("[%d:a:0]asetpts=PTS-STARTPTS[a%d]", silence, i))
+			silence++
+		}
+
+		pairs = append(pairs, fmt.Sprintf("[v%d][a%d]", i, i))
+	}
+	filters = append(filters, fmt.Sprintf("%sconcat=n=%d:v=1:a=1[v][a]", strings.Join(pairs, ""), len(inputPaths)))
+
+	// Prepare the FFmpeg command
+	args = append(args,
+		"-filter_complex", strings.Join(filters, ";"),
+		"-map", "[v]",
+		"-map", "[a]",
+		"-c:v", "libx264",
+		"-crf", "18",
+		"-preset", "veryfast",
+		"-c:a", "aac",
+		"-b:a", "192k",
+		"-movflags", "+faststart",
+		"-y",
+		outputPath,
+	)
+
+	// Create the command
+	cmd := exec.CommandContext(ctx, s.ffmpegPath, args...)
+
+	// Capture stdout and stderr
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// Run the command
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg concat failed: %w", err)
+	}
+
+	return nil
+}
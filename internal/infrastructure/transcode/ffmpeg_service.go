@@ -1,8 +1,11 @@
 package transcode
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -17,48 +20,261 @@ import (
 type FFmpegService struct {
 	ffmpegPath  string
 	ffprobePath string
+	av1Encoder  string
 }
 
-// NewFFmpegService creates a new FFmpeg service
-func NewFFmpegService(ffmpegPath, ffprobePath string) repository.TranscodeRepository {
+// NewFFmpegService creates a new FFmpeg service. av1Encoder selects the AV1
+// encoder library, either "libsvtav1" or "libaom-av1".
+func NewFFmpegService(ffmpegPath, ffprobePath, av1Encoder string) repository.TranscodeRepository {
 	return &FFmpegService{
 		ffmpegPath:  ffmpegPath,
 		ffprobePath: ffprobePath,
+		av1Encoder:  av1Encoder,
 	}
 }
 
 // getResolutionParams returns width and height for a given resolution
 func (s *FFmpegService) getResolutionParams(resolution entity.Resolution) (int, int) {
-	switch resolution {
-	case entity.Resolution1080p:
-		return 1920, 1080
-	case entity.Resolution720p:
-		return 1280, 720
-	default:
-		return 1280, 720 // Default to 720p
-	}
+	return resolution.Dimensions()
 }
 
-// Transcode transcodes a video to a specific resolution and fps
+// Transcode transcodes a video to the resolution, codec, fps and rate control of a
+// ladder profile. Two-pass ABR profiles run an analysis pass first.
 func (s *FFmpegService) Transcode(
 	ctx context.Context,
 	inputURL string,
 	outputPath string,
-	resolution entity.Resolution,
-	fps int,
+	profile entity.TranscodeProfile,
+	opts entity.TranscodeOptions,
 ) error {
-	width, height := s.getResolutionParams(resolution)
+	width, height := s.getResolutionParams(profile.Resolution)
+
+	// Scale, tone mapping HDR sources down to SDR if requested
+	videoFilter := fmt.Sprintf("scale=%d:%d", width, height)
+	if opts.ToneMap != nil {
+		videoFilter += "," + toneMapFilter(opts.ToneMap)
+	}
+
+	// Burn captions in at the output size so text is rendered sharp
+	if opts.SubtitlePath != "" {
+		videoFilter += ",subtitles=" + filterPath(opts.SubtitlePath)
+	}
+
+	// The watermark image is a second input blended in by a filter graph
+	inputArgs := []string{"-i", inputURL}
+	videoArgs := []string{
+		"-map", "0:v:0",
+		"-vf", videoFilter,
+	}
+	if opts.Watermark != nil {
+		inputArgs = append(inputArgs, "-i", opts.WatermarkPath)
+		videoArgs = []string{
+			"-filter_complex", watermarkFilter(videoFilter, opts.Watermark, width, opts.HDR != nil),
+			"-map", "[v]",
+		}
+	}
+	videoArgs = append(videoArgs, "-r", strconv.Itoa(profile.FPS))
+	if opts.KeyframeInterval > 0 {
+		videoArgs = append(videoArgs, "-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", opts.KeyframeInterval))
+	}
+
+	// Run the analysis pass, writing statistics next to the output
+	pass := 0
+	passLog := outputPath + ".pass"
+	if profile.RateControl.Mode == entity.RateControlTwoPassABR && s.supportsTwoPass(profile.Codec) {
+		defer removePassLogs(passLog)
+
+		args := append([]string{}, inputArgs...)
+		args = append(args, videoArgs...)
+		args = append(args, s.videoEncoderArgs(profile, opts.HDR, 1, passLog)...)
+		args = append(args,
+			"-an",
+			"-f", "null",
+			"-y",
+			os.DevNull,
+		)
+
+		// Create the command
+		cmd := exec.CommandContext(ctx, s.ffmpegPath, args...)
+
+		// Capture stdout and stderr
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		// Run the command
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("ffmpeg first pass failed: %w", err)
+		}
+
+		pass = 2
+	}
+
+	// Prepare the FFmpeg command
+	args := append([]string{}, inputArgs...)
+	args = append(args, videoArgs...)
+	if !opts.NoAudio {
+		args = append(args, "-map", "0:a:0?") // Default audio track, if the source has one
+	}
+	args = append(args, s.videoEncoderArgs(profile, opts.HDR, pass, passLog)...)
+	if opts.NoAudio {
+		args = append(args, "-an")
+	} else {
+		if opts.Loudness != nil {
+			args = append(args, "-af", loudnormFilter(opts.Loudness))
+		}
+		args = append(args,
+			"-c:a", "aac",
+			"-b:a", "128k",
+		)
+	}
+	args = append(args,
+		"-movflags", "+faststart",
+		"-y", // Overwrite output file if it exists
+		outputPath,
+	)
+
+	// Create the command
+	cmd := exec.CommandContext(ctx, s.ffmpegPath, args...)
+
+	// Capture stdout and stderr
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// Run the command
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg transcode failed: %w", err)
+	}
+
+	return nil
+}
+
+// EncodeSample encodes a short excerpt of the input video with the codec and rate
+// control of a profile and returns the resulting bitrate in bits per second
+func (s *FFmpegService) EncodeSample(
+	ctx context.Context,
+	inputPath string,
+	start float64,
+	duration float64,
+	profile entity.TranscodeProfile,
+	opts entity.TranscodeOptions,
+) (int, error) {
+	output, err := os.CreateTemp("", "sample-*.mp4")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create sample file: %w", err)
+	}
+	outputPath := output.Name()
+	output.Close()
+	defer os.Remove(outputPath)
+
+	width, height := s.getResolutionParams(profile.Resolution)
+	videoFilter := fmt.Sprintf("scale=%d:%d", width, height)
+	if opts.ToneMap != nil {
+		videoFilter += "," + toneMapFilter(opts.ToneMap)
+	}
+
+	// Prepare the FFmpeg command, seeking before the input for speed
+	args := []string{
+		"-ss", strconv.FormatFloat(start, 'f', 3, 64),
+		"-t", strconv.FormatFloat(duration, 'f', 3, 64),
+		"-i", inputPath,
+		"-map", "0:v:0",
+		"-an",
+	}
+	args = append(args, s.videoEncoderArgs(profile, opts.HDR, 0, "")...)
+	args = append(args,
+		"-vf", videoFilter,
+		"-r", strconv.Itoa(profile.FPS),
+		"-y",
+		outputPath,
+	)
+
+	// Create the command
+	cmd := exec.CommandContext(ctx, s.ffmpegPath, args...)
+
+	// Capture stdout and stderr
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// Run the command
+	if err := cmd.Run(); err != nil {
+		return 0, fmt.Errorf("ffmpeg sample encode failed: %w", err)
+	}
+
+	// The excerpt may be shorter than requested near the end of the video
+	encoded, _, _, err := s.GetVideoInfo(ctx, outputPath)
+	if err != nil {
+		return 0, err
+	}
+	if encoded <= 0 {
+		return 0, fmt.Errorf("sample encode is empty")
+	}
 
+	stat, err := os.Stat(outputPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat sample file: %w", err)
+	}
+
+	return int(float64(stat.Size()*8) / encoded), nil
+}
+
+// TranscodeAudio encodes a single audio stream of the input into an AAC audio-only file
+func (s *FFmpegService) TranscodeAudio(
+	ctx context.Context,
+	inputPath string,
+	outputPath string,
+	streamIndex int,
+	bitrate int,
+	opts entity.TranscodeOptions,
+) error {
 	// Prepare the FFmpeg command
 	args := []string{
-		"-i", inputURL,
-		"-c:v", "libx264",
-		"-vf", fmt.Sprintf("scale=%d:%d", width, height),
-		"-r", strconv.Itoa(fps),
+		"-i", inputPath,
+		"-map", fmt.Sprintf("0:a:%d", streamIndex),
+		"-vn",
+	}
+	if opts.Loudness != nil {
+		args = append(args, "-af", loudnormFilter(opts.Loudness))
+	}
+	args = append(args,
 		"-c:a", "aac",
-		"-b:a", "128k",
+		"-b:a", strconv.Itoa(bitrate),
 		"-movflags", "+faststart",
-		"-y", // Overwrite output file if it exists
+		"-y",
+		outputPath,
+	)
+
+	// Create the command
+	cmd := exec.CommandContext(ctx, s.ffmpegPath, args...)
+
+	// Capture stdout and stderr
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// Run the command
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg audio transcode failed: %w", err)
+	}
+
+	return nil
+}
+
+// MuxAudio combines a video-only file and an audio-only file into a faststart MP4
+// without re-encoding either
+func (s *FFmpegService) MuxAudio(
+	ctx context.Context,
+	videoPath string,
+	audioPath string,
+	outputPath string,
+) error {
+	// Prepare the FFmpeg command
+	args := []string{
+		"-i", videoPath,
+		"-i", audioPath,
+		"-map", "0:v:0",
+		"-map", "1:a:0",
+		"-c", "copy", // Copy without re-encoding
+		"-movflags", "+faststart",
+		"-y",
 		outputPath,
 	}
 
@@ -71,7 +287,108 @@ func (s *FFmpegService) Transcode(
 
 	// Run the command
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("ffmpeg transcode failed: %w", err)
+		return fmt.Errorf("ffmpeg mux failed: %w", err)
+	}
+
+	return nil
+}
+
+// MeasureLoudness runs the first loudnorm pass over an audio stream and returns
+// the measured EBU R128 values needed to normalize it linearly in a second pass
+func (s *FFmpegService) MeasureLoudness(
+	ctx context.Context,
+	inputPath string,
+	streamIndex int,
+	target entity.LoudnessTarget,
+) (*entity.LoudnessMeasurement, error) {
+	// Prepare the FFmpeg command; loudnorm prints its analysis as JSON on stderr
+	args := []string{
+		"-hide_banner",
+		"-nostats",
+		"-i", inputPath,
+		"-map", fmt.Sprintf("0:a:%d", streamIndex),
+		"-af", fmt.Sprintf(
+			"loudnorm=I=%.1f:TP=%.1f:LRA=%.1f:print_format=json",
+			target.IntegratedLUFS, target.TruePeak, target.LoudnessRange,
+		),
+		"-f", "null",
+		"-",
+	}
+
+	// Create the command
+	cmd := exec.CommandContext(ctx, s.ffmpegPath, args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	// Run the command
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg loudness analysis failed: %w", err)
+	}
+
+	// The analysis is the last JSON object in the output
+	output := stderr.String()
+	start := strings.LastIndex(output, "{")
+	end := strings.LastIndex(output, "}")
+	if start == -1 || end < start {
+		return nil, fmt.Errorf("loudness analysis not found in ffmpeg output")
+	}
+
+	var values map[string]string
+	if err := json.Unmarshal([]byte(output[start:end+1]), &values); err != nil {
+		return nil, fmt.Errorf("failed to parse loudness analysis: %w", err)
+	}
+
+	measurement := &entity.LoudnessMeasurement{StreamIndex: streamIndex}
+	fields := map[string]*float64{
+		"input_i":       &measurement.IntegratedLUFS,
+		"input_tp":      &measurement.TruePeak,
+		"input_lra":     &measurement.LoudnessRange,
+		"input_thresh":  &measurement.Threshold,
+		"target_offset": &measurement.TargetOffset,
+	}
+	for key, field := range fields {
+		value, err := strconv.ParseFloat(values[key], 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", key, err)
+		}
+		// Silent streams measure as -inf and cannot be normalized
+		if math.IsInf(value, 0) || math.IsNaN(value) {
+			return nil, fmt.Errorf("audio stream %d is silent", streamIndex)
+		}
+		*field = value
+	}
+
+	return measurement, nil
+}
+
+// ExtractSubtitle extracts a text subtitle stream of the input as a WebVTT file
+func (s *FFmpegService) ExtractSubtitle(
+	ctx context.Context,
+	inputPath string,
+	outputPath string,
+	streamIndex int,
+) error {
+	// Prepare the FFmpeg command
+	args := []string{
+		"-i", inputPath,
+		"-map", fmt.Sprintf("0:s:%d", streamIndex),
+		"-c:s", "webvtt",
+		"-f", "webvtt",
+		"-y",
+		outputPath,
+	}
+
+	// Create the command
+	cmd := exec.CommandContext(ctx, s.ffmpegPath, args...)
+
+	// Capture stdout and stderr
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// Run the command
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg subtitle extraction failed: %w", err)
 	}
 
 	return nil
@@ -125,6 +442,54 @@ func (s *FFmpegService) Segment(
 	return matches, nil
 }
 
+// SegmentFMP4 segments a video into fragmented MP4 HLS segments, as required for
+// codecs such as HEVC. It returns the initialization segment and the media segments.
+func (s *FFmpegService) SegmentFMP4(
+	ctx context.Context,
+	videoPath string,
+	segmentDuration int,
+	outputDir string,
+) (string, []string, error) {
+	// Create the output directory if it doesn't exist
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	// Prepare the FFmpeg command for segmenting
+	args := []string{
+		"-i", videoPath,
+		"-c", "copy", // Copy without re-encoding
+		"-map", "0",
+		"-f", "hls",
+		"-hls_time", strconv.Itoa(segmentDuration),
+		"-hls_playlist_type", "vod",
+		"-hls_segment_type", "fmp4",
+		"-hls_fmp4_init_filename", "init.mp4",
+		"-hls_segment_filename", filepath.Join(outputDir, "segment_%03d.m4s"),
+		filepath.Join(outputDir, "playlist.m3u8"),
+	}
+
+	// Create the command
+	cmd := exec.CommandContext(ctx, s.ffmpegPath, args...)
+
+	// Capture stdout and stderr
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// Run the command
+	if err := cmd.Run(); err != nil {
+		return "", nil, fmt.Errorf("ffmpeg fmp4 segment failed: %w", err)
+	}
+
+	// Get list of segment files
+	matches, err := filepath.Glob(filepath.Join(outputDir, "segment_*.m4s"))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to list segment files: %w", err)
+	}
+
+	return filepath.Join(outputDir, "init.mp4"), matches, nil
+}
+
 // GetVideoInfo gets information about a video file
 func (s *FFmpegService) GetVideoInfo(
 	ctx context.Context,
@@ -187,3 +552,375 @@ func (s *FFmpegService) GetVideoInfo(
 
 	return duration, width, height, nil
 }
+
+// probeOutput mirrors the subset of ffprobe's JSON output used by Probe
+type probeOutput struct {
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+	Streams []struct {
+		CodecType   string            `json:"codec_type"`
+		CodecName   string            `json:"codec_name"`
+		Profile     string            `json:"profile"`
+		Level       int               `json:"level"`
+		PixFmt      string            `json:"pix_fmt"`
+		Width       int               `json:"width"`
+		Height      int               `json:"height"`
+		Channels    int               `json:"channels"`
+		ColorTrc    string            `json:"color_transfer"`
+		ColorPrim   string            `json:"color_primaries"`
+		ColorSpace  string            `json:"color_space"`
+		Tags        map[string]string `json:"tags"`
+		Disposition map[string]int    `json:"disposition"`
+	} `json:"streams"`
+}
+
+// probe runs ffprobe on a media file and parses its JSON output
+func (s *FFmpegService) probe(ctx context.Context, videoPath string) (*probeOutput, error) {
+	// Prepare the FFprobe command
+	args := []string{
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		videoPath,
+	}
+
+	// Execute the command
+	cmd := exec.CommandContext(ctx, s.ffprobePath, args...)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	// Parse the output
+	var probe probeOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	return &probe, nil
+}
+
+// Probe gets detailed stream information about a media file
+func (s *FFmpegService) Probe(ctx context.Context, videoPath string) (*entity.MediaInfo, error) {
+	probe, err := s.probe(ctx, videoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &entity.MediaInfo{}
+	if probe.Format.Duration != "" {
+		info.Duration, err = strconv.ParseFloat(probe.Format.Duration, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse duration: %w", err)
+		}
+	}
+
+	videoFound := false
+	for _, stream := range probe.Streams {
+		switch stream.CodecType {
+		case "video":
+			// Skip cover art and only use the first real video stream
+			if videoFound || stream.Disposition["attached_pic"] == 1 {
+				continue
+			}
+			videoFound = true
+			info.Width = stream.Width
+			info.Height = stream.Height
+			info.Color = entity.ColorInfo{
+				Transfer:  stream.ColorTrc,
+				Primaries: stream.ColorPrim,
+				Matrix:    stream.ColorSpace,
+			}
+		case "audio":
+			info.AudioStreams = append(info.AudioStreams, entity.AudioStream{
+				Index:    len(info.AudioStreams),
+				Codec:    stream.CodecName,
+				Language: stream.Tags["language"],
+				Title:    stream.Tags["title"],
+				Channels: stream.Channels,
+				Default:  stream.Disposition["default"] == 1,
+			})
+		case "subtitle":
+			info.SubtitleStreams = append(info.SubtitleStreams, entity.SubtitleStream{
+				Index:    len(info.SubtitleStreams),
+				Codec:    stream.CodecName,
+				Language: stream.Tags["language"],
+				Title:    stream.Tags["title"],
+				Default:  stream.Disposition["default"] == 1,
+			})
+		}
+	}
+
+	return info, nil
+}
+
+// loudnormFilter builds the second-pass loudnorm filter from first-pass measurements.
+// loudnorm resamples to 192 kHz internally, so the output is resampled back to 48 kHz.
+func loudnormFilter(n *entity.LoudnessNormalization) string {
+	return fmt.Sprintf(
+		"loudnorm=I=%.1f:TP=%.1f:LRA=%.1f:measured_I=%.2f:measured_TP=%.2f:measured_LRA=%.2f:measured_thresh=%.2f:offset=%.2f:linear=true,aresample=48000",
+		n.Target.IntegratedLUFS,
+		n.Target.TruePeak,
+		n.Target.LoudnessRange,
+		n.Measured.IntegratedLUFS,
+		n.Measured.TruePeak,
+		n.Measured.LoudnessRange,
+		n.Measured.Threshold,
+		n.Measured.TargetOffset,
+	)
+}
+
+// watermarkFilter builds a filter graph applying videoFilter to the first input and
+// overlaying the second input as a watermark. The image and margin are sized for a
+// 1080p frame and scaled to the output width.
+func watermarkFilter(videoFilter string, w *entity.Watermark, width int, tenBit bool) string {
+	scale := float64(width) / 1920
+	margin := int(math.Round(float64(w.Margin) * scale))
+
+	var x, y string
+	switch w.Position {
+	case entity.WatermarkTopLeft:
+		x, y = strconv.Itoa(margin), strconv.Itoa(margin)
+	case entity.WatermarkTopRight:
+		x, y = fmt.Sprintf("main_w-overlay_w-%d", margin), strconv.Itoa(margin)
+	case entity.WatermarkBottomLeft:
+		x, y = strconv.Itoa(margin), fmt.Sprintf("main_h-overlay_h-%d", margin)
+	case entity.WatermarkCenter:
+		x, y = "(main_w-overlay_w)/2", "(main_h-overlay_h)/2"
+	default:
+		x, y = fmt.Sprintf("main_w-overlay_w-%d", margin), fmt.Sprintf("main_h-overlay_h-%d", margin)
+	}
+
+	// Keep HDR renditions at 10 bits through the overlay
+	format := "yuv420"
+	if tenBit {
+		format = "yuv420p10"
+	}
+
+	return fmt.Sprintf(
+		"[0:v:0]%s[base];[1:v]format=rgba,colorchannelmixer=aa=%.2f,scale=iw*%.4f:-1[wm];[base][wm]overlay=%s:%s:format=%s[v]",
+		videoFilter, w.Opacity, scale, x, y, format,
+	)
+}
+
+// filterPath escapes a file path for use as a filter option inside a filter graph
+func filterPath(path string) string {
+	// Escape for the filter option parser, then for the filter graph parser
+	path = strings.NewReplacer(`\`, `\\`, `:`, `\:`, `'`, `\'`).Replace(path)
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`, `[`, `\[`, `]`, `\]`, `,`, `\,`, `;`, `\;`).Replace(path)
+}
+
+// toneMapFilter builds a zscale/tonemap chain converting HDR video with the given
+// characteristics to 8-bit SDR BT.709
+func toneMapFilter(c *entity.ColorInfo) string {
+	// Tell zscale what the input is rather than relying on frame properties
+	input := []string{"t=linear", "npl=100"}
+	if c.Transfer != "" {
+		input = append([]string{"tin=" + c.Transfer}, input...)
+	}
+	if c.Primaries != "" {
+		input = append(input, "pin="+c.Primaries)
+	}
+	if c.Matrix != "" {
+		input = append(input, "min="+c.Matrix)
+	}
+
+	return strings.Join([]string{
+		"zscale=" + strings.Join(input, ":"),
+		"format=gbrpf32le",
+		"zscale=p=bt709",
+		"tonemap=tonemap=hable:desat=0",
+		"zscale=t=bt709:m=bt709:r=tv",
+		"format=yuv420p",
+	}, ",")
+}
+
+// videoEncoderArgs returns the encoder and rate control arguments of a profile. When
+// hdr is set the encode is 10-bit and keeps the source's HDR transfer function. A
+// non-zero pass selects the pass of a two-pass encode logging to passLog.
+func (s *FFmpegService) videoEncoderArgs(
+	profile entity.TranscodeProfile,
+	hdr *entity.ColorInfo,
+	pass int,
+	passLog string,
+) []string {
+	rc := profile.RateControl
+
+	switch profile.Codec {
+	case entity.CodecHEVC:
+		args := []string{
+			"-c:v", "libx265",
+			"-tag:v", "hvc1", // Required by Apple players for HEVC in MP4
+		}
+		args = append(args, x26xRateControlArgs(rc)...)
+
+		// libx265 takes multi-pass and HDR settings through its own parameter list
+		var params []string
+		if hdr != nil {
+			args = append(args, hdrColorArgs(hdr)...)
+			params = append(params, hdrX265Params(hdr)...)
+		}
+		if pass > 0 {
+			params = append(params, fmt.Sprintf("pass=%d", pass), "stats="+passLog)
+		}
+		if len(params) > 0 {
+			args = append(args, "-x265-params", strings.Join(params, ":"))
+		}
+		return args
+	case entity.CodecVP9:
+		args := []string{
+			"-c:v", "libvpx-vp9",
+			"-row-mt", "1",
+			"-deadline", "good",
+			"-cpu-used", valueOrDefault(rc.Preset, "2"),
+		}
+		args = append(args, vpxRateControlArgs(rc)...)
+		return append(args, passArgs(pass, passLog)...)
+	case entity.CodecAV1:
+		if s.av1Encoder == "libaom-av1" {
+			args := []string{
+				"-c:v", "libaom-av1",
+				"-row-mt", "1",
+				"-cpu-used", valueOrDefault(rc.Preset, "6"),
+			}
+			args = append(args, vpxRateControlArgs(rc)...)
+			return append(args, passArgs(pass, passLog)...)
+		}
+
+		args := []string{
+			"-c:v", "libsvtav1",
+			"-preset", valueOrDefault(rc.Preset, "8"),
+		}
+		switch rc.Mode {
+		case entity.RateControlTwoPassABR:
+			// FFmpeg's SVT-AV1 wrapper has no multi-pass support, so this is single-pass VBR
+			args = append(args, "-b:v", strconv.Itoa(rc.Bitrate))
+		case entity.RateControlCappedCRF:
+			args = append(args, "-crf", strconv.Itoa(rc.CRF), "-maxrate", strconv.Itoa(rc.MaxBitrate))
+		default:
+			args = append(args, "-crf", strconv.Itoa(valueOrDefaultInt(rc.CRF, 35)))
+		}
+		return args
+	default:
+		args := []string{"-c:v", "libx264"}
+		args = append(args, x26xRateControlArgs(rc)...)
+		return append(args, passArgs(pass, passLog)...)
+	}
+}
+
+// supportsTwoPass reports whether the encoder used for a codec can run a two-pass encode
+func (s *FFmpegService) supportsTwoPass(codec entity.VideoCodec) bool {
+	return codec != entity.CodecAV1 || s.av1Encoder == "libaom-av1"
+}
+
+// x26xRateControlArgs returns the rate control arguments shared by libx264 and libx265
+func x26xRateControlArgs(rc entity.RateControl) []string {
+	var args []string
+	switch rc.Mode {
+	case entity.RateControlTwoPassABR:
+		args = append(args, "-b:v", strconv.Itoa(rc.Bitrate))
+	case entity.RateControlCappedCRF:
+		args = append(args,
+			"-crf", strconv.Itoa(rc.CRF),
+			"-maxrate", strconv.Itoa(rc.MaxBitrate),
+			"-bufsize", strconv.Itoa(rc.BufferSize),
+		)
+	default:
+		if rc.CRF > 0 {
+			args = append(args, "-crf", strconv.Itoa(rc.CRF))
+		}
+	}
+
+	if rc.Preset != "" {
+		args = append(args, "-preset", rc.Preset)
+	}
+	if rc.Tune != "" {
+		args = append(args, "-tune", rc.Tune)
+	}
+
+	return args
+}
+
+// vpxRateControlArgs returns the rate control arguments of libvpx-vp9 and libaom-av1,
+// where -b:v caps a constant quality encode and zero leaves it unconstrained
+func vpxRateControlArgs(rc entity.RateControl) []string {
+	switch rc.Mode {
+	case entity.RateControlTwoPassABR:
+		return []string{"-b:v", strconv.Itoa(rc.Bitrate)}
+	case entity.RateControlCappedCRF:
+		return []string{
+			"-crf", strconv.Itoa(rc.CRF),
+			"-b:v", strconv.Itoa(rc.MaxBitrate),
+			"-bufsize", strconv.Itoa(rc.BufferSize),
+		}
+	default:
+		return []string{
+			"-crf", strconv.Itoa(valueOrDefaultInt(rc.CRF, 31)),
+			"-b:v", "0",
+		}
+	}
+}
+
+// passArgs returns the generic FFmpeg arguments selecting a pass of a two-pass encode
+func passArgs(pass int, passLog string) []string {
+	if pass == 0 {
+		return nil
+	}
+	return []string{
+		"-pass", strconv.Itoa(pass),
+		"-passlogfile", passLog,
+	}
+}
+
+// removePassLogs deletes the statistics files written by a two-pass encode
+func removePassLogs(passLog string) {
+	matches, _ := filepath.Glob(passLog + "*")
+	for _, match := range matches {
+		_ = os.Remove(match)
+	}
+}
+
+// hdrX265Params returns libx265 parameters signalling the source's HDR transfer
+// function in the bitstream
+func hdrX265Params(c *entity.ColorInfo) []string {
+	params := []string{
+		"repeat-headers=1",
+		"colorprim=" + valueOrDefault(c.Primaries, "bt2020"),
+		"transfer=" + c.Transfer,
+		"colormatrix=" + valueOrDefault(c.Matrix, "bt2020nc"),
+	}
+	if c.HDRFormat() == "PQ" {
+		params = append(params, "hdr10=1", "hdr10-opt=1")
+	}
+
+	return params
+}
+
+// hdrColorArgs returns arguments for a 10-bit encode tagged with the source's HDR
+// color characteristics
+func hdrColorArgs(c *entity.ColorInfo) []string {
+	return []string{
+		"-pix_fmt", "yuv420p10le",
+		"-color_primaries", valueOrDefault(c.Primaries, "bt2020"),
+		"-color_trc", c.Transfer,
+		"-colorspace", valueOrDefault(c.Matrix, "bt2020nc"),
+	}
+}
+
+// valueOrDefault returns value, or fallback when value is empty
+func valueOrDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// valueOrDefaultInt returns value, or fallback when value is zero
+func valueOrDefaultInt(value, fallback int) int {
+	if value == 0 {
+		return fallback
+	}
+	return value
+}
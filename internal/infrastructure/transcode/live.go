@@ -0,0 +1,109 @@
+package transcode
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+)
+
+// liveAudioBitrate is the AAC bitrate of each live rendition
+const liveAudioBitrate = 128000
+
+// TranscodeLive encodes an FLV stream read from input into an HLS ladder while
+// it is being published. Each rendition is written to outputDir/<name>/ as
+// segment_NNNNN.ts files listed in playlist.m3u8, which FFmpeg rewrites as each
+// segment completes. With a part duration, the files are part_NNNNN.ts parts
+// that each start with a keyframe, to be joined into segments by the caller.
+// It returns once the input ends and every file is written.
+func (s *FFmpegService) TranscodeLive(
+	ctx context.Context,
+	input io.Reader,
+	outputDir string,
+	ladder []entity.TranscodeProfile,
+	opts entity.LiveOptions,
+) error {
+	if len(ladder) == 0 {
+		return fmt.Errorf("live ladder is empty")
+	}
+
+	// Decode once and scale the picture for every rendition
+	outputs := make([]string, len(ladder))
+	filters := make([]string, 0, len(ladder)+1)
+	for i := range ladder {
+		outputs[i] = fmt.Sprintf("[v%d]", i)
+	}
+	filters = append(filters, fmt.Sprintf("[0:v]split=%d%s", len(ladder), strings.Join(outputs, "")))
+	for i, profile := range ladder {
+		width, height := s.getResolutionParams(profile.Resolution)
+		filters = append(filters, fmt.Sprintf("[v%d]scale=%d:%d[out%d]", i, width, height, i))
+	}
+
+	args := []string{
+		"-f", "flv",
+		"-i", "pipe:0",
+		"-filter_complex", strings.Join(filters, ";"),
+	}
+
+	// Low-latency parts are cut like short segments
+	fileDuration, filePattern := opts.SegmentDuration, "segment_%05d.ts"
+	if opts.PartDuration > 0 {
+		fileDuration, filePattern = opts.PartDuration, "part_%05d.ts"
+	}
+	duration := strconv.FormatFloat(fileDuration, 'f', -1, 64)
+	for i, profile := range ladder {
+		dir := filepath.Join(outputDir, string(profile.Name))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+
+		args = append(args, "-map", fmt.Sprintf("[out%d]", i), "-map", "0:a?")
+		args = append(args, s.videoEncoderArgs(profile, nil, 0, "")...)
+		args = append(args,
+			// Keyframes at every file boundary keep the renditions aligned
+			"-force_key_frames", "expr:gte(t,n_forced*"+duration+")",
+			"-sc_threshold", "0",
+			"-c:a", "aac",
+			"-b:a", strconv.Itoa(liveAudioBitrate),
+			"-ac", "2",
+			"-f", "hls",
+			"-hls_time", duration,
+			"-hls_list_size", "0",
+			"-hls_flags", "independent_segments+temp_file",
+			"-hls_segment_filename", filepath.Join(dir, filePattern),
+			filepath.Join(dir, "playlist.m3u8"),
+		)
+	}
+
+	// Keep the stream as published for the recording
+	if opts.RecordingPath != "" {
+		args = append(args,
+			"-map", "0:v",
+			"-map", "0:a?",
+			"-c", "copy",
+			"-f", "mpegts",
+			opts.RecordingPath,
+		)
+	}
+
+	// Create the command
+	cmd := exec.CommandContext(ctx, s.ffmpegPath, args...)
+	cmd.Stdin = input
+
+	// Capture stdout and stderr
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// Run the command
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg live transcode failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+)
+
+// PerceptualHash samples evenly spaced frames of a video and returns their
+// difference hashes, which survive re-encoding, scaling and small color changes.
+// Each frame is shrunk to 9x8 grey pixels and every bit records whether a pixel is
+// darker than its right neighbour.
+func (s *FFmpegService) PerceptualHash(ctx context.Context, videoPath string, duration float64) (string, error) {
+	if duration <= 0 {
+		return "", fmt.Errorf("cannot hash a video without a duration")
+	}
+
+	rate := strconv.FormatFloat(entity.PerceptualHashFrames/duration, 'f', 6, 64)
+
+	// Prepare the FFmpeg command, writing raw grey frames to stdout
+	args := []string{
+		"-hide_banner",
+		"-nostats",
+		"-i", videoPath,
+		"-map", "0:v:0",
+		"-vf", fmt.Sprintf("fps=%s,scale=9:8:flags=area,format=gray", rate),
+		"-frames:v", strconv.Itoa(entity.PerceptualHashFrames),
+		"-f", "rawvideo",
+		"-",
+	}
+
+	// Create the command
+	cmd := exec.CommandContext(ctx, s.ffmpegPath, args...)
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	// Run the command
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("ffmpeg frame sampling failed: %w", err)
+	}
+
+	const frameSize = 9 * 8
+	frames := stdout.Bytes()
+	if len(frames) < frameSize {
+		return "", fmt.Errorf("no frames sampled from %s", videoPath)
+	}
+
+	var hash strings.Builder
+	for offset := 0; offset+frameSize <= len(frames); offset += frameSize {
+		frame := frames[offset : offset+frameSize]
+
+		var bits uint64
+		for y := 0; y < 8; y++ {
+			for x := 0; x < 8; x++ {
+				bits <<= 1
+				if frame[y*9+x] < frame[y*9+x+1] {
+					bits |= 1
+				}
+			}
+		}
+		hash.WriteString(fmt.Sprintf("%016x", bits))
+	}
+
+	return hash.String(), nil
+}
@@ -0,0 +1,126 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"regexp"
+	"strconv"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+)
+
+// Detection settings. Short defects are normal in edited content, so only
+// stretches of at least a few seconds are reported.
+const (
+	blackDetectFilter   = "blackdetect=d=1:pix_th=0.10"
+	freezeDetectFilter  = "freezedetect=n=-60dB:d=2"
+	silenceDetectFilter = "silencedetect=n=-50dB:d=2"
+)
+
+// Patterns of the detection filter log lines
+var (
+	blackLine        = regexp.MustCompile(`black_start:\s*([0-9.]+)\s+black_end:\s*([0-9.]+)\s+black_duration:\s*([0-9.]+)`)
+	freezeStartLine  = regexp.MustCompile(`freeze_start:\s*([0-9.]+)`)
+	freezeEndLine    = regexp.MustCompile(`freeze_end:\s*([0-9.]+)`)
+	silenceStartLine = regexp.MustCompile(`silence_start:\s*(-?[0-9.]+)`)
+	silenceEndLine   = regexp.MustCompile(`silence_end:\s*([0-9.]+)`)
+)
+
+// DetectDefects finds black frames, frozen frames and, when the video has audio,
+// silence in a single decoding pass. Defects running to the end of the video are
+// closed at duration.
+func (s *FFmpegService) DetectDefects(
+	ctx context.Context,
+	videoPath string,
+	duration float64,
+	audio bool,
+) (*entity.QCReport, error) {
+	// Prepare the FFmpeg command; the detection filters log to stderr
+	args := []string{
+		"-hide_banner",
+		"-nostats",
+		"-i", videoPath,
+		"-map", "0:v:0",
+		"-vf", blackDetectFilter + "," + freezeDetectFilter,
+	}
+	if audio {
+		args = append(args,
+			"-map", "0:a:0",
+			"-af", silenceDetectFilter,
+		)
+	}
+	args = append(args,
+		"-f", "null",
+		"-",
+	)
+
+	// Create the command
+	cmd := exec.CommandContext(ctx, s.ffmpegPath, args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	// Run the command
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg defect detection failed: %w", err)
+	}
+
+	report := &entity.QCReport{
+		Black:   []entity.QCInterval{},
+		Freeze:  []entity.QCInterval{},
+		Silence: []entity.QCInterval{},
+	}
+
+	freezeStart, silenceStart := -1.0, -1.0
+	for _, line := range bytes.Split(stderr.Bytes(), []byte("\n")) {
+		text := string(line)
+
+		if m := blackLine.FindStringSubmatch(text); m != nil {
+			report.Black = append(report.Black, entity.QCInterval{
+				Start:    parseSeconds(m[1]),
+				End:      parseSeconds(m[2]),
+				Duration: parseSeconds(m[3]),
+			})
+		}
+
+		if m := freezeStartLine.FindStringSubmatch(text); m != nil {
+			freezeStart = parseSeconds(m[1])
+		}
+		if m := freezeEndLine.FindStringSubmatch(text); m != nil && freezeStart >= 0 {
+			report.Freeze = append(report.Freeze, interval(freezeStart, parseSeconds(m[1])))
+			freezeStart = -1
+		}
+
+		if m := silenceStartLine.FindStringSubmatch(text); m != nil {
+			silenceStart = math.Max(0, parseSeconds(m[1]))
+		}
+		if m := silenceEndLine.FindStringSubmatch(text); m != nil && silenceStart >= 0 {
+			report.Silence = append(report.Silence, interval(silenceStart, parseSeconds(m[1])))
+			silenceStart = -1
+		}
+	}
+
+	// Older builds don't report defects that last until the end of the input
+	if freezeStart >= 0 {
+		report.Freeze = append(report.Freeze, interval(freezeStart, duration))
+	}
+	if silenceStart >= 0 {
+		report.Silence = append(report.Silence, interval(silenceStart, duration))
+	}
+
+	return report, nil
+}
+
+// interval builds a QC interval from its start and end times
+func interval(start, end float64) entity.QCInterval {
+	return entity.QCInterval{Start: start, End: end, Duration: end - start}
+}
+
+// parseSeconds parses a time in seconds, returning 0 for malformed values
+func parseSeconds(value string) float64 {
+	seconds, _ := strconv.ParseFloat(value, 64)
+	return seconds
+}
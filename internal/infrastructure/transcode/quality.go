@@ -0,0 +1,104 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"regexp"
+	"strconv"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+)
+
+// maxPSNR replaces the infinite PSNR ffmpeg reports for identical frames
+const maxPSNR = 100
+
+// Summary lines printed by the libvmaf, psnr and ssim filters
+var (
+	vmafScorePattern = regexp.MustCompile(`VMAF score: ([0-9.]+)`)
+	psnrScorePattern = regexp.MustCompile(`PSNR .*average:([0-9.]+|inf)`)
+	ssimScorePattern = regexp.MustCompile(`SSIM .*All:([0-9.]+)`)
+)
+
+// MeasureQuality compares an encoded rendition to its source with the libvmaf, psnr
+// and ssim filters. The source is scaled, resampled and tone mapped the same way
+// as the rendition so both inputs match frame for frame.
+func (s *FFmpegService) MeasureQuality(
+	ctx context.Context,
+	renditionPath string,
+	sourcePath string,
+	profile entity.TranscodeProfile,
+	opts entity.TranscodeOptions,
+) (*entity.QualityScore, error) {
+	width, height := s.getResolutionParams(profile.Resolution)
+
+	pixFmt := "yuv420p"
+	if opts.HDR != nil {
+		pixFmt = "yuv420p10le"
+	}
+
+	reference := fmt.Sprintf("scale=%d:%d:flags=bicubic,fps=%d", width, height, profile.FPS)
+	if opts.ToneMap != nil {
+		reference += "," + toneMapFilter(opts.ToneMap)
+	}
+
+	filter := fmt.Sprintf(
+		"[0:v]format=%[1]s,setpts=PTS-STARTPTS,split=3[d0][d1][d2];"+
+			"[1:v]%[2]s,format=%[1]s,setpts=PTS-STARTPTS,split=3[r0][r1][r2];"+
+			"[d0][r0]libvmaf=n_threads=4;[d1][r1]psnr;[d2][r2]ssim",
+		pixFmt, reference,
+	)
+
+	// Prepare the FFmpeg command; the filters print their summaries on stderr
+	args := []string{
+		"-hide_banner",
+		"-nostats",
+		"-i", renditionPath,
+		"-i", sourcePath,
+		"-lavfi", filter,
+		"-f", "null",
+		"-",
+	}
+
+	// Create the command
+	cmd := exec.CommandContext(ctx, s.ffmpegPath, args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	// Run the command
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg quality analysis failed: %w", err)
+	}
+
+	output := stderr.String()
+	score := &entity.QualityScore{}
+	metrics := []struct {
+		name    string
+		pattern *regexp.Regexp
+		value   *float64
+	}{
+		{"VMAF", vmafScorePattern, &score.VMAF},
+		{"PSNR", psnrScorePattern, &score.PSNR},
+		{"SSIM", ssimScorePattern, &score.SSIM},
+	}
+	for _, metric := range metrics {
+		match := metric.pattern.FindStringSubmatch(output)
+		if match == nil {
+			return nil, fmt.Errorf("%s score not found in ffmpeg output", metric.name)
+		}
+
+		value, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s score: %w", metric.name, err)
+		}
+		if math.IsInf(value, 1) {
+			value = maxPSNR
+		}
+		*metric.value = value
+	}
+
+	return score, nil
+}
@@ -0,0 +1,62 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// showinfoTime matches the presentation time printed by showinfo for each frame
+var showinfoTime = regexp.MustCompile(`pts_time:\s*([0-9.]+)`)
+
+// DetectScenes returns the times in seconds of frames whose scene change score
+// exceeds threshold (0 to 1), found with the select and showinfo filters
+func (s *FFmpegService) DetectScenes(ctx context.Context, videoPath string, threshold float64) ([]float64, error) {
+	// Score small frames, which is much faster and barely changes the result
+	filter := fmt.Sprintf("scale=320:-2,select='gt(scene,%.3f)',showinfo", threshold)
+
+	// Prepare the FFmpeg command; showinfo reports selected frames on stderr
+	args := []string{
+		"-hide_banner",
+		"-nostats",
+		"-i", videoPath,
+		"-map", "0:v:0",
+		"-vf", filter,
+		"-an",
+		"-f", "null",
+		"-",
+	}
+
+	// Create the command
+	cmd := exec.CommandContext(ctx, s.ffmpegPath, args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	// Run the command
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg scene detection failed: %w", err)
+	}
+
+	var scenes []float64
+	for _, line := range strings.Split(stderr.String(), "\n") {
+		if !strings.Contains(line, "Parsed_showinfo") {
+			continue
+		}
+		m := showinfoTime.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		t, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			continue
+		}
+		scenes = append(scenes, t)
+	}
+
+	return scenes, nil
+}
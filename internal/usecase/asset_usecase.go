@@ -0,0 +1,149 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+	"cams.dev/video_upload_backend/internal/domain/repository"
+)
+
+// downloadURLExpiry is how long a presigned download URL stays valid
+const downloadURLExpiry = time.Hour
+
+// unsafeFileNameChars matches characters replaced in suggested download file names
+var unsafeFileNameChars = regexp.MustCompile(`[^\p{L}\p{N}._-]+`)
+
+// AssetUseCase handles downloadable assets derived from videos
+type AssetUseCase struct {
+	videoRepo        repository.VideoRepository
+	captionRepo      repository.CaptionRepository
+	assetRepo        repository.AssetRepository
+	storageRepo      repository.StorageRepository
+	transcodeUseCase *TranscodeUseCase
+}
+
+// NewAssetUseCase creates a new asset use case instance
+func NewAssetUseCase(
+	videoRepo repository.VideoRepository,
+	captionRepo repository.CaptionRepository,
+	assetRepo repository.AssetRepository,
+	storageRepo repository.StorageRepository,
+	transcodeUseCase *TranscodeUseCase,
+) *AssetUseCase {
+	return &AssetUseCase{
+		videoRepo:        videoRepo,
+		captionRepo:      captionRepo,
+		assetRepo:        assetRepo,
+		storageRepo:      storageRepo,
+		transcodeUseCase: transcodeUseCase,
+	}
+}
+
+// BurnedSubtitlesInput represents input data for burning a caption track into a video
+type BurnedSubtitlesInput struct {
+	VideoID    string
+	UserID     string
+	CaptionID  string
+	Resolution entity.Resolution // Defaults to 1080p
+}
+
+// CreateBurnedSubtitles starts encoding an MP4 of the video with the caption track
+// burned in. The asset appears on the video once the background encode finishes.
+func (uc *AssetUseCase) CreateBurnedSubtitles(ctx context.Context, input BurnedSubtitlesInput) error {
+	video, err := uc.videoRepo.GetByID(ctx, input.VideoID)
+	if err != nil {
+		return err
+	}
+	if video.UserID != input.UserID {
+		return fmt.Errorf("forbidden: video %s belongs to another user", video.ID)
+	}
+	if video.Status != entity.StatusComplete {
+		return fmt.Errorf("invalid video: %s has not finished processing", video.ID)
+	}
+
+	caption, err := uc.captionRepo.GetByID(ctx, input.CaptionID)
+	if err != nil {
+		return err
+	}
+	if caption.VideoID != video.ID {
+		return fmt.Errorf("caption with ID %s not found", input.CaptionID)
+	}
+
+	resolution := input.Resolution
+	if resolution == "" {
+		resolution = entity.Resolution1080p
+	}
+	if resolution != entity.Resolution1080p && resolution != entity.Resolution720p {
+		return fmt.Errorf("invalid resolution: %s", resolution)
+	}
+
+	go func() {
+		// Create a new context since the request context will be cancelled
+		bgCtx := context.Background()
+
+		if _, err := uc.transcodeUseCase.BurnSubtitles(bgCtx, video, caption, resolution); err != nil {
+			fmt.Printf("Failed to burn in subtitles for video %s: %v\n", video.ID, err)
+		}
+	}()
+
+	return nil
+}
+
+// ListAssets retrieves the downloadable assets of a video
+func (uc *AssetUseCase) ListAssets(ctx context.Context, videoID string) ([]*entity.Asset, error) {
+	return uc.assetRepo.GetByVideoID(ctx, videoID)
+}
+
+// Download is a presigned URL for downloading an asset
+type Download struct {
+	URL       string    `json:"url"`
+	FileName  string    `json:"file_name"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// DownloadMP4 returns a presigned URL for the progressive MP4 of a rendition,
+// 1080p unless another resolution is given. Browsers save it under the video title.
+func (uc *AssetUseCase) DownloadMP4(ctx context.Context, videoID string, resolution entity.Resolution) (*Download, error) {
+	video, err := uc.videoRepo.GetByID(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+
+	if resolution == "" {
+		resolution = entity.Resolution1080p
+	}
+
+	assets, err := uc.assetRepo.GetByVideoID(ctx, videoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get assets: %w", err)
+	}
+
+	for _, asset := range assets {
+		if asset.Type != entity.AssetMP4 || asset.Name != string(resolution) {
+			continue
+		}
+
+		title := strings.Trim(unsafeFileNameChars.ReplaceAllString(video.Title, "_"), "_")
+		if title == "" {
+			title = video.ID
+		}
+		fileName := fmt.Sprintf("%s_%s.mp4", title, resolution)
+
+		url, err := uc.storageRepo.GeneratePresignedURL(ctx, asset.URL, fileName, downloadURLExpiry)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Download{
+			URL:       url,
+			FileName:  fileName,
+			ExpiresAt: time.Now().Add(downloadURLExpiry),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("download for resolution %s not found", resolution)
+}
@@ -0,0 +1,135 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+	"cams.dev/video_upload_backend/internal/domain/repository"
+	"cams.dev/video_upload_backend/pkg/subtitle"
+)
+
+// languageTag matches a BCP 47 style language tag such as "en", "tha" or "pt-BR"
+var languageTag = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// CaptionUseCase handles caption-related operations
+type CaptionUseCase struct {
+	videoRepo   repository.VideoRepository
+	captionRepo repository.CaptionRepository
+	storageRepo repository.StorageRepository
+}
+
+// NewCaptionUseCase creates a new caption use case instance
+func NewCaptionUseCase(
+	videoRepo repository.VideoRepository,
+	captionRepo repository.CaptionRepository,
+	storageRepo repository.StorageRepository,
+) *CaptionUseCase {
+	return &CaptionUseCase{
+		videoRepo:   videoRepo,
+		captionRepo: captionRepo,
+		storageRepo: storageRepo,
+	}
+}
+
+// CaptionUploadInput represents input data for uploading a caption track
+type CaptionUploadInput struct {
+	VideoID   string
+	UserID    string
+	Language  string
+	Label     string
+	IsDefault bool
+	FileData  []byte
+}
+
+// UploadCaption stores an SRT or WebVTT caption track for a video, converting SRT
+// to WebVTT. An existing uploaded track for the same language is replaced.
+func (uc *CaptionUseCase) UploadCaption(ctx context.Context, input CaptionUploadInput) (*entity.Caption, error) {
+	if !languageTag.MatchString(input.Language) {
+		return nil, errors.New("invalid caption language")
+	}
+
+	video, err := uc.videoRepo.GetByID(ctx, input.VideoID)
+	if err != nil {
+		return nil, err
+	}
+	if video.UserID != input.UserID {
+		return nil, fmt.Errorf("forbidden: video %s belongs to another user", input.VideoID)
+	}
+
+	// Convert to WebVTT, which is the only format HLS players accept
+	vtt, err := subtitle.ToWebVTT(input.FileData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid caption file: %w", err)
+	}
+
+	// Upload the converted track
+	storagePath := fmt.Sprintf("videos/%s/captions/%s.vtt", input.VideoID, input.Language)
+	captionURL, err := uc.storageRepo.UploadFile(ctx, storagePath, vtt, "text/vtt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload caption: %w", err)
+	}
+
+	label := input.Label
+	if label == "" {
+		label = input.Language
+	}
+
+	captions, err := uc.captionRepo.GetByVideoID(ctx, input.VideoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get captions: %w", err)
+	}
+
+	// Only one track can be the default
+	if input.IsDefault {
+		for _, caption := range captions {
+			if caption.IsDefault && caption.Language != input.Language {
+				caption.IsDefault = false
+				if err := uc.captionRepo.Update(ctx, caption); err != nil {
+					return nil, fmt.Errorf("failed to update caption: %w", err)
+				}
+			}
+		}
+	}
+
+	// Replace a previously uploaded track for the same language
+	for _, caption := range captions {
+		if caption.Source == entity.CaptionSourceUpload && caption.Language == input.Language {
+			caption.Label = label
+			caption.URL = captionURL
+			caption.IsDefault = input.IsDefault
+			if err := uc.captionRepo.Update(ctx, caption); err != nil {
+				return nil, fmt.Errorf("failed to update caption: %w", err)
+			}
+			return caption, nil
+		}
+	}
+
+	caption := &entity.Caption{
+		ID:        uuid.New().String(),
+		VideoID:   input.VideoID,
+		Language:  input.Language,
+		Label:     label,
+		Source:    entity.CaptionSourceUpload,
+		URL:       captionURL,
+		IsDefault: input.IsDefault,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	if err := uc.captionRepo.Create(ctx, caption); err != nil {
+		return nil, fmt.Errorf("failed to create caption record: %w", err)
+	}
+
+	return caption, nil
+}
+
+// ListCaptions retrieves the caption tracks of a video
+func (uc *CaptionUseCase) ListCaptions(ctx context.Context, videoID string) ([]*entity.Caption, error) {
+	return uc.captionRepo.GetByVideoID(ctx, videoID)
+}
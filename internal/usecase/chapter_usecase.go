@@ -0,0 +1,174 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+	"cams.dev/video_upload_backend/internal/domain/repository"
+	"cams.dev/video_upload_backend/pkg/subtitle"
+)
+
+// ChapterUseCase handles chapter-related operations
+type ChapterUseCase struct {
+	videoRepo   repository.VideoRepository
+	chapterRepo repository.ChapterRepository
+}
+
+// NewChapterUseCase creates a new chapter use case instance
+func NewChapterUseCase(
+	videoRepo repository.VideoRepository,
+	chapterRepo repository.ChapterRepository,
+) *ChapterUseCase {
+	return &ChapterUseCase{
+		videoRepo:   videoRepo,
+		chapterRepo: chapterRepo,
+	}
+}
+
+// ChapterInput represents input data for creating or updating a chapter
+type ChapterInput struct {
+	VideoID string
+	UserID  string
+	Title   string
+	Start   float64 // Seconds
+	End     float64 // Seconds
+}
+
+// CreateChapter adds a chapter to a video owned by the user
+func (uc *ChapterUseCase) CreateChapter(ctx context.Context, input ChapterInput) (*entity.Chapter, error) {
+	if err := uc.validate(ctx, input); err != nil {
+		return nil, err
+	}
+
+	chapter := &entity.Chapter{
+		ID:        uuid.New().String(),
+		VideoID:   input.VideoID,
+		Title:     strings.TrimSpace(input.Title),
+		Start:     input.Start,
+		End:       input.End,
+		Source:    entity.ChapterSourceManual,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	if err := uc.chapterRepo.Create(ctx, chapter); err != nil {
+		return nil, fmt.Errorf("failed to create chapter record: %w", err)
+	}
+
+	return chapter, nil
+}
+
+// UpdateChapter changes the title and range of a chapter. Edited automatic
+// chapters become manual so reprocessing keeps them.
+func (uc *ChapterUseCase) UpdateChapter(ctx context.Context, chapterID string, input ChapterInput) (*entity.Chapter, error) {
+	chapter, err := uc.videoChapter(ctx, input.VideoID, chapterID)
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.validate(ctx, input); err != nil {
+		return nil, err
+	}
+
+	chapter.Title = strings.TrimSpace(input.Title)
+	chapter.Start = input.Start
+	chapter.End = input.End
+	chapter.Source = entity.ChapterSourceManual
+	if err := uc.chapterRepo.Update(ctx, chapter); err != nil {
+		return nil, fmt.Errorf("failed to update chapter: %w", err)
+	}
+
+	return chapter, nil
+}
+
+// DeleteChapter removes a chapter from a video owned by the user
+func (uc *ChapterUseCase) DeleteChapter(ctx context.Context, videoID, chapterID, userID string) error {
+	if _, err := uc.ownedVideo(ctx, videoID, userID); err != nil {
+		return err
+	}
+	if _, err := uc.videoChapter(ctx, videoID, chapterID); err != nil {
+		return err
+	}
+
+	if err := uc.chapterRepo.Delete(ctx, chapterID); err != nil {
+		return fmt.Errorf("failed to delete chapter: %w", err)
+	}
+
+	return nil
+}
+
+// ListChapters retrieves the chapters of a video in playback order
+func (uc *ChapterUseCase) ListChapters(ctx context.Context, videoID string) ([]*entity.Chapter, error) {
+	if _, err := uc.videoRepo.GetByID(ctx, videoID); err != nil {
+		return nil, err
+	}
+	return uc.chapterRepo.GetByVideoID(ctx, videoID)
+}
+
+// GetChaptersWebVTT renders the chapters of a video as a WebVTT chapters track
+func (uc *ChapterUseCase) GetChaptersWebVTT(ctx context.Context, videoID string) ([]byte, error) {
+	chapters, err := uc.ListChapters(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+
+	var cues []subtitle.Cue
+	for _, chapter := range chapters {
+		cues = append(cues, subtitle.Cue{
+			Start: chapter.Start,
+			End:   chapter.End,
+			Text:  chapter.Title,
+		})
+	}
+
+	return subtitle.WriteWebVTT(cues), nil
+}
+
+// validate checks that the user owns the video and the chapter fits inside it
+func (uc *ChapterUseCase) validate(ctx context.Context, input ChapterInput) error {
+	video, err := uc.ownedVideo(ctx, input.VideoID, input.UserID)
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(input.Title) == "" {
+		return errors.New("invalid chapter: title is required")
+	}
+	if input.Start < 0 || input.End <= input.Start {
+		return errors.New("invalid chapter: end must be after start")
+	}
+	if video.Duration > 0 && input.End > video.Duration {
+		return fmt.Errorf("invalid chapter: end is past the video duration of %.3f seconds", video.Duration)
+	}
+
+	return nil
+}
+
+// ownedVideo retrieves a video, checking that it belongs to the user
+func (uc *ChapterUseCase) ownedVideo(ctx context.Context, videoID, userID string) (*entity.Video, error) {
+	video, err := uc.videoRepo.GetByID(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+	if video.UserID != userID {
+		return nil, fmt.Errorf("forbidden: video %s belongs to another user", videoID)
+	}
+	return video, nil
+}
+
+// videoChapter retrieves a chapter, checking that it belongs to the video
+func (uc *ChapterUseCase) videoChapter(ctx context.Context, videoID, chapterID string) (*entity.Chapter, error) {
+	chapter, err := uc.chapterRepo.GetByID(ctx, chapterID)
+	if err != nil {
+		return nil, err
+	}
+	if chapter.VideoID != videoID {
+		return nil, fmt.Errorf("chapter with ID %s not found", chapterID)
+	}
+	return chapter, nil
+}
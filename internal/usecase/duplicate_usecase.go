@@ -0,0 +1,281 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+	"cams.dev/video_upload_backend/internal/domain/repository"
+)
+
+// DuplicateUseCase handles re-uploads of videos that were already processed
+type DuplicateUseCase struct {
+	videoRepo        repository.VideoRepository
+	segmentRepo      repository.SegmentRepository
+	renditionRepo    repository.RenditionRepository
+	captionRepo      repository.CaptionRepository
+	assetRepo        repository.AssetRepository
+	chapterRepo      repository.ChapterRepository
+	keyRepo          repository.KeyRepository
+	qcRepo           repository.QCRepository
+	transactor       repository.Transactor
+	transcodeUseCase *TranscodeUseCase
+	maxDistance      float64
+}
+
+// NewDuplicateUseCase creates a new duplicate use case instance. Videos whose
+// perceptual hashes differ by at most maxDistance bits per frame are reported
+// as near-duplicates.
+func NewDuplicateUseCase(
+	videoRepo repository.VideoRepository,
+	segmentRepo repository.SegmentRepository,
+	renditionRepo repository.RenditionRepository,
+	captionRepo repository.CaptionRepository,
+	assetRepo repository.AssetRepository,
+	chapterRepo repository.ChapterRepository,
+	keyRepo repository.KeyRepository,
+	qcRepo repository.QCRepository,
+	transactor repository.Transactor,
+	transcodeUseCase *TranscodeUseCase,
+	maxDistance float64,
+) *DuplicateUseCase {
+	return &DuplicateUseCase{
+		videoRepo:        videoRepo,
+		segmentRepo:      segmentRepo,
+		renditionRepo:    renditionRepo,
+		captionRepo:      captionRepo,
+		assetRepo:        assetRepo,
+		chapterRepo:      chapterRepo,
+		keyRepo:          keyRepo,
+		qcRepo:           qcRepo,
+		transactor:       transactor,
+		transcodeUseCase: transcodeUseCase,
+		maxDistance:      maxDistance,
+	}
+}
+
+// LinkDuplicate completes a duplicate upload with the renditions of the video it
+// duplicates. The records are copied but keep pointing at the original's storage.
+func (uc *DuplicateUseCase) LinkDuplicate(ctx context.Context, videoID, userID string) (*entity.Video, error) {
+	video, err := uc.pendingDuplicate(ctx, videoID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := uc.videoRepo.GetByID(ctx, video.Metadata.DuplicateOf)
+	if err != nil {
+		return nil, err
+	}
+	if source.Status != entity.StatusComplete {
+		return nil, fmt.Errorf("invalid duplicate: video %s is no longer complete", source.ID)
+	}
+
+	// Copy in one transaction so a failure leaves the duplicate pending, ready to retry
+	err = uc.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		return uc.copyRecords(ctx, source, video)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return video, nil
+}
+
+// copyRecords copies the records of source to video and completes video
+func (uc *DuplicateUseCase) copyRecords(ctx context.Context, source, video *entity.Video) error {
+	renditions, err := uc.renditionRepo.GetByVideoID(ctx, source.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get renditions: %w", err)
+	}
+	for _, rendition := range renditions {
+		rendition.ID = uuid.New().String()
+		rendition.VideoID = video.ID
+		rendition.CreatedAt = time.Now()
+		if err := uc.renditionRepo.Create(ctx, rendition); err != nil {
+			return fmt.Errorf("failed to create rendition record: %w", err)
+		}
+	}
+
+	segments, err := uc.segmentRepo.GetByVideoID(ctx, source.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get segments: %w", err)
+	}
+	for _, segment := range segments {
+		segment.ID = uuid.New().String()
+		segment.VideoID = video.ID
+		segment.CreatedAt = time.Now()
+		if err := uc.segmentRepo.Create(ctx, segment); err != nil {
+			return fmt.Errorf("failed to create segment record: %w", err)
+		}
+	}
+
+	captions, err := uc.captionRepo.GetByVideoID(ctx, source.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get captions: %w", err)
+	}
+	for _, caption := range captions {
+		caption.ID = uuid.New().String()
+		caption.VideoID = video.ID
+		caption.CreatedAt = time.Now()
+		caption.UpdatedAt = time.Now()
+		if err := uc.captionRepo.Create(ctx, caption); err != nil {
+			return fmt.Errorf("failed to create caption record: %w", err)
+		}
+	}
+
+	chapters, err := uc.chapterRepo.GetByVideoID(ctx, source.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get chapters: %w", err)
+	}
+	for _, chapter := range chapters {
+		chapter.ID = uuid.New().String()
+		chapter.VideoID = video.ID
+		chapter.CreatedAt = time.Now()
+		chapter.UpdatedAt = time.Now()
+		if err := uc.chapterRepo.Create(ctx, chapter); err != nil {
+			return fmt.Errorf("failed to create chapter record: %w", err)
+		}
+	}
+
+	assets, err := uc.assetRepo.GetByVideoID(ctx, source.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get assets: %w", err)
+	}
+	for _, asset := range assets {
+		asset.ID = uuid.New().String()
+		asset.VideoID = video.ID
+		asset.CreatedAt = time.Now()
+		asset.UpdatedAt = time.Now()
+		if err := uc.assetRepo.Create(ctx, asset); err != nil {
+			return fmt.Errorf("failed to create asset record: %w", err)
+		}
+	}
+
+	// The copied segments are encrypted with the source's content key
+	if source.Metadata.Encryption.Enabled() {
+		key, err := uc.keyRepo.GetByVideoID(ctx, source.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get encryption key: %w", err)
+		}
+		key.VideoID = video.ID
+		key.CreatedAt = time.Now()
+		if err := uc.keyRepo.Save(ctx, key); err != nil {
+			return fmt.Errorf("failed to save encryption key: %w", err)
+		}
+	}
+
+	// QC is optional, so the source may have no report
+	report, err := uc.qcRepo.GetByVideoID(ctx, source.ID)
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		return fmt.Errorf("failed to get QC report: %w", err)
+	}
+	if report != nil {
+		report.VideoID = video.ID
+		report.CreatedAt = time.Now()
+		if err := uc.qcRepo.Save(ctx, report); err != nil {
+			return fmt.Errorf("failed to save QC report: %w", err)
+		}
+	}
+
+	// Take over what processing would have found, keeping the link to the video
+	// whose files are shared. A linked source shares the files of its own source.
+	video.Duration = source.Duration
+	video.ResolutionInfo = source.ResolutionInfo
+	video.ThumbnailURL = source.ThumbnailURL
+	video.PerceptualHash = source.PerceptualHash
+	video.Metadata = source.Metadata
+	if video.Metadata.DuplicateOf == "" {
+		video.Metadata.DuplicateOf = source.ID
+	}
+	video.Status = entity.StatusComplete
+
+	if err := uc.videoRepo.Update(ctx, video); err != nil {
+		return fmt.Errorf("failed to update video: %w", err)
+	}
+
+	return nil
+}
+
+// ProcessDuplicate transcodes a duplicate upload as a video of its own
+func (uc *DuplicateUseCase) ProcessDuplicate(ctx context.Context, videoID, userID string) (*entity.Video, error) {
+	video, err := uc.pendingDuplicate(ctx, videoID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	video.Metadata.DuplicateOf = ""
+	video.Status = entity.StatusPending
+	if err := uc.videoRepo.Update(ctx, video); err != nil {
+		return nil, fmt.Errorf("failed to update video: %w", err)
+	}
+
+	uc.transcodeUseCase.ProcessAsync(video, nil)
+
+	return video, nil
+}
+
+// NearDuplicates lists videos of any user that look like copies of the video,
+// closest first. Uploads of the same file always come first.
+func (uc *DuplicateUseCase) NearDuplicates(ctx context.Context, videoID string) ([]*entity.DuplicateCandidate, error) {
+	video, err := uc.videoRepo.GetByID(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+	if video.PerceptualHash == "" && video.Checksum == "" {
+		return nil, fmt.Errorf("invalid video: %s has not been fingerprinted", video.ID)
+	}
+
+	videos, err := uc.videoRepo.ListWithPerceptualHash(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list videos: %w", err)
+	}
+
+	candidates := []*entity.DuplicateCandidate{}
+	for _, other := range videos {
+		if other.ID == video.ID {
+			continue
+		}
+
+		exact := video.Checksum != "" && other.Checksum == video.Checksum
+		distance := entity.HashDistance(video.PerceptualHash, other.PerceptualHash)
+		if !exact && (distance < 0 || distance > uc.maxDistance) {
+			continue
+		}
+
+		candidates = append(candidates, &entity.DuplicateCandidate{
+			Video:    other,
+			Exact:    exact,
+			Distance: distance,
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Exact != candidates[j].Exact {
+			return candidates[i].Exact
+		}
+		return candidates[i].Distance < candidates[j].Distance
+	})
+
+	return candidates, nil
+}
+
+// pendingDuplicate retrieves a duplicate upload of the user that is still waiting for a decision
+func (uc *DuplicateUseCase) pendingDuplicate(ctx context.Context, videoID, userID string) (*entity.Video, error) {
+	video, err := uc.videoRepo.GetByID(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+	if video.UserID != userID {
+		return nil, fmt.Errorf("forbidden: video %s belongs to another user", video.ID)
+	}
+	if video.Status != entity.StatusDuplicate {
+		return nil, fmt.Errorf("invalid video: %s is not a duplicate upload", video.ID)
+	}
+
+	return video, nil
+}
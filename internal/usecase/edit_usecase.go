@@ -0,0 +1,304 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+	"cams.dev/video_upload_backend/internal/domain/repository"
+)
+
+// keyframeTolerance is how far in seconds a cut may be from a keyframe and still
+// be done by stream copy
+const keyframeTolerance = 0.05
+
+// Limits and output frame rate of concatenation jobs
+const (
+	maxConcatVideos = 20
+	concatFPS       = 30
+)
+
+// EditUseCase creates new videos by editing existing ones
+type EditUseCase struct {
+	videoRepo        repository.VideoRepository
+	storageRepo      repository.StorageRepository
+	transcodeRepo    repository.TranscodeRepository
+	transcodeUseCase *TranscodeUseCase
+}
+
+// NewEditUseCase creates a new edit use case instance
+func NewEditUseCase(
+	videoRepo repository.VideoRepository,
+	storageRepo repository.StorageRepository,
+	transcodeRepo repository.TranscodeRepository,
+	transcodeUseCase *TranscodeUseCase,
+) *EditUseCase {
+	return &EditUseCase{
+		videoRepo:        videoRepo,
+		storageRepo:      storageRepo,
+		transcodeRepo:    transcodeRepo,
+		transcodeUseCase: transcodeUseCase,
+	}
+}
+
+// ClipInput represents input data for cutting a clip out of a video
+type ClipInput struct {
+	SourceVideoID string
+	UserID        string
+	Title         string
+	Description   string
+	Start         float64 // Seconds
+	End           float64 // Seconds
+}
+
+// CreateClip creates a new video from a time range of a source video. The clip is
+// cut and processed in the background like an upload.
+func (uc *EditUseCase) CreateClip(ctx context.Context, input ClipInput) (*entity.Video, error) {
+	source, err := uc.ownedVideo(ctx, input.SourceVideoID, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Start < 0 || input.End <= input.Start {
+		return nil, errors.New("invalid clip range: end must be after start")
+	}
+	if source.Duration > 0 && input.End > source.Duration {
+		return nil, fmt.Errorf("invalid clip range: end is past the video duration of %.3f seconds", source.Duration)
+	}
+
+	title := input.Title
+	if title == "" {
+		title = source.Title + " (clip)"
+	}
+
+	video := &entity.Video{
+		ID:          uuid.New().String(),
+		Title:       title,
+		Description: input.Description,
+		Status:      entity.StatusPending,
+		MimeType:    "video/mp4",
+		UserID:      input.UserID,
+		Metadata: entity.VideoMetadata{
+			Edit: &entity.EditInfo{
+				Operation: entity.EditClip,
+				Sources: []entity.EditSource{
+					{VideoID: source.ID, Start: input.Start, End: input.End},
+				},
+			},
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	if err := uc.videoRepo.Create(ctx, video); err != nil {
+		return nil, fmt.Errorf("failed to create video record: %w", err)
+	}
+
+	uc.transcodeUseCase.ProcessAsync(video, func(ctx context.Context, video *entity.Video) error {
+		return uc.cutClip(ctx, video, source, input.Start, input.End)
+	})
+
+	return video, nil
+}
+
+// cutClip trims the source video and uploads the result as the original of the clip
+func (uc *EditUseCase) cutClip(
+	ctx context.Context,
+	video *entity.Video,
+	source *entity.Video,
+	start float64,
+	end float64,
+) error {
+	tempDir, err := os.MkdirTemp("", "video-clip-"+video.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	sourcePath, err := uc.downloadOriginal(ctx, source, tempDir)
+	if err != nil {
+		return err
+	}
+
+	// Copy the streams when the cut starts on a keyframe, otherwise re-encode
+	keyframes, err := uc.transcodeRepo.Keyframes(ctx, sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to read keyframes: %w", err)
+	}
+	reencode := !onKeyframe(keyframes, start)
+
+	outputPath := filepath.Join(tempDir, "clip.mp4")
+	if err := uc.transcodeRepo.Trim(ctx, sourcePath, outputPath, start, end, reencode); err != nil {
+		return fmt.Errorf("failed to trim video: %w", err)
+	}
+
+	return uc.uploadOriginal(ctx, video, outputPath)
+}
+
+// ConcatInput represents input data for joining videos
+type ConcatInput struct {
+	VideoIDs    []string // In playback order
+	UserID      string
+	Title       string
+	Description string
+}
+
+// ConcatVideos creates a new video by joining the user's videos in order. The
+// videos are normalized and joined in the background, then processed like an upload.
+func (uc *EditUseCase) ConcatVideos(ctx context.Context, input ConcatInput) (*entity.Video, error) {
+	if len(input.VideoIDs) < 2 {
+		return nil, errors.New("invalid video list: at least two videos are required")
+	}
+	if len(input.VideoIDs) > maxConcatVideos {
+		return nil, fmt.Errorf("invalid video list: at most %d videos can be joined", maxConcatVideos)
+	}
+
+	var sources []*entity.Video
+	var editSources []entity.EditSource
+	for _, videoID := range input.VideoIDs {
+		source, err := uc.ownedVideo(ctx, videoID, input.UserID)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+		editSources = append(editSources, entity.EditSource{VideoID: source.ID})
+	}
+
+	title := input.Title
+	if title == "" {
+		title = sources[0].Title + " (joined)"
+	}
+
+	video := &entity.Video{
+		ID:          uuid.New().String(),
+		Title:       title,
+		Description: input.Description,
+		Status:      entity.StatusPending,
+		MimeType:    "video/mp4",
+		UserID:      input.UserID,
+		Metadata: entity.VideoMetadata{
+			Edit: &entity.EditInfo{
+				Operation: entity.EditConcat,
+				Sources:   editSources,
+			},
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	if err := uc.videoRepo.Create(ctx, video); err != nil {
+		return nil, fmt.Errorf("failed to create video record: %w", err)
+	}
+
+	uc.transcodeUseCase.ProcessAsync(video, func(ctx context.Context, video *entity.Video) error {
+		return uc.joinVideos(ctx, video, sources)
+	})
+
+	return video, nil
+}
+
+// joinVideos concatenates the source videos and uploads the result as the original
+// of the new video
+func (uc *EditUseCase) joinVideos(ctx context.Context, video *entity.Video, sources []*entity.Video) error {
+	tempDir, err := os.MkdirTemp("", "video-concat-"+video.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// The common profile is 1080p unless every source is 720p or smaller
+	resolution := entity.Resolution720p
+	var inputPaths []string
+	for _, source := range sources {
+		path, err := uc.downloadOriginal(ctx, source, tempDir)
+		if err != nil {
+			return err
+		}
+		inputPaths = append(inputPaths, path)
+
+		info, err := uc.transcodeRepo.Probe(ctx, path)
+		if err != nil {
+			return fmt.Errorf("failed to probe video %s: %w", source.ID, err)
+		}
+		if _, height := entity.Resolution720p.Dimensions(); info.Height > height {
+			resolution = entity.Resolution1080p
+		}
+	}
+
+	outputPath := filepath.Join(tempDir, "concat.mp4")
+	if err := uc.transcodeRepo.Concat(ctx, inputPaths, outputPath, resolution, concatFPS); err != nil {
+		return fmt.Errorf("failed to join videos: %w", err)
+	}
+
+	return uc.uploadOriginal(ctx, video, outputPath)
+}
+
+// ownedVideo retrieves a video, checking that it belongs to the user
+func (uc *EditUseCase) ownedVideo(ctx context.Context, videoID, userID string) (*entity.Video, error) {
+	video, err := uc.videoRepo.GetByID(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+	if video.UserID != userID {
+		return nil, fmt.Errorf("forbidden: video %s belongs to another user", videoID)
+	}
+	if video.OriginalURL == "" {
+		return nil, fmt.Errorf("invalid source: video %s has no original file yet", videoID)
+	}
+	return video, nil
+}
+
+// downloadOriginal saves the original file of a video into dir and returns its path
+func (uc *EditUseCase) downloadOriginal(ctx context.Context, video *entity.Video, dir string) (string, error) {
+	data, err := uc.storageRepo.GetFile(ctx, video.OriginalURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to download video %s: %w", video.ID, err)
+	}
+
+	path := filepath.Join(dir, video.ID+filepath.Ext(video.OriginalURL))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to save video to temp dir: %w", err)
+	}
+
+	return path, nil
+}
+
+// uploadOriginal stores an edited file as the original of a video
+func (uc *EditUseCase) uploadOriginal(ctx context.Context, video *entity.Video, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read edited video: %w", err)
+	}
+
+	storagePath := fmt.Sprintf("uploads/%s/original/%s", video.ID, filepath.Base(path))
+	uploadURL, err := uc.storageRepo.UploadFile(ctx, storagePath, data, "video/mp4")
+	if err != nil {
+		return fmt.Errorf("failed to upload video: %w", err)
+	}
+
+	video.OriginalURL = uploadURL
+	video.FileSize = int64(len(data))
+	video.MimeType = "video/mp4"
+	if err := uc.videoRepo.Update(ctx, video); err != nil {
+		return fmt.Errorf("failed to update video: %w", err)
+	}
+
+	return nil
+}
+
+// onKeyframe reports whether t falls on one of the keyframe times
+func onKeyframe(keyframes []float64, t float64) bool {
+	for _, keyframe := range keyframes {
+		if math.Abs(keyframe-t) <= keyframeTolerance {
+			return true
+		}
+	}
+	return false
+}
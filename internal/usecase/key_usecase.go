@@ -0,0 +1,56 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+	"cams.dev/video_upload_backend/internal/domain/repository"
+)
+
+// KeyUseCase handles delivery of HLS content keys
+type KeyUseCase struct {
+	videoRepo repository.VideoRepository
+	keyRepo   repository.KeyRepository
+}
+
+// NewKeyUseCase creates a new key use case instance
+func NewKeyUseCase(
+	videoRepo repository.VideoRepository,
+	keyRepo repository.KeyRepository,
+) *KeyUseCase {
+	return &KeyUseCase{
+		videoRepo: videoRepo,
+		keyRepo:   keyRepo,
+	}
+}
+
+// GetKey returns the content key that decrypts the segments of a video. scoped
+// reports whether a signed URL or playback token for the video authorized the
+// request. Otherwise the key is only given to the owner of the video.
+func (uc *KeyUseCase) GetKey(ctx context.Context, videoID, userID string, scoped bool) ([]byte, error) {
+	video, err := uc.videoRepo.GetByID(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeKey(video, userID, scoped); err != nil {
+		return nil, err
+	}
+
+	key, err := uc.keyRepo.GetByVideoID(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+
+	return key.Key, nil
+}
+
+// authorizeKey checks that a caller may obtain the content key of a video, as
+// anyone holding a signed URL or playback token for the video may. Callers
+// authenticated by a JWT must own it.
+func authorizeKey(video *entity.Video, userID string, scoped bool) error {
+	if scoped || (userID != "" && video.UserID == userID) {
+		return nil
+	}
+	return fmt.Errorf("forbidden: video %s belongs to another user", video.ID)
+}
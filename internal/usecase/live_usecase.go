@@ -0,0 +1,1007 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+	"cams.dev/video_upload_backend/internal/domain/repository"
+	"cams.dev/video_upload_backend/internal/infrastructure/auth"
+	"cams.dev/video_upload_backend/pkg/hls"
+)
+
+// livePollInterval is how often the playlists written by the live encoder are
+// checked for new segments
+const livePollInterval = 500 * time.Millisecond
+
+// liveEndedRetention is how long an ended stream stays playable, so players
+// catch up and see the end of the playlist
+const liveEndedRetention = 5 * time.Minute
+
+// streamKeyBytes is the number of random bytes in a stream key
+const streamKeyBytes = 24
+
+// LiveConfig holds settings for live streaming
+type LiveConfig struct {
+	Ladder          []entity.TranscodeProfile // H.264 renditions encoded in real time
+	SegmentDuration float64                   // Target segment length in seconds
+	PartDuration    float64                   // LL-HLS part length in seconds, zero to disable low latency
+	WindowSize      int                       // Segments listed in live media playlists without a DVR window
+	DVRWindow       time.Duration             // Time viewers can rewind in live playlists, zero for none
+}
+
+// LiveReload is a blocking playlist reload request. The playlist is returned
+// once it contains the segment MSN, or part Part of it if Part is not negative.
+type LiveReload struct {
+	MSN  int
+	Part int
+}
+
+// LiveStreamInput represents input data for creating a live stream
+type LiveStreamInput struct {
+	UserID      string
+	Title       string
+	Description string
+}
+
+// liveSession tracks a live stream while it is being published
+type liveSession struct {
+	mu       sync.Mutex
+	stream   *entity.LiveStream
+	segments map[entity.Resolution][]*entity.LiveSegment
+	parts    map[entity.Resolution][]*entity.LivePart // Parts of the segment being written
+	handled  map[entity.Resolution]int                // Encoder files uploaded so far
+	codecs   map[entity.Resolution]string
+	ended    bool
+	updated  chan struct{} // Closed and replaced whenever media is added
+}
+
+// notify wakes up requests waiting for new media. The caller must hold mu.
+func (s *liveSession) notify() {
+	close(s.updated)
+	s.updated = make(chan struct{})
+}
+
+// LiveUseCase handles live stream ingest, playback and recording
+type LiveUseCase struct {
+	liveRepo      repository.LiveStreamRepository
+	videoRepo     repository.VideoRepository
+	segmentRepo   repository.SegmentRepository
+	renditionRepo repository.RenditionRepository
+	storageRepo   repository.StorageRepository
+	transcodeRepo repository.TranscodeRepository
+	urls          *playbackURLs
+	config        LiveConfig
+
+	mu       sync.Mutex
+	sessions map[string]*liveSession // By live stream ID
+}
+
+// NewLiveUseCase creates a new live use case instance
+func NewLiveUseCase(
+	liveRepo repository.LiveStreamRepository,
+	videoRepo repository.VideoRepository,
+	segmentRepo repository.SegmentRepository,
+	renditionRepo repository.RenditionRepository,
+	storageRepo repository.StorageRepository,
+	transcodeRepo repository.TranscodeRepository,
+	urlSigner *auth.URLSigner,
+	playback PlaybackConfig,
+	config LiveConfig,
+) *LiveUseCase {
+	return &LiveUseCase{
+		liveRepo:      liveRepo,
+		videoRepo:     videoRepo,
+		segmentRepo:   segmentRepo,
+		renditionRepo: renditionRepo,
+		storageRepo:   storageRepo,
+		transcodeRepo: transcodeRepo,
+		urls:          newPlaybackURLs(storageRepo, urlSigner, playback),
+		config:        config,
+		sessions:      make(map[string]*liveSession),
+	}
+}
+
+// CreateLiveStream creates a live stream with a new stream key
+func (uc *LiveUseCase) CreateLiveStream(ctx context.Context, input LiveStreamInput) (*entity.LiveStream, error) {
+	if strings.TrimSpace(input.Title) == "" {
+		return nil, fmt.Errorf("invalid live stream: title is required")
+	}
+
+	key := make([]byte, streamKeyBytes)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate stream key: %w", err)
+	}
+
+	stream := &entity.LiveStream{
+		ID:          uuid.New().String(),
+		Title:       input.Title,
+		Description: input.Description,
+		StreamKey:   hex.EncodeToString(key),
+		Status:      entity.LiveStatusIdle,
+		UserID:      input.UserID,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	if err := uc.liveRepo.Create(ctx, stream); err != nil {
+		return nil, fmt.Errorf("failed to create live stream: %w", err)
+	}
+
+	return stream, nil
+}
+
+// RecoverStreams ends the streams left live by a previous run of the server,
+// whose sessions were lost, so their encoders can publish again
+func (uc *LiveUseCase) RecoverStreams(ctx context.Context) error {
+	streams, err := uc.liveRepo.GetByStatus(ctx, entity.LiveStatusLive)
+	if err != nil {
+		return fmt.Errorf("failed to get live streams: %w", err)
+	}
+
+	for _, stream := range streams {
+		if _, err := uc.session(stream.ID); err == nil {
+			continue
+		}
+
+		now := time.Now()
+		stream.Status = entity.LiveStatusEnded
+		stream.EndedAt = &now
+		stream.UpdatedAt = now
+		if err := uc.liveRepo.Update(ctx, stream); err != nil {
+			return fmt.Errorf("failed to update live stream: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetLiveStream retrieves a live stream. The stream key is only returned to the owner.
+func (uc *LiveUseCase) GetLiveStream(ctx context.Context, id, userID string) (*entity.LiveStream, error) {
+	stream, err := uc.liveRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if stream.UserID != userID {
+		stream.StreamKey = ""
+	}
+
+	return stream, nil
+}
+
+// ListLiveStreams retrieves the live streams of a user
+func (uc *LiveUseCase) ListLiveStreams(ctx context.Context, userID string) ([]*entity.LiveStream, error) {
+	return uc.liveRepo.GetByUserID(ctx, userID)
+}
+
+// Publish starts a live session for the stream with the given key. The
+// returned writer takes the published FLV stream and ends the session when closed.
+// A stream key can be used again once a broadcast ended, each broadcast is
+// recorded as a separate video.
+func (uc *LiveUseCase) Publish(streamKey string) (io.WriteCloser, error) {
+	ctx := context.Background()
+
+	if len(uc.config.Ladder) == 0 {
+		return nil, errors.New("live ladder has no renditions")
+	}
+
+	stream, err := uc.liveRepo.GetByStreamKey(ctx, streamKey)
+	if err != nil {
+		return nil, err
+	}
+	if stream.Status == entity.LiveStatusLive {
+		return nil, fmt.Errorf("invalid live stream: %s is %s", stream.ID, stream.Status)
+	}
+
+	session := &liveSession{
+		stream:   stream,
+		segments: make(map[entity.Resolution][]*entity.LiveSegment),
+		parts:    make(map[entity.Resolution][]*entity.LivePart),
+		handled:  make(map[entity.Resolution]int),
+		codecs:   make(map[entity.Resolution]string),
+		updated:  make(chan struct{}),
+	}
+
+	// The session of an ended broadcast is kept for a while for players catching
+	// up, a new broadcast takes its place
+	uc.mu.Lock()
+	if previous, ok := uc.sessions[stream.ID]; ok {
+		previous.mu.Lock()
+		ended := previous.ended
+		previous.mu.Unlock()
+		if !ended {
+			uc.mu.Unlock()
+			return nil, fmt.Errorf("invalid live stream: %s is already being published", stream.ID)
+		}
+	}
+	uc.sessions[stream.ID] = session
+	uc.mu.Unlock()
+
+	tempDir, err := os.MkdirTemp("", "video-live-"+stream.ID)
+	if err != nil {
+		uc.removeSession(stream.ID, session)
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	// The recording becomes a video with this ID when the stream ends
+	now := time.Now()
+	stream.Status = entity.LiveStatusLive
+	stream.VideoID = uuid.New().String()
+	stream.StartedAt = &now
+	stream.EndedAt = nil
+	stream.UpdatedAt = now
+	if err := uc.liveRepo.Update(ctx, stream); err != nil {
+		os.RemoveAll(tempDir)
+		uc.removeSession(stream.ID, session)
+		return nil, fmt.Errorf("failed to update live stream: %w", err)
+	}
+
+	reader, writer := io.Pipe()
+	go uc.ingest(session, reader, tempDir)
+
+	return writer, nil
+}
+
+// ingest runs the live encoder on a published stream, uploading segments as
+// they complete, and turns the recording into a video once the stream ends
+func (uc *LiveUseCase) ingest(session *liveSession, input *io.PipeReader, tempDir string) {
+	defer os.RemoveAll(tempDir)
+
+	// Create a new context since the stream outlives the publishing request
+	bgCtx := context.Background()
+	recordingPath := filepath.Join(tempDir, "recording.ts")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- uc.transcodeRepo.TranscodeLive(bgCtx, input, tempDir, uc.config.Ladder, entity.LiveOptions{
+			SegmentDuration: uc.config.SegmentDuration,
+			PartDuration:    uc.config.PartDuration,
+			RecordingPath:   recordingPath,
+		})
+	}()
+
+	ticker := time.NewTicker(livePollInterval)
+	defer ticker.Stop()
+
+	// Collect once more after the encoder exits to pick up the last segments
+	var err error
+	for running := true; running; {
+		select {
+		case err = <-done:
+			running = false
+		case <-ticker.C:
+		}
+		uc.collectSegments(bgCtx, session, tempDir)
+	}
+
+	// The parts written since the last full segment make up the final one
+	for _, profile := range uc.config.Ladder {
+		if err := uc.joinParts(bgCtx, session, profile.Name, filepath.Join(tempDir, string(profile.Name))); err != nil {
+			fmt.Printf("Failed to upload live segment: %v\n", err)
+		}
+	}
+
+	// Fail further writes from the publisher if the encoder stopped early
+	input.CloseWithError(errors.New("live encoder stopped"))
+	if err != nil {
+		fmt.Printf("Failed to transcode live stream %s: %v\n", session.stream.ID, err)
+	}
+
+	uc.finishStream(bgCtx, session, recordingPath)
+}
+
+// collectSegments uploads the files the live encoder has completed since the last call
+func (uc *LiveUseCase) collectSegments(ctx context.Context, session *liveSession, tempDir string) {
+	for _, profile := range uc.config.Ladder {
+		renditionDir := filepath.Join(tempDir, string(profile.Name))
+		data, err := os.ReadFile(filepath.Join(renditionDir, "playlist.m3u8"))
+		if err != nil {
+			// The encoder hasn't written the playlist yet
+			continue
+		}
+
+		playlist, err := hls.ParseMediaPlaylist(string(data))
+		if err != nil {
+			fmt.Printf("Failed to parse live playlist of %s: %v\n", profile.Name, err)
+			continue
+		}
+
+		session.mu.Lock()
+		handled := session.handled[profile.Name]
+		session.mu.Unlock()
+
+		for _, file := range playlist.Segments[handled:] {
+			var err error
+			if uc.config.PartDuration > 0 {
+				err = uc.uploadLivePart(ctx, session, profile.Name, renditionDir, file)
+			} else {
+				err = uc.uploadLiveSegment(ctx, session, profile.Name, renditionDir, file)
+			}
+			if err != nil {
+				// Try again on the next poll
+				fmt.Printf("Failed to upload live segment: %v\n", err)
+				break
+			}
+		}
+	}
+}
+
+// uploadLiveSegment uploads a segment completed by the live encoder
+func (uc *LiveUseCase) uploadLiveSegment(
+	ctx context.Context,
+	session *liveSession,
+	rendition entity.Resolution,
+	renditionDir string,
+	file hls.Segment,
+) error {
+	filePath := filepath.Join(renditionDir, filepath.Base(file.URI))
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read segment file: %w", err)
+	}
+	uc.probeCodecs(ctx, session, rendition, filePath)
+
+	session.mu.Lock()
+	sequence := len(session.segments[rendition])
+	session.mu.Unlock()
+
+	fileName := fmt.Sprintf("segment_%05d.ts", sequence)
+	url, err := uc.storageRepo.UploadFile(ctx, uc.liveStoragePath(session, rendition, fileName), data, "video/mp2t")
+	if err != nil {
+		return fmt.Errorf("failed to upload segment: %w", err)
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.segments[rendition] = append(session.segments[rendition], &entity.LiveSegment{
+		Sequence: sequence,
+		Duration: file.Duration,
+		FileName: fileName,
+		URL:      url,
+		Size:     len(data),
+	})
+	session.handled[rendition]++
+	session.notify()
+
+	return nil
+}
+
+// uploadLivePart uploads a part completed by the live encoder, joining the
+// parts into a segment once there are enough for its duration
+func (uc *LiveUseCase) uploadLivePart(
+	ctx context.Context,
+	session *liveSession,
+	rendition entity.Resolution,
+	renditionDir string,
+	file hls.Segment,
+) error {
+	filePath := filepath.Join(renditionDir, filepath.Base(file.URI))
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read part file: %w", err)
+	}
+	uc.probeCodecs(ctx, session, rendition, filePath)
+
+	session.mu.Lock()
+	fileName := livePartFileName(session.handled[rendition])
+	session.mu.Unlock()
+
+	url, err := uc.storageRepo.UploadFile(ctx, uc.liveStoragePath(session, rendition, fileName), data, "video/mp2t")
+	if err != nil {
+		return fmt.Errorf("failed to upload part: %w", err)
+	}
+
+	session.mu.Lock()
+	session.parts[rendition] = append(session.parts[rendition], &entity.LivePart{
+		Duration: file.Duration,
+		FileName: fileName,
+		URL:      url,
+	})
+	session.handled[rendition]++
+	session.notify()
+	complete := len(session.parts[rendition]) >= uc.partsPerSegment()
+	session.mu.Unlock()
+
+	if complete {
+		return uc.joinParts(ctx, session, rendition, renditionDir)
+	}
+	return nil
+}
+
+// joinParts uploads the parts of the segment being written as one segment.
+// MPEG-TS parts that start with keyframes join by concatenation.
+func (uc *LiveUseCase) joinParts(ctx context.Context, session *liveSession, rendition entity.Resolution, renditionDir string) error {
+	session.mu.Lock()
+	parts := session.parts[rendition]
+	first := session.handled[rendition] - len(parts)
+	sequence := len(session.segments[rendition])
+	session.mu.Unlock()
+
+	if len(parts) == 0 {
+		return nil
+	}
+
+	// Parts are stored under their own numbering, the encoder's files are
+	// numbered in the same order
+	playlist, err := os.ReadFile(filepath.Join(renditionDir, "playlist.m3u8"))
+	if err != nil {
+		return fmt.Errorf("failed to read live playlist: %w", err)
+	}
+	files, err := hls.ParseMediaPlaylist(string(playlist))
+	if err != nil {
+		return fmt.Errorf("failed to parse live playlist: %w", err)
+	}
+	if first+len(parts) > len(files.Segments) {
+		return fmt.Errorf("parts of segment %d not found", sequence)
+	}
+
+	var data []byte
+	var duration float64
+	for i, part := range parts {
+		partData, err := os.ReadFile(filepath.Join(renditionDir, filepath.Base(files.Segments[first+i].URI)))
+		if err != nil {
+			return fmt.Errorf("failed to read part file: %w", err)
+		}
+		data = append(data, partData...)
+		duration += part.Duration
+	}
+
+	fileName := fmt.Sprintf("segment_%05d.ts", sequence)
+	url, err := uc.storageRepo.UploadFile(ctx, uc.liveStoragePath(session, rendition, fileName), data, "video/mp2t")
+	if err != nil {
+		return fmt.Errorf("failed to upload segment: %w", err)
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.segments[rendition] = append(session.segments[rendition], &entity.LiveSegment{
+		Sequence: sequence,
+		Duration: duration,
+		FileName: fileName,
+		URL:      url,
+		Size:     len(data),
+		Parts:    parts,
+	})
+	session.parts[rendition] = nil
+	session.notify()
+
+	return nil
+}
+
+// probeCodecs detects the codecs of a rendition from its first file, to
+// advertise them in the master playlist
+func (uc *LiveUseCase) probeCodecs(ctx context.Context, session *liveSession, rendition entity.Resolution, filePath string) {
+	session.mu.Lock()
+	_, probed := session.codecs[rendition]
+	session.mu.Unlock()
+	if probed {
+		return
+	}
+
+	codecs, err := uc.transcodeRepo.CodecString(ctx, filePath)
+	if err != nil {
+		fmt.Printf("Failed to detect codecs of %s: %v\n", rendition, err)
+	}
+
+	session.mu.Lock()
+	session.codecs[rendition] = codecs
+	session.mu.Unlock()
+}
+
+// liveStoragePath returns the storage key of a file of a live rendition
+func (uc *LiveUseCase) liveStoragePath(session *liveSession, rendition entity.Resolution, fileName string) string {
+	return fmt.Sprintf("videos/%s/%s/%s", session.stream.VideoID, rendition, fileName)
+}
+
+// partsPerSegment returns the number of parts joined into each segment
+func (uc *LiveUseCase) partsPerSegment() int {
+	return max(1, int(math.Round(uc.config.SegmentDuration/uc.config.PartDuration)))
+}
+
+// finishStream marks a live stream as ended and turns its segments into a video
+func (uc *LiveUseCase) finishStream(ctx context.Context, session *liveSession, recordingPath string) {
+	session.mu.Lock()
+	session.ended = true
+	session.notify()
+	session.mu.Unlock()
+
+	stream := session.stream
+	now := time.Now()
+	stream.Status = entity.LiveStatusEnded
+	stream.EndedAt = &now
+	stream.UpdatedAt = now
+	if err := uc.liveRepo.Update(ctx, stream); err != nil {
+		// Log error but continue
+		fmt.Printf("Failed to update live stream status: %v\n", err)
+	}
+
+	if err := uc.finalizeRecording(ctx, session, recordingPath); err != nil {
+		fmt.Printf("Failed to record live stream %s: %v\n", stream.ID, err)
+	}
+
+	time.AfterFunc(liveEndedRetention, func() {
+		uc.removeSession(stream.ID, session)
+	})
+}
+
+// finalizeRecording creates the video of an ended stream from the segments
+// uploaded while it was live, so it plays as VOD without being transcoded
+// again. The published stream is kept as the original for editing.
+func (uc *LiveUseCase) finalizeRecording(ctx context.Context, session *liveSession, recordingPath string) error {
+	stream := session.stream
+	if len(uc.config.Ladder) == 0 {
+		return fmt.Errorf("live stream %s has no renditions to record", stream.ID)
+	}
+
+	session.mu.Lock()
+	segments := make(map[entity.Resolution][]*entity.LiveSegment, len(session.segments))
+	for rendition, renditionSegments := range session.segments {
+		segments[rendition] = renditionSegments
+	}
+	codecs := session.codecs
+	session.mu.Unlock()
+
+	var duration float64
+	for _, segment := range segments[uc.config.Ladder[0].Name] {
+		duration += segment.Duration
+	}
+	if duration == 0 {
+		return fmt.Errorf("live stream %s has no segments", stream.ID)
+	}
+
+	width, height := uc.config.Ladder[0].Resolution.Dimensions()
+	video := &entity.Video{
+		ID:             stream.VideoID,
+		Title:          stream.Title,
+		Description:    stream.Description,
+		Status:         entity.StatusProcessing,
+		Duration:       duration,
+		ResolutionInfo: fmt.Sprintf("%dx%d", width, height),
+		MimeType:       "video/mp2t",
+		UserID:         stream.UserID,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+
+	// The recording is optional, the segments are enough to play the video
+	data, err := os.ReadFile(recordingPath)
+	if err == nil && len(data) > 0 {
+		storagePath := fmt.Sprintf("uploads/%s/original/recording.ts", stream.VideoID)
+		url, err := uc.storageRepo.UploadFile(ctx, storagePath, data, "video/mp2t")
+		if err != nil {
+			fmt.Printf("Failed to upload recording: %v\n", err)
+		} else {
+			checksum := sha256.Sum256(data)
+			video.OriginalURL = url
+			video.FileSize = int64(len(data))
+			video.Checksum = hex.EncodeToString(checksum[:])
+		}
+	}
+
+	if err := uc.videoRepo.Create(ctx, video); err != nil {
+		return fmt.Errorf("failed to create video record: %w", err)
+	}
+
+	for _, profile := range uc.config.Ladder {
+		err = uc.finalizeRendition(ctx, video.ID, profile, segments[profile.Name], codecs[profile.Name])
+		if err != nil {
+			break
+		}
+	}
+
+	video.Status = entity.StatusComplete
+	if err != nil {
+		video.Status = entity.StatusFailed
+	}
+	if updateErr := uc.videoRepo.Update(ctx, video); updateErr != nil {
+		return fmt.Errorf("failed to update video: %w", updateErr)
+	}
+
+	return err
+}
+
+// finalizeRendition stores the segments of a live rendition as the segments
+// and rendition of its recording
+func (uc *LiveUseCase) finalizeRendition(
+	ctx context.Context,
+	videoID string,
+	profile entity.TranscodeProfile,
+	segments []*entity.LiveSegment,
+	codecs string,
+) error {
+	if len(segments) == 0 {
+		return nil
+	}
+
+	var bitrate renditionBitrate
+	var totalBytes int
+	var startTime float64
+	for _, liveSegment := range segments {
+		segment := &entity.Segment{
+			ID:           uuid.New().String(),
+			VideoID:      videoID,
+			FileName:     liveSegment.FileName,
+			URL:          liveSegment.URL,
+			Resolution:   profile.Name,
+			StartTime:    startTime,
+			Duration:     liveSegment.Duration,
+			SegmentIndex: liveSegment.Sequence,
+			CreatedAt:    time.Now(),
+		}
+		if err := uc.segmentRepo.Create(ctx, segment); err != nil {
+			return fmt.Errorf("failed to create segment record: %w", err)
+		}
+
+		// Track the peak bitrate for the playlist BANDWIDTH attribute
+		if segment.Duration > 0 {
+			if peak := int(float64(liveSegment.Size*8) / segment.Duration); peak > bitrate.Peak {
+				bitrate.Peak = peak
+			}
+		}
+		totalBytes += liveSegment.Size
+		startTime += segment.Duration
+	}
+
+	// Average over the whole rendition for AVERAGE-BANDWIDTH
+	if startTime > 0 {
+		bitrate.Average = int(float64(totalBytes*8) / startTime)
+	}
+
+	width, height := profile.Resolution.Dimensions()
+	rateControl := profile.RateControl
+	rendition := &entity.Rendition{
+		ID:               uuid.New().String(),
+		VideoID:          videoID,
+		Name:             profile.Name,
+		Type:             entity.RenditionVideo,
+		Width:            width,
+		Height:           height,
+		Bandwidth:        bitrate.Peak,
+		AverageBandwidth: bitrate.Average,
+		Codec:            profile.Codec,
+		Codecs:           codecs,
+		VideoRange:       "SDR",
+		RateControl:      &rateControl,
+		CreatedAt:        time.Now(),
+	}
+	if err := uc.renditionRepo.Create(ctx, rendition); err != nil {
+		return fmt.Errorf("failed to create rendition record: %w", err)
+	}
+
+	return nil
+}
+
+// GetLiveMasterPlaylist generates the master playlist of a stream that is being published
+func (uc *LiveUseCase) GetLiveMasterPlaylist(ctx context.Context, streamID, token string) (string, error) {
+	session, err := uc.session(streamID)
+	if err != nil {
+		return "", err
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	expiresAt := uc.urls.expiresAt()
+	playlist := &hls.MasterPlaylist{}
+	for _, profile := range uc.config.Ladder {
+		bandwidth := profile.RateControl.MaxBitrate
+		if bandwidth == 0 {
+			bandwidth = profile.RateControl.Bitrate
+		}
+
+		width, height := profile.Resolution.Dimensions()
+		uri := mediaPlaylistURI(profile.Name)
+		playlist.Variants = append(playlist.Variants, hls.Variant{
+			Bandwidth: bandwidth + audioBitrate(2),
+			Codecs:    session.codecs[profile.Name],
+			Width:     width,
+			Height:    height,
+			URI:       uc.urls.apiURI(uri, liveURI(streamID, uri), expiresAt, token),
+		})
+	}
+
+	return playlist.String(), nil
+}
+
+// GetLiveMediaPlaylist generates the sliding-window media playlist of a live
+// rendition. With a reload request, it waits until the playlist has the
+// requested segment or part, as LL-HLS players ask for.
+func (uc *LiveUseCase) GetLiveMediaPlaylist(
+	ctx context.Context,
+	streamID string,
+	rendition entity.Resolution,
+	reload *LiveReload,
+	token string,
+) (string, error) {
+	session, err := uc.session(streamID)
+	if err != nil {
+		return "", err
+	}
+	if !uc.inLadder(rendition) {
+		return "", fmt.Errorf("rendition %s not found for live stream %s", rendition, streamID)
+	}
+
+	if reload != nil {
+		if err := uc.awaitReload(ctx, session, rendition, reload); err != nil {
+			return "", err
+		}
+	}
+
+	session.mu.Lock()
+	segments := session.segments[rendition]
+	parts := session.parts[rendition]
+	nextPart := session.handled[rendition]
+	ended := session.ended
+	session.mu.Unlock()
+
+	if len(segments) == 0 {
+		return "", fmt.Errorf("segments of live stream %s not found yet", streamID)
+	}
+	segments = uc.window(segments)
+
+	expiresAt := uc.urls.expiresAt()
+	playlist := &hls.MediaPlaylist{
+		MediaSequence: segments[0].Sequence,
+		Ended:         ended,
+		ServerControl: &hls.ServerControl{CanBlockReload: true},
+	}
+
+	lowLatency := uc.config.PartDuration > 0
+	if lowLatency {
+		playlist.PartTarget = uc.config.PartDuration
+		playlist.ServerControl.PartHoldBack = 3 * uc.config.PartDuration
+	}
+
+	// Parts are listed for the segments within three target durations of the end
+	partsFrom := len(segments)
+	if lowLatency {
+		var recent float64
+		for partsFrom > 0 && recent < 3*uc.config.SegmentDuration {
+			partsFrom--
+			recent += segments[partsFrom].Duration
+		}
+	}
+
+	for i, segment := range segments {
+		uri, err := uc.liveObjectURI(ctx, streamID, rendition, segment.FileName, segment.URL, expiresAt, token)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate segment URL: %w", err)
+		}
+
+		entry := hls.Segment{
+			Duration: segment.Duration,
+			URI:      uri,
+		}
+		if i >= partsFrom {
+			if entry.Parts, err = uc.liveParts(ctx, streamID, rendition, segment.Parts, expiresAt, token); err != nil {
+				return "", err
+			}
+		}
+		playlist.Segments = append(playlist.Segments, entry)
+	}
+
+	if lowLatency && !ended {
+		if playlist.Parts, err = uc.liveParts(ctx, streamID, rendition, parts, expiresAt, token); err != nil {
+			return "", err
+		}
+
+		// Only the API can hold the request for the hinted part until it is written
+		fileName := livePartFileName(nextPart)
+		playlist.PreloadHint = uc.urls.apiURI(fileName, liveURI(streamID, fmt.Sprintf("%s/%s", rendition, fileName)), expiresAt, token)
+	}
+
+	return playlist.String(), nil
+}
+
+// window returns the segments listed in a live media playlist: those within
+// the DVR window, or the last few segments without one
+func (uc *LiveUseCase) window(segments []*entity.LiveSegment) []*entity.LiveSegment {
+	if uc.config.DVRWindow <= 0 {
+		if len(segments) > uc.config.WindowSize {
+			return segments[len(segments)-uc.config.WindowSize:]
+		}
+		return segments
+	}
+
+	// Always keep the newest segment, even if it is longer than the window
+	first := len(segments) - 1
+	duration := segments[first].Duration
+	for first > 0 && duration+segments[first-1].Duration <= uc.config.DVRWindow.Seconds() {
+		first--
+		duration += segments[first].Duration
+	}
+	return segments[first:]
+}
+
+// awaitReload waits until a live rendition has the segment or part a blocking
+// reload asks for, or the stream ends
+func (uc *LiveUseCase) awaitReload(ctx context.Context, session *liveSession, rendition entity.Resolution, reload *LiveReload) error {
+	timeout := time.NewTimer(uc.blockingTimeout())
+	defer timeout.Stop()
+
+	for {
+		session.mu.Lock()
+		nextMSN := len(session.segments[rendition])
+		parts := len(session.parts[rendition])
+		ended := session.ended
+		updated := session.updated
+		session.mu.Unlock()
+
+		// Players may only ask for the next two segments
+		if reload.MSN > nextMSN+1 {
+			return fmt.Errorf("invalid reload: segment %d is too far ahead of segment %d", reload.MSN, nextMSN)
+		}
+		if ended || reload.MSN < nextMSN || (reload.MSN == nextMSN && reload.Part >= 0 && reload.Part < parts) {
+			return nil
+		}
+
+		select {
+		case <-updated:
+		case <-timeout.C:
+			return fmt.Errorf("live playlist update timed out")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// GetLiveSegment opens a segment or part of a live rendition for streaming.
+// A request for the part hinted in the playlist waits until it is written.
+func (uc *LiveUseCase) GetLiveSegment(
+	ctx context.Context,
+	streamID string,
+	rendition entity.Resolution,
+	fileName, byteRange, ifNoneMatch string,
+) (*entity.StoredObject, error) {
+	session, err := uc.session(streamID)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := time.NewTimer(uc.blockingTimeout())
+	defer timeout.Stop()
+
+	for {
+		session.mu.Lock()
+		url := liveFileURL(session, rendition, fileName)
+		hinted := !session.ended && fileName == livePartFileName(session.handled[rendition])
+		updated := session.updated
+		session.mu.Unlock()
+
+		if url != "" {
+			return uc.storageRepo.OpenFile(ctx, url, byteRange, ifNoneMatch)
+		}
+		if !hinted || uc.config.PartDuration == 0 {
+			return nil, fmt.Errorf("segment %s not found for live stream %s", fileName, streamID)
+		}
+
+		select {
+		case <-updated:
+		case <-timeout.C:
+			return nil, fmt.Errorf("segment %s not found for live stream %s", fileName, streamID)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// liveParts returns the playlist entries of low-latency parts
+func (uc *LiveUseCase) liveParts(
+	ctx context.Context,
+	streamID string,
+	rendition entity.Resolution,
+	parts []*entity.LivePart,
+	expiresAt time.Time,
+	token string,
+) ([]hls.Part, error) {
+	var entries []hls.Part
+	for _, part := range parts {
+		uri, err := uc.liveObjectURI(ctx, streamID, rendition, part.FileName, part.URL, expiresAt, token)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate part URL: %w", err)
+		}
+
+		// The encoder starts every part with a keyframe
+		entries = append(entries, hls.Part{
+			Duration:    part.Duration,
+			URI:         uri,
+			Independent: true,
+		})
+	}
+	return entries, nil
+}
+
+// liveObjectURI returns the playlist URI of a stored file of a live rendition
+func (uc *LiveUseCase) liveObjectURI(
+	ctx context.Context,
+	streamID string,
+	rendition entity.Resolution,
+	fileName, storedURL string,
+	expiresAt time.Time,
+	token string,
+) (string, error) {
+	return uc.urls.objectURI(ctx, storedURL, liveURI(streamID, fmt.Sprintf("%s/%s", rendition, fileName)), expiresAt, token)
+}
+
+// blockingTimeout returns how long blocking requests wait for new media,
+// three target durations as LL-HLS asks for
+func (uc *LiveUseCase) blockingTimeout() time.Duration {
+	return time.Duration(3 * math.Ceil(uc.config.SegmentDuration) * float64(time.Second))
+}
+
+// session returns the session of a stream that is being published or has just ended
+func (uc *LiveUseCase) session(streamID string) (*liveSession, error) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	session, ok := uc.sessions[streamID]
+	if !ok {
+		return nil, fmt.Errorf("live session for stream %s not found", streamID)
+	}
+	return session, nil
+}
+
+// removeSession forgets the session of a stream, unless a new broadcast replaced it
+func (uc *LiveUseCase) removeSession(streamID string, session *liveSession) {
+	uc.mu.Lock()
+	if uc.sessions[streamID] == session {
+		delete(uc.sessions, streamID)
+	}
+	uc.mu.Unlock()
+}
+
+// inLadder reports whether a rendition is part of the live ladder
+func (uc *LiveUseCase) inLadder(rendition entity.Resolution) bool {
+	for _, profile := range uc.config.Ladder {
+		if profile.Name == rendition {
+			return true
+		}
+	}
+	return false
+}
+
+// liveFileURL returns the stored URL of a segment or part of a live rendition,
+// empty if it hasn't been uploaded. The caller must hold the session's mu.
+func liveFileURL(session *liveSession, rendition entity.Resolution, fileName string) string {
+	for _, part := range session.parts[rendition] {
+		if part.FileName == fileName {
+			return part.URL
+		}
+	}
+
+	segments := session.segments[rendition]
+	for i := len(segments) - 1; i >= 0; i-- {
+		if segments[i].FileName == fileName {
+			return segments[i].URL
+		}
+		for _, part := range segments[i].Parts {
+			if part.FileName == fileName {
+				return part.URL
+			}
+		}
+	}
+	return ""
+}
+
+// livePartFileName returns the file name of the part with the given index in a live rendition
+func livePartFileName(index int) string {
+	return fmt.Sprintf("part_%05d.ts", index)
+}
+
+// liveURI returns the API URI of a resource of a live stream
+func liveURI(streamID, uri string) string {
+	return fmt.Sprintf("/api/v1/live/%s/%s", streamID, uri)
+}
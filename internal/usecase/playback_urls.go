@@ -0,0 +1,73 @@
+package usecase
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+	"cams.dev/video_upload_backend/internal/domain/repository"
+	"cams.dev/video_upload_backend/internal/infrastructure/auth"
+)
+
+// PlaybackConfig controls the URIs written into playlists
+type PlaybackConfig struct {
+	URLMode   entity.PlaybackURLMode
+	URLExpiry time.Duration
+}
+
+// playbackURLs builds the URIs that playlists use for stored objects and API resources
+type playbackURLs struct {
+	storageRepo repository.StorageRepository
+	urlSigner   *auth.URLSigner
+	config      PlaybackConfig
+}
+
+// newPlaybackURLs creates a playlist URI builder
+func newPlaybackURLs(
+	storageRepo repository.StorageRepository,
+	urlSigner *auth.URLSigner,
+	config PlaybackConfig,
+) *playbackURLs {
+	return &playbackURLs{
+		storageRepo: storageRepo,
+		urlSigner:   urlSigner,
+		config:      config,
+	}
+}
+
+// expiresAt returns the expiry of URIs written into a playlist now
+func (p *playbackURLs) expiresAt() time.Time {
+	return time.Now().Add(p.config.URLExpiry)
+}
+
+// objectURI returns the URI of a stored object according to the playback URL
+// mode. apiPath is the API path that streams the object for signed URLs.
+func (p *playbackURLs) objectURI(
+	ctx context.Context,
+	storedURL, apiPath string,
+	expiresAt time.Time,
+	token string,
+) (string, error) {
+	switch p.config.URLMode {
+	case entity.PlaybackURLPresigned:
+		return p.storageRepo.GeneratePresignedURL(ctx, storedURL, "", time.Until(expiresAt))
+	case entity.PlaybackURLSigned:
+		return p.apiURI(apiPath, apiPath, expiresAt, token), nil
+	default:
+		return storedURL, nil
+	}
+}
+
+// apiURI adds the query string that authorizes players to fetch an API URI.
+// Signed URLs carry the signature of apiPath, the absolute path uri resolves
+// to. Otherwise the playback token of the request is passed along, if any.
+func (p *playbackURLs) apiURI(uri, apiPath string, expiresAt time.Time, token string) string {
+	if p.config.URLMode == entity.PlaybackURLSigned {
+		return uri + "?" + p.urlSigner.SignedQuery(apiPath, expiresAt)
+	}
+	if token != "" {
+		return uri + "?" + url.Values{"token": {token}}.Encode()
+	}
+	return uri
+}
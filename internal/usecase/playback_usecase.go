@@ -0,0 +1,125 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+	"cams.dev/video_upload_backend/internal/domain/repository"
+	"cams.dev/video_upload_backend/internal/infrastructure/auth"
+)
+
+// PlaybackTokenInput represents input for minting a playback token. VideoID
+// is the ID of the live stream for live stream tokens.
+type PlaybackTokenInput struct {
+	VideoID         string
+	UserID          string
+	ExpiresIn       time.Duration
+	AllowedReferrer string
+	AllowedIP       string
+}
+
+// PlaybackUseCase mints playback tokens for embedded players
+type PlaybackUseCase struct {
+	videoRepo     repository.VideoRepository
+	liveRepo      repository.LiveStreamRepository
+	jwtService    *auth.JWTService
+	defaultExpiry time.Duration
+	maxExpiry     time.Duration
+}
+
+// NewPlaybackUseCase creates a new playback use case instance. Tokens last
+// defaultExpiry unless requested otherwise, and never longer than maxExpiry.
+func NewPlaybackUseCase(
+	videoRepo repository.VideoRepository,
+	liveRepo repository.LiveStreamRepository,
+	jwtService *auth.JWTService,
+	defaultExpiry time.Duration,
+	maxExpiry time.Duration,
+) *PlaybackUseCase {
+	return &PlaybackUseCase{
+		videoRepo:     videoRepo,
+		liveRepo:      liveRepo,
+		jwtService:    jwtService,
+		defaultExpiry: defaultExpiry,
+		maxExpiry:     maxExpiry,
+	}
+}
+
+// CreatePlaybackToken mints a token that lets a player stream one of the user's videos
+func (uc *PlaybackUseCase) CreatePlaybackToken(ctx context.Context, input PlaybackTokenInput) (*entity.PlaybackToken, error) {
+	video, err := uc.videoRepo.GetByID(ctx, input.VideoID)
+	if err != nil {
+		return nil, err
+	}
+	if video.UserID != input.UserID {
+		return nil, fmt.Errorf("forbidden: video %s belongs to another user", video.ID)
+	}
+
+	return uc.mintToken(&entity.PlaybackToken{VideoID: video.ID}, input)
+}
+
+// CreateLivePlaybackToken mints a token that lets a player watch one of the
+// user's live streams. Live playlists are addressed by the stream, not by the
+// video its recording becomes.
+func (uc *PlaybackUseCase) CreateLivePlaybackToken(ctx context.Context, input PlaybackTokenInput) (*entity.PlaybackToken, error) {
+	stream, err := uc.liveRepo.GetByID(ctx, input.VideoID)
+	if err != nil {
+		return nil, err
+	}
+	if stream.UserID != input.UserID {
+		return nil, fmt.Errorf("forbidden: live stream %s belongs to another user", stream.ID)
+	}
+
+	return uc.mintToken(&entity.PlaybackToken{LiveStreamID: stream.ID}, input)
+}
+
+// mintToken applies the restrictions of a playback token request and signs the token
+func (uc *PlaybackUseCase) mintToken(playbackToken *entity.PlaybackToken, input PlaybackTokenInput) (*entity.PlaybackToken, error) {
+	expiresIn := input.ExpiresIn
+	if expiresIn == 0 {
+		expiresIn = uc.defaultExpiry
+	}
+	if expiresIn < 0 || expiresIn > uc.maxExpiry {
+		return nil, fmt.Errorf("invalid expiry: tokens last at most %s", uc.maxExpiry)
+	}
+
+	referrer, err := referrerHost(input.AllowedReferrer)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.AllowedIP != "" && net.ParseIP(input.AllowedIP) == nil {
+		return nil, fmt.Errorf("invalid allowed IP: %s", input.AllowedIP)
+	}
+
+	playbackToken.AllowedReferrer = referrer
+	playbackToken.AllowedIP = input.AllowedIP
+	playbackToken.ExpiresAt = time.Now().Add(expiresIn)
+
+	playbackToken.Token, err = uc.jwtService.GeneratePlaybackToken(playbackToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign playback token: %w", err)
+	}
+
+	return playbackToken, nil
+}
+
+// referrerHost reduces an allowed referrer, given as a host or a URL, to its host
+func referrerHost(referrer string) (string, error) {
+	referrer = strings.TrimSpace(referrer)
+	if referrer == "" || !strings.Contains(referrer, "://") {
+		return strings.ToLower(referrer), nil
+	}
+
+	parsed, err := url.Parse(referrer)
+	if err != nil || parsed.Host == "" {
+		return "", fmt.Errorf("invalid allowed referrer: %s", referrer)
+	}
+
+	return strings.ToLower(parsed.Host), nil
+}
@@ -0,0 +1,373 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"cams.dev/video_upload_backend/internal/domain/entity"
+	"cams.dev/video_upload_backend/internal/domain/repository"
+	"cams.dev/video_upload_backend/internal/infrastructure/auth"
+	"cams.dev/video_upload_backend/pkg/hls"
+)
+
+// EXT-X-MEDIA groups shared by all alternative renditions of a video
+const (
+	audioGroupID    = "audio"
+	subtitleGroupID = "subs"
+)
+
+// chapterDateRangeClass identifies chapter markers among EXT-X-DATERANGE tags
+const chapterDateRangeClass = "com.cams.chapter"
+
+// captionsDir is the storage directory of a video's caption files
+const captionsDir = "captions"
+
+// PlaylistUseCase builds HLS playlists from stored renditions and segments
+type PlaylistUseCase struct {
+	videoRepo     repository.VideoRepository
+	segmentRepo   repository.SegmentRepository
+	renditionRepo repository.RenditionRepository
+	captionRepo   repository.CaptionRepository
+	chapterRepo   repository.ChapterRepository
+	storageRepo   repository.StorageRepository
+	urls          *playbackURLs
+}
+
+// NewPlaylistUseCase creates a new playlist use case instance
+func NewPlaylistUseCase(
+	videoRepo repository.VideoRepository,
+	segmentRepo repository.SegmentRepository,
+	renditionRepo repository.RenditionRepository,
+	captionRepo repository.CaptionRepository,
+	chapterRepo repository.ChapterRepository,
+	storageRepo repository.StorageRepository,
+	urlSigner *auth.URLSigner,
+	config PlaybackConfig,
+) *PlaylistUseCase {
+	return &PlaylistUseCase{
+		videoRepo:     videoRepo,
+		segmentRepo:   segmentRepo,
+		renditionRepo: renditionRepo,
+		captionRepo:   captionRepo,
+		chapterRepo:   chapterRepo,
+		storageRepo:   storageRepo,
+		urls:          newPlaybackURLs(storageRepo, urlSigner, config),
+	}
+}
+
+// GetMasterPlaylist builds the master playlist listing every rendition of a
+// video. A playback token is carried over into the URIs of the playlist.
+func (uc *PlaylistUseCase) GetMasterPlaylist(ctx context.Context, videoID, token string) (string, error) {
+	if _, err := uc.videoRepo.GetByID(ctx, videoID); err != nil {
+		return "", err
+	}
+
+	renditions, err := uc.renditionRepo.GetByVideoID(ctx, videoID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get renditions: %w", err)
+	}
+	if len(renditions) == 0 {
+		return "", fmt.Errorf("video %s has no renditions", videoID)
+	}
+
+	playlist := &hls.MasterPlaylist{}
+	expiresAt := uc.urls.expiresAt()
+
+	// Audio tracks are exposed as alternative renditions of a single group
+	var defaultAudio *entity.Rendition
+	for _, rendition := range renditions {
+		if rendition.Type != entity.RenditionAudio {
+			continue
+		}
+		if defaultAudio == nil || rendition.IsDefault {
+			defaultAudio = rendition
+		}
+
+		media := hls.Media{
+			Type:       hls.MediaTypeAudio,
+			GroupID:    audioGroupID,
+			Name:       rendition.Label,
+			Language:   rendition.Language,
+			URI:        uc.playlistURI(videoID, mediaPlaylistURI(rendition.Name), expiresAt, token),
+			Default:    rendition.IsDefault,
+			Autoselect: true,
+		}
+		if rendition.Channels > 0 {
+			media.Channels = strconv.Itoa(rendition.Channels)
+		}
+		playlist.Media = append(playlist.Media, media)
+	}
+
+	// Caption tracks are exposed as a subtitles group
+	captions, err := uc.captionRepo.GetByVideoID(ctx, videoID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get captions: %w", err)
+	}
+	subtitles := ""
+	for _, caption := range captions {
+		subtitles = subtitleGroupID
+		playlist.Media = append(playlist.Media, hls.Media{
+			Type:       hls.MediaTypeSubtitles,
+			GroupID:    subtitleGroupID,
+			Name:       caption.Label,
+			Language:   caption.Language,
+			URI:        uc.playlistURI(videoID, captionPlaylistURI(caption.ID), expiresAt, token),
+			Default:    caption.IsDefault,
+			Autoselect: true,
+		})
+	}
+
+	for _, rendition := range renditions {
+		if rendition.Type != entity.RenditionVideo {
+			continue
+		}
+
+		variant := hls.Variant{
+			Bandwidth:        rendition.Bandwidth,
+			AverageBandwidth: rendition.AverageBandwidth,
+			Codecs:           rendition.Codecs,
+			Width:            rendition.Width,
+			Height:           rendition.Height,
+			VideoRange:       rendition.VideoRange,
+			Subtitles:        subtitles,
+			URI:              uc.playlistURI(videoID, mediaPlaylistURI(rendition.Name), expiresAt, token),
+		}
+		// Renditions encoded with their audio muxed in don't use the audio group
+		if defaultAudio != nil && !carriesAudio(rendition.Codecs) {
+			variant.Bandwidth += defaultAudio.Bandwidth
+			if variant.AverageBandwidth > 0 {
+				variant.AverageBandwidth += defaultAudio.AverageBandwidth
+			}
+			variant.Codecs += "," + defaultAudio.Codecs
+			variant.Audio = audioGroupID
+		}
+		playlist.Variants = append(playlist.Variants, variant)
+	}
+
+	// Audio-only fallback for clients on constrained networks
+	if defaultAudio != nil {
+		playlist.Variants = append(playlist.Variants, hls.Variant{
+			Bandwidth:        defaultAudio.Bandwidth,
+			AverageBandwidth: defaultAudio.AverageBandwidth,
+			Codecs:           defaultAudio.Codecs,
+			Audio:            audioGroupID,
+			URI:              uc.playlistURI(videoID, mediaPlaylistURI(defaultAudio.Name), expiresAt, token),
+		})
+	}
+
+	return playlist.String(), nil
+}
+
+// GetMediaPlaylist builds the media playlist for a single rendition of a video.
+// userID and scoped identify the caller as for KeyUseCase.GetKey, since the
+// playlist of an encrypted video hands out the URI of its key.
+func (uc *PlaylistUseCase) GetMediaPlaylist(
+	ctx context.Context,
+	videoID string,
+	rendition entity.Resolution,
+	userID string,
+	scoped bool,
+	token string,
+) (string, error) {
+	video, err := uc.videoRepo.GetByID(ctx, videoID)
+	if err != nil {
+		return "", err
+	}
+	if video.Metadata.Encryption.Enabled() {
+		if err := authorizeKey(video, userID, scoped); err != nil {
+			return "", err
+		}
+	}
+
+	segments, err := uc.segmentRepo.GetByVideoIDAndResolution(ctx, videoID, rendition)
+	if err != nil {
+		return "", fmt.Errorf("failed to get segments: %w", err)
+	}
+	if len(segments) == 0 {
+		return "", fmt.Errorf("rendition %s not found for video %s", rendition, videoID)
+	}
+
+	renditions, err := uc.renditionRepo.GetByVideoID(ctx, videoID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get renditions: %w", err)
+	}
+
+	playlist := &hls.MediaPlaylist{VOD: true}
+	expiresAt := uc.urls.expiresAt()
+	for _, r := range renditions {
+		if r.Name == rendition && r.InitURL != "" {
+			playlist.MapURI, err = uc.urls.objectURI(ctx, r.InitURL, segmentURI(videoID, string(rendition), path.Base(r.InitURL)), expiresAt, token)
+			if err != nil {
+				return "", fmt.Errorf("failed to sign initialization segment URL: %w", err)
+			}
+		}
+	}
+	if method := video.Metadata.Encryption; method.Enabled() {
+		playlist.Key = &hls.Key{
+			Method: string(method.ForSegments(playlist.MapURI != "")),
+			URI:    uc.urls.apiURI(keyURI(videoID), keyURI(videoID), expiresAt, token),
+		}
+	}
+	for _, segment := range segments {
+		uri, err := uc.urls.objectURI(ctx, segment.URL, segmentURI(videoID, string(rendition), segment.FileName), expiresAt, token)
+		if err != nil {
+			return "", fmt.Errorf("failed to sign segment URL: %w", err)
+		}
+		playlist.Segments = append(playlist.Segments, hls.Segment{
+			Duration: segment.Duration,
+			URI:      uri,
+		})
+	}
+
+	// Chapters are date ranges on a timeline anchored at the upload time
+	chapters, err := uc.chapterRepo.GetByVideoID(ctx, videoID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get chapters: %w", err)
+	}
+	if len(chapters) > 0 {
+		playlist.ProgramDateTime = video.CreatedAt
+		for _, chapter := range chapters {
+			playlist.DateRanges = append(playlist.DateRanges, hls.DateRange{
+				ID:        "chapter-" + chapter.ID,
+				Class:     chapterDateRangeClass,
+				StartDate: video.CreatedAt.Add(time.Duration(chapter.Start * float64(time.Second))),
+				Duration:  chapter.End - chapter.Start,
+				Attributes: map[string]string{
+					"X-TITLE": chapter.Title,
+				},
+			})
+		}
+	}
+
+	return playlist.String(), nil
+}
+
+// GetCaptionPlaylist builds the subtitle media playlist for a caption track. The
+// whole WebVTT file is served as a single segment spanning the video.
+func (uc *PlaylistUseCase) GetCaptionPlaylist(ctx context.Context, videoID, captionID, token string) (string, error) {
+	caption, err := uc.captionRepo.GetByID(ctx, captionID)
+	if err != nil {
+		return "", err
+	}
+	if caption.VideoID != videoID {
+		return "", fmt.Errorf("caption with ID %s not found", captionID)
+	}
+
+	video, err := uc.videoRepo.GetByID(ctx, videoID)
+	if err != nil {
+		return "", err
+	}
+
+	uri, err := uc.urls.objectURI(ctx, caption.URL, segmentURI(videoID, captionsDir, path.Base(caption.URL)), uc.urls.expiresAt(), token)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign caption URL: %w", err)
+	}
+
+	playlist := &hls.MediaPlaylist{
+		VOD: true,
+		Segments: []hls.Segment{
+			{Duration: video.Duration, URI: uri},
+		},
+	}
+
+	return playlist.String(), nil
+}
+
+// GetSegment opens an object referenced by the playlists of a video: a media
+// or initialization segment of a rendition, or a caption file. Only objects
+// recorded for the video can be opened. byteRange and ifNoneMatch are optional
+// HTTP Range and If-None-Match header values.
+func (uc *PlaylistUseCase) GetSegment(
+	ctx context.Context,
+	videoID, dir, fileName string,
+	byteRange, ifNoneMatch string,
+) (*entity.StoredObject, error) {
+	storedURL, err := uc.storedObjectURL(ctx, videoID, dir, fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.storageRepo.OpenFile(ctx, storedURL, byteRange, ifNoneMatch)
+}
+
+// storedObjectURL finds the stored URL of an object referenced by a video's playlists.
+// Linked duplicates keep pointing at the storage of their source.
+func (uc *PlaylistUseCase) storedObjectURL(ctx context.Context, videoID, dir, fileName string) (string, error) {
+	if _, err := uc.videoRepo.GetByID(ctx, videoID); err != nil {
+		return "", err
+	}
+
+	if dir == captionsDir {
+		captions, err := uc.captionRepo.GetByVideoID(ctx, videoID)
+		if err != nil {
+			return "", fmt.Errorf("failed to get captions: %w", err)
+		}
+		for _, caption := range captions {
+			if path.Base(caption.URL) == fileName {
+				return caption.URL, nil
+			}
+		}
+		return "", fmt.Errorf("caption file %s not found for video %s", fileName, videoID)
+	}
+
+	renditions, err := uc.renditionRepo.GetByVideoID(ctx, videoID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get renditions: %w", err)
+	}
+	for _, rendition := range renditions {
+		if string(rendition.Name) == dir && rendition.InitURL != "" && path.Base(rendition.InitURL) == fileName {
+			return rendition.InitURL, nil
+		}
+	}
+
+	segments, err := uc.segmentRepo.GetByVideoIDAndResolution(ctx, videoID, entity.Resolution(dir))
+	if err != nil {
+		return "", fmt.Errorf("failed to get segments: %w", err)
+	}
+	for _, segment := range segments {
+		if segment.FileName == fileName {
+			return segment.URL, nil
+		}
+	}
+
+	return "", fmt.Errorf("segment %s/%s not found for video %s", dir, fileName, videoID)
+}
+
+// playlistURI returns the URI of a playlist relative to the master playlist
+func (uc *PlaylistUseCase) playlistURI(videoID, uri string, expiresAt time.Time, token string) string {
+	return uc.urls.apiURI(uri, fmt.Sprintf("/api/v1/videos/%s/%s", videoID, uri), expiresAt, token)
+}
+
+// mediaPlaylistURI returns the media playlist URI of a rendition relative to the master playlist
+func mediaPlaylistURI(rendition entity.Resolution) string {
+	return fmt.Sprintf("%s/playlist.m3u8", rendition)
+}
+
+// segmentURI returns the API URI of a stored object referenced by a video's playlists
+func segmentURI(videoID, dir, fileName string) string {
+	return fmt.Sprintf("/api/v1/videos/%s/%s/%s", videoID, dir, fileName)
+}
+
+// keyURI returns the URI of a video's content key
+func keyURI(videoID string) string {
+	return fmt.Sprintf("/api/v1/keys/%s", videoID)
+}
+
+// captionPlaylistURI returns the subtitle playlist URI of a caption relative to the master playlist
+func captionPlaylistURI(captionID string) string {
+	return fmt.Sprintf("captions/%s/playlist.m3u8", captionID)
+}
+
+// carriesAudio reports whether a CODECS value lists an audio codec
+func carriesAudio(codecs string) bool {
+	for _, codec := range strings.Split(codecs, ",") {
+		if strings.HasPrefix(strings.TrimSpace(codec), "mp4a.") {
+			return true
+		}
+	}
+	return false
+}
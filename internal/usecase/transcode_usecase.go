@@ -2,7 +2,10 @@ package usecase
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"time"
@@ -11,31 +14,139 @@ import (
 
 	"cams.dev/video_upload_backend/internal/domain/entity"
 	"cams.dev/video_upload_backend/internal/domain/repository"
+	"cams.dev/video_upload_backend/pkg/hls"
 )
 
+// segmentDuration is the length in seconds of each HLS segment
+const segmentDuration = 10
+
+// Per-title complexity probe settings. Test encodes of typical content at 720p
+// CRF 23 average complexityReferenceBitrate, which maps to a factor of 1.
+const (
+	complexitySamples          = 5
+	complexitySampleDuration   = 4.0 // seconds
+	complexityReferenceBitrate = 2000000
+	minComplexityFactor        = 0.3
+	maxComplexityFactor        = 1.8
+)
+
+// aacCodecString is the RFC 6381 codec string of AAC-LC audio renditions
+const aacCodecString = "mp4a.40.2"
+
+// TranscodeConfig holds settings for the transcoding pipeline
+type TranscodeConfig struct {
+	Loudnorm       bool // Enables two-pass loudness normalization
+	LoudnessTarget entity.LoudnessTarget
+	HDRRendition   bool // Adds a 10-bit HEVC rendition keeping HDR for HDR sources
+	Ladder         []entity.TranscodeProfile
+	QualityMetrics bool              // Scores each video rendition against the source with VMAF, PSNR and SSIM
+	PerTitle       bool              // Scales ladder bitrates by the complexity of each video
+	Watermark      *entity.Watermark // Default overlay for videos uploaded without one
+	AudioAssets    bool              // Offers the default audio track as MP3, M4A and waveform downloads
+	WaveformMs     int               // Milliseconds summarized by each waveform peak
+	MP4Downloads   bool              // Keeps each video rendition as a progressive download MP4
+	SceneDetection bool              // Records scene changes and creates chapters from them
+	SceneThreshold float64           // Scene change score (0 to 1) that starts a new scene
+	MinChapter     float64           // Shortest automatic chapter in seconds
+	QC             bool              // Checks for black frames, frozen frames and silence
+	QCThresholds   entity.QCThresholds
+	PerceptualHash bool                    // Fingerprints the picture to find near-duplicate uploads
+	Encryption     entity.EncryptionMethod // Default segment encryption for videos uploaded without a choice
+	KeySealing     bool                    // Content keys can be sealed, so uploads may ask for encryption
+}
+
 // TranscodeUseCase handles video transcoding operations
 type TranscodeUseCase struct {
 	videoRepo     repository.VideoRepository
 	segmentRepo   repository.SegmentRepository
+	renditionRepo repository.RenditionRepository
+	captionRepo   repository.CaptionRepository
+	assetRepo     repository.AssetRepository
+	chapterRepo   repository.ChapterRepository
+	qcRepo        repository.QCRepository
+	keyRepo       repository.KeyRepository
 	storageRepo   repository.StorageRepository
 	transcodeRepo repository.TranscodeRepository
+	config        TranscodeConfig
 }
 
 // NewTranscodeUseCase creates a new transcode use case instance
 func NewTranscodeUseCase(
 	videoRepo repository.VideoRepository,
 	segmentRepo repository.SegmentRepository,
+	renditionRepo repository.RenditionRepository,
+	captionRepo repository.CaptionRepository,
+	assetRepo repository.AssetRepository,
+	chapterRepo repository.ChapterRepository,
+	qcRepo repository.QCRepository,
+	keyRepo repository.KeyRepository,
 	storageRepo repository.StorageRepository,
 	transcodeRepo repository.TranscodeRepository,
+	config TranscodeConfig,
 ) *TranscodeUseCase {
 	return &TranscodeUseCase{
 		videoRepo:     videoRepo,
 		segmentRepo:   segmentRepo,
+		renditionRepo: renditionRepo,
+		captionRepo:   captionRepo,
+		assetRepo:     assetRepo,
+		chapterRepo:   chapterRepo,
+		qcRepo:        qcRepo,
+		keyRepo:       keyRepo,
 		storageRepo:   storageRepo,
 		transcodeRepo: transcodeRepo,
+		config:        config,
 	}
 }
 
+// ProcessAsync processes a stored video in the background and records the final
+// status. prepare, if set, runs first and must leave the source at video.OriginalURL.
+func (uc *TranscodeUseCase) ProcessAsync(
+	video *entity.Video,
+	prepare func(ctx context.Context, video *entity.Video) error,
+) {
+	go func() {
+		// Create a new context since the request context will be cancelled
+		bgCtx := context.Background()
+
+		// Update status to processing
+		video.Status = entity.StatusProcessing
+		if err := uc.videoRepo.Update(bgCtx, video); err != nil {
+			// Log error but continue
+			fmt.Printf("Failed to update video status: %v\n", err)
+		}
+
+		// Produce the source, e.g. by editing other videos
+		var err error
+		if prepare != nil {
+			err = prepare(bgCtx, video)
+		}
+
+		// Process the video (transcode and segment)
+		if err == nil {
+			err = uc.ProcessVideo(bgCtx, video.ID, video.OriginalURL)
+		}
+
+		// Reload the video so the details stored during processing aren't overwritten
+		if latest, getErr := uc.videoRepo.GetByID(bgCtx, video.ID); getErr == nil {
+			video = latest
+		}
+
+		// Update status based on the result
+		if err != nil {
+			video.Status = entity.StatusFailed
+			fmt.Printf("Video processing failed: %v\n", err)
+		} else {
+			video.Status = entity.StatusComplete
+		}
+
+		// Update the video status
+		if updateErr := uc.videoRepo.Update(bgCtx, video); updateErr != nil {
+			fmt.Printf("Failed to update video status: %v\n", updateErr)
+		}
+	}()
+}
+
 // ProcessVideo handles video transcoding and segmentation
 func (uc *TranscodeUseCase) ProcessVideo(ctx context.Context, videoID, videoURL string) error {
 	// Retrieve video info
@@ -64,85 +175,224 @@ func (uc *TranscodeUseCase) ProcessVideo(ctx context.Context, videoID, videoURL
 	}
 
 	// Get video information
-	duration, width, height, err := uc.transcodeRepo.GetVideoInfo(ctx, originalVideoPath)
+	info, err := uc.transcodeRepo.Probe(ctx, originalVideoPath)
 	if err != nil {
 		return fmt.Errorf("failed to get video info: %w", err)
 	}
+	duration := info.Duration
+
+	// Fingerprint the picture so re-encoded copies can be found
+	if uc.config.PerceptualHash {
+		perceptualHash, err := uc.transcodeRepo.PerceptualHash(ctx, originalVideoPath, duration)
+		if err != nil {
+			// Duplicate detection is optional, so don't fail the whole video
+			fmt.Printf("Failed to compute perceptual hash: %v\n", err)
+		} else {
+			video.PerceptualHash = perceptualHash
+		}
+	}
+
+	// Measure loudness of every audio stream for the second normalization pass
+	loudness := make(map[int]*entity.LoudnessNormalization)
+	if uc.config.Loudnorm {
+		video.Metadata.Loudness = nil
+		for _, stream := range info.AudioStreams {
+			measured, err := uc.transcodeRepo.MeasureLoudness(ctx, originalVideoPath, stream.Index, uc.config.LoudnessTarget)
+			if err != nil {
+				// Leave the stream untouched rather than failing the whole video
+				fmt.Printf("Failed to measure loudness of audio stream %d: %v\n", stream.Index, err)
+				continue
+			}
+			loudness[stream.Index] = &entity.LoudnessNormalization{
+				Target:   uc.config.LoudnessTarget,
+				Measured: *measured,
+			}
+			video.Metadata.Loudness = append(video.Metadata.Loudness, *measured)
+		}
+	}
+
+	// HDR sources are tone mapped for the SDR ladder
+	color := info.Color
+	var toneMap *entity.ColorInfo
+	video.Metadata.VideoRange = color.HDRFormat()
+	if color.IsHDR() {
+		video.Metadata.Color = &color
+		toneMap = &color
+	}
+
+	// Probe content complexity to scale the ladder bitrates for this title
+	complexityFactor := 1.0
+	if uc.config.PerTitle {
+		complexity, err := uc.probeComplexity(ctx, originalVideoPath, duration, toneMap)
+		if err != nil {
+			// Fall back to the static ladder
+			fmt.Printf("Failed to probe complexity: %v\n", err)
+		} else {
+			video.Metadata.Complexity = complexity
+			complexityFactor = complexity.Factor
+		}
+	}
+
+	// Check the master for defects viewers would notice
+	if uc.config.QC {
+		if err := uc.runQC(ctx, videoID, originalVideoPath, duration, len(info.AudioStreams) > 0); err != nil {
+			// The report is informational, so don't fail the whole video
+			fmt.Printf("Failed to run QC: %v\n", err)
+		}
+	}
+
+	// Record scene changes and suggest chapters at the major ones
+	if uc.config.SceneDetection {
+		scenes, err := uc.transcodeRepo.DetectScenes(ctx, originalVideoPath, uc.config.SceneThreshold)
+		if err != nil {
+			// Chapters are optional, so don't fail the whole video
+			fmt.Printf("Failed to detect scenes: %v\n", err)
+		} else {
+			video.Metadata.Scenes = scenes
+			if err := uc.createSceneChapters(ctx, videoID, scenes, duration); err != nil {
+				fmt.Printf("Failed to create chapters: %v\n", err)
+			}
+		}
+	}
+
+	// Overlay the video's watermark, or the default one, on every video rendition
+	if video.Metadata.Watermark == nil && uc.config.Watermark != nil {
+		watermark := *uc.config.Watermark
+		video.Metadata.Watermark = &watermark
+	}
+	var watermarkPath string
+	if video.Metadata.Watermark != nil {
+		watermarkPath, err = uc.downloadWatermark(ctx, video.Metadata.Watermark, tempDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Encrypt the segments with a new content key
+	if video.Metadata.Encryption == "" {
+		video.Metadata.Encryption = uc.config.Encryption
+	}
+	var key *entity.EncryptionKey
+	if video.Metadata.Encryption.Enabled() {
+		key, err = uc.createKey(ctx, videoID, video.Metadata.Encryption)
+		if err != nil {
+			return err
+		}
+	}
 
 	// Update video with duration and resolution info
 	video.Duration = duration
-	video.ResolutionInfo = fmt.Sprintf("%dx%d", width, height)
+	video.ResolutionInfo = fmt.Sprintf("%dx%d", info.Width, info.Height)
 	video.Status = entity.StatusTranscoded
 	if err := uc.videoRepo.Update(ctx, video); err != nil {
 		return fmt.Errorf("failed to update video info: %w", err)
 	}
 
-	// Process each required resolution
-	resolutions := []entity.Resolution{
-		entity.Resolution1080p,
-		entity.Resolution720p,
-	}
-
-	for _, resolution := range resolutions {
-		// Transcode to the target resolution
-		outputPath := filepath.Join(tempDir, fmt.Sprintf("%s.mp4", resolution))
+	// Preserve every source audio stream as its own audio-only rendition. Video
+	// renditions play them through the audio group, and the default track is
+	// muxed into the download MP4s.
+	defaultIndex := defaultAudioStream(info.AudioStreams)
+	var defaultAudioPath string
+	for _, stream := range info.AudioStreams {
+		name := entity.AudioRenditionName(stream.Index)
+		outputPath := filepath.Join(tempDir, fmt.Sprintf("%s.m4a", name))
 
-		if err := uc.transcodeRepo.Transcode(ctx, originalVideoPath, outputPath, resolution, 24); err != nil {
-			return fmt.Errorf("failed to transcode to %s: %w", resolution, err)
+		opts := entity.TranscodeOptions{Loudness: loudness[stream.Index]}
+		if err := uc.transcodeRepo.TranscodeAudio(ctx, originalVideoPath, outputPath, stream.Index, audioBitrate(stream.Channels), opts); err != nil {
+			return fmt.Errorf("failed to transcode audio track %d: %w", stream.Index, err)
 		}
-
-		// Segment the transcoded video
-		segmentsDirPath := filepath.Join(tempDir, string(resolution))
-		if err := os.MkdirAll(segmentsDirPath, 0755); err != nil {
-			return fmt.Errorf("failed to create segments directory: %w", err)
+		if stream.Index == defaultIndex {
+			defaultAudioPath = outputPath
 		}
 
-		// Create 10-second segments
-		segmentPattern := filepath.Join(segmentsDirPath, "segment_%03d.ts")
-		segmentFiles, err := uc.transcodeRepo.Segment(ctx, outputPath, 10, segmentPattern)
+		bitrate, err := uc.segmentAndUpload(ctx, videoID, name, outputPath, tempDir, duration, key)
 		if err != nil {
-			return fmt.Errorf("failed to segment video: %w", err)
+			return err
 		}
 
-		// Upload each segment and store metadata
-		for i, segmentPath := range segmentFiles {
-			segmentFileName := filepath.Base(segmentPath)
+		rendition := &entity.Rendition{
+			ID:               uuid.New().String(),
+			VideoID:          videoID,
+			Name:             name,
+			Type:             entity.RenditionAudio,
+			Bandwidth:        bitrate.Peak,
+			AverageBandwidth: bitrate.Average,
+			Codecs:           aacCodecString,
+			Language:         streamLanguage(stream.Language),
+			Label:            audioLabel(stream),
+			Channels:         stream.Channels,
+			IsDefault:        stream.Index == defaultIndex,
+			CreatedAt:        time.Now(),
+		}
+		if err := uc.renditionRepo.Create(ctx, rendition); err != nil {
+			return fmt.Errorf("failed to create rendition record: %w", err)
+		}
+	}
 
-			// Read segment file
-			segmentData, err := os.ReadFile(segmentPath)
-			if err != nil {
-				return fmt.Errorf("failed to read segment file: %w", err)
-			}
+	// Encode every rung of the ladder, e.g. the same resolutions in several codecs
+	ladder := uc.config.Ladder
+	if len(ladder) == 0 {
+		ladder = entity.DefaultLadder([]entity.VideoCodec{entity.CodecH264}, entity.RateControlCRF, "", "")
+	}
 
-			// Upload to storage
-			storagePath := fmt.Sprintf("videos/%s/%s/%s", videoID, resolution, segmentFileName)
-			segmentURL, err := uc.storageRepo.UploadFile(ctx, storagePath, segmentData, "video/mp2t")
-			if err != nil {
-				return fmt.Errorf("failed to upload segment: %w", err)
-			}
+	for _, profile := range ladder {
+		if uc.config.PerTitle {
+			profile.RateControl = profile.RateControl.Scaled(complexityFactor)
+		}
 
-			// Create segment record
-			segment := &entity.Segment{
-				ID:           uuid.New().String(),
-				VideoID:      videoID,
-				FileName:     segmentFileName,
-				URL:          segmentURL,
-				Resolution:   resolution,
-				StartTime:    float64(i) * 10.0, // 10-second segments
-				Duration:     10.0,
-				SegmentIndex: i,
-				CreatedAt:    time.Now(),
-			}
+		// Audio is played from the audio group, so renditions carry only video
+		opts := entity.TranscodeOptions{
+			NoAudio:          len(info.AudioStreams) > 0,
+			ToneMap:          toneMap,
+			Watermark:        video.Metadata.Watermark,
+			WatermarkPath:    watermarkPath,
+			KeyframeInterval: segmentDuration,
+		}
+		if err := uc.createVideoRendition(ctx, videoID, originalVideoPath, tempDir, duration, profile, opts, "SDR", defaultAudioPath, key); err != nil {
+			return err
+		}
+	}
 
-			// For the last segment, adjust duration if needed
-			if i == len(segmentFiles)-1 && duration-float64(i)*10.0 < 10.0 {
-				segment.Duration = duration - float64(i)*10.0
-			}
+	// Keep an HDR HEVC rendition of the top resolution for capable clients
+	if color.IsHDR() && uc.config.HDRRendition {
+		profile := entity.TranscodeProfile{
+			Name:        entity.HDRRenditionName(entity.Resolution1080p),
+			Resolution:  entity.Resolution1080p,
+			Codec:       entity.CodecHEVC,
+			FPS:         24,
+			RateControl: uc.hdrRateControl(),
+		}
+		if uc.config.PerTitle {
+			profile.RateControl = profile.RateControl.Scaled(complexityFactor)
+		}
+		opts := entity.TranscodeOptions{
+			NoAudio:          len(info.AudioStreams) > 0,
+			HDR:              &color,
+			Watermark:        video.Metadata.Watermark,
+			WatermarkPath:    watermarkPath,
+			KeyframeInterval: segmentDuration,
+		}
+		if err := uc.createVideoRendition(ctx, videoID, originalVideoPath, tempDir, duration, profile, opts, color.HDRFormat(), defaultAudioPath, key); err != nil {
+			return err
+		}
+	}
 
-			// Save segment metadata
-			if err := uc.segmentRepo.Create(ctx, segment); err != nil {
-				return fmt.Errorf("failed to create segment record: %w", err)
-			}
+	// Offer the default audio track as downloads for audio editors
+	if uc.config.AudioAssets && len(info.AudioStreams) > 0 {
+		if err := uc.createAudioAssets(ctx, videoID, originalVideoPath, tempDir, defaultIndex, loudness[defaultIndex]); err != nil {
+			// Downloads are extras, so don't fail the whole video
+			fmt.Printf("Failed to create audio assets: %v\n", err)
+		}
+	}
+
+	// Extract embedded text subtitles as WebVTT caption tracks
+	for _, stream := range info.SubtitleStreams {
+		if !stream.IsText() {
+			continue
+		}
+		if err := uc.extractCaption(ctx, videoID, originalVideoPath, tempDir, stream); err != nil {
+			// Captions are optional, so don't fail the whole video
+			fmt.Printf("Failed to extract subtitle stream %d: %v\n", stream.Index, err)
 		}
 	}
 
@@ -168,3 +418,781 @@ func (uc *TranscodeUseCase) ProcessVideo(ctx context.Context, videoID, videoURL
 	video.Status = entity.StatusComplete
 	return uc.videoRepo.Update(ctx, video)
 }
+
+// createVideoRendition transcodes the source to a ladder profile, uploads its segments
+// and stores the rendition. Codecs other than H.264 are packaged as fragmented MP4.
+// audioPath, if set, is muxed into the download MP4 of a rendition encoded without audio.
+func (uc *TranscodeUseCase) createVideoRendition(
+	ctx context.Context,
+	videoID string,
+	inputPath string,
+	tempDir string,
+	duration float64,
+	profile entity.TranscodeProfile,
+	opts entity.TranscodeOptions,
+	videoRange string,
+	audioPath string,
+	key *entity.EncryptionKey,
+) error {
+	outputPath := filepath.Join(tempDir, fmt.Sprintf("%s.mp4", profile.Name))
+	if err := uc.transcodeRepo.Transcode(ctx, inputPath, outputPath, profile, opts); err != nil {
+		return fmt.Errorf("failed to transcode to %s: %w", profile.Name, err)
+	}
+
+	// Describe the encoded streams exactly as the encoder produced them
+	codecs, err := uc.transcodeRepo.CodecString(ctx, outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to get codecs of %s: %w", profile.Name, err)
+	}
+
+	// Keep the faststart MP4 for progressive download before it is segmented
+	if uc.config.MP4Downloads {
+		downloadPath := outputPath
+		if opts.NoAudio && audioPath != "" {
+			downloadPath = filepath.Join(tempDir, fmt.Sprintf("%s_download.mp4", profile.Name))
+			if err := uc.transcodeRepo.MuxAudio(ctx, outputPath, audioPath, downloadPath); err != nil {
+				return fmt.Errorf("failed to add audio to %s MP4: %w", profile.Name, err)
+			}
+		}
+		if err := uc.createMP4Asset(ctx, videoID, profile.Name, downloadPath); err != nil {
+			return err
+		}
+	}
+
+	// Score the encode against the source to help tune the ladder
+	var quality *entity.QualityScore
+	if uc.config.QualityMetrics {
+		quality, err = uc.transcodeRepo.MeasureQuality(ctx, outputPath, inputPath, profile, opts)
+		if err != nil {
+			// Scores are informational, so don't fail the whole video
+			fmt.Printf("Failed to measure quality of %s: %v\n", profile.Name, err)
+		}
+	}
+
+	var bitrate renditionBitrate
+	var initURL string
+	if profile.Codec.UsesFMP4() {
+		bitrate, initURL, err = uc.segmentAndUploadFMP4(ctx, videoID, profile.Name, outputPath, tempDir, duration, key)
+	} else {
+		bitrate, err = uc.segmentAndUpload(ctx, videoID, profile.Name, outputPath, tempDir, duration, key)
+	}
+	if err != nil {
+		return err
+	}
+
+	width, height := profile.Resolution.Dimensions()
+	rateControl := profile.RateControl
+	rendition := &entity.Rendition{
+		ID:               uuid.New().String(),
+		VideoID:          videoID,
+		Name:             profile.Name,
+		Type:             entity.RenditionVideo,
+		Width:            width,
+		Height:           height,
+		Bandwidth:        bitrate.Peak,
+		AverageBandwidth: bitrate.Average,
+		Codec:            profile.Codec,
+		Codecs:           codecs,
+		VideoRange:       videoRange,
+		InitURL:          initURL,
+		RateControl:      &rateControl,
+		Quality:          quality,
+		CreatedAt:        time.Now(),
+	}
+	if err := uc.renditionRepo.Create(ctx, rendition); err != nil {
+		return fmt.Errorf("failed to create rendition record: %w", err)
+	}
+
+	return nil
+}
+
+// hdrRateControl returns the rate control of the HDR rendition, following the mode
+// used by the rest of the ladder
+func (uc *TranscodeUseCase) hdrRateControl() entity.RateControl {
+	mode := entity.RateControlCRF
+	if len(uc.config.Ladder) > 0 {
+		mode = uc.config.Ladder[0].RateControl.Mode
+	}
+
+	rc := entity.DefaultRateControl(mode, entity.Resolution1080p, entity.CodecHEVC)
+	// 10-bit HDR needs more bits than an SDR HEVC encode
+	rc.Bitrate = rc.Bitrate * 5 / 4
+	rc.MaxBitrate = rc.MaxBitrate * 5 / 4
+	rc.BufferSize = rc.BufferSize * 5 / 4
+
+	return rc
+}
+
+// BurnSubtitles encodes an H.264 MP4 of a processed video with a caption track
+// rendered into the picture and stores it as a downloadable asset
+func (uc *TranscodeUseCase) BurnSubtitles(
+	ctx context.Context,
+	video *entity.Video,
+	caption *entity.Caption,
+	resolution entity.Resolution,
+) (*entity.Asset, error) {
+	tempDir, err := os.MkdirTemp("", "video-burn-"+video.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Download the original video and the caption track
+	videoData, err := uc.storageRepo.GetFile(ctx, video.OriginalURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download video: %w", err)
+	}
+	originalVideoPath := filepath.Join(tempDir, "original.mp4")
+	if err := os.WriteFile(originalVideoPath, videoData, 0644); err != nil {
+		return nil, fmt.Errorf("failed to save video to temp dir: %w", err)
+	}
+
+	captionData, err := uc.storageRepo.GetFile(ctx, caption.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download caption: %w", err)
+	}
+	captionPath := filepath.Join(tempDir, "caption.vtt")
+	if err := os.WriteFile(captionPath, captionData, 0644); err != nil {
+		return nil, fmt.Errorf("failed to save caption to temp dir: %w", err)
+	}
+
+	// Match the picture and sound of the streaming renditions
+	opts := entity.TranscodeOptions{SubtitlePath: captionPath}
+	if video.Metadata.Color != nil && video.Metadata.Color.IsHDR() {
+		opts.ToneMap = video.Metadata.Color
+	}
+	if uc.config.Loudnorm && len(video.Metadata.Loudness) > 0 {
+		opts.Loudness = &entity.LoudnessNormalization{
+			Target:   uc.config.LoudnessTarget,
+			Measured: video.Metadata.Loudness[0],
+		}
+	}
+	if video.Metadata.Watermark != nil {
+		opts.Watermark = video.Metadata.Watermark
+		opts.WatermarkPath, err = uc.downloadWatermark(ctx, video.Metadata.Watermark, tempDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	profile := entity.TranscodeProfile{
+		Name:        entity.ProfileName(resolution, entity.CodecH264),
+		Resolution:  resolution,
+		Codec:       entity.CodecH264,
+		FPS:         24,
+		RateControl: entity.DefaultRateControl(entity.RateControlCRF, resolution, entity.CodecH264),
+	}
+
+	outputPath := filepath.Join(tempDir, "burned.mp4")
+	if err := uc.transcodeRepo.Transcode(ctx, originalVideoPath, outputPath, profile, opts); err != nil {
+		return nil, fmt.Errorf("failed to burn in subtitles: %w", err)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read burned-in video: %w", err)
+	}
+
+	storagePath := fmt.Sprintf("videos/%s/assets/subtitles_%s_%s.mp4", video.ID, caption.Language, resolution)
+	assetURL, err := uc.storageRepo.UploadFile(ctx, storagePath, data, "video/mp4")
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload burned-in video: %w", err)
+	}
+
+	asset := &entity.Asset{
+		VideoID:  video.ID,
+		Type:     entity.AssetBurnedSubtitles,
+		Name:     string(resolution),
+		Language: caption.Language,
+		URL:      assetURL,
+		MimeType: "video/mp4",
+		FileSize: int64(len(data)),
+	}
+	if err := uc.saveAsset(ctx, asset); err != nil {
+		return nil, err
+	}
+
+	return asset, nil
+}
+
+// createAudioAssets extracts an audio stream as MP3 and M4A files and computes its
+// waveform, storing each under videos/<id>/audio/ as an asset
+func (uc *TranscodeUseCase) createAudioAssets(
+	ctx context.Context,
+	videoID string,
+	inputPath string,
+	tempDir string,
+	streamIndex int,
+	loudness *entity.LoudnessNormalization,
+) error {
+	opts := entity.TranscodeOptions{Loudness: loudness}
+	for _, format := range []entity.AudioFormat{entity.AudioFormatMP3, entity.AudioFormatM4A} {
+		outputPath := filepath.Join(tempDir, "audio."+string(format))
+		if err := uc.transcodeRepo.ExtractAudio(ctx, inputPath, outputPath, streamIndex, format, opts); err != nil {
+			return fmt.Errorf("failed to extract %s audio: %w", format, err)
+		}
+
+		data, err := os.ReadFile(outputPath)
+		if err != nil {
+			return fmt.Errorf("failed to read %s audio: %w", format, err)
+		}
+
+		storagePath := fmt.Sprintf("videos/%s/audio/audio.%s", videoID, format)
+		audioURL, err := uc.storageRepo.UploadFile(ctx, storagePath, data, format.MimeType())
+		if err != nil {
+			return fmt.Errorf("failed to upload %s audio: %w", format, err)
+		}
+
+		asset := &entity.Asset{
+			VideoID:  videoID,
+			Type:     entity.AssetAudio,
+			Name:     string(format),
+			URL:      audioURL,
+			MimeType: format.MimeType(),
+			FileSize: int64(len(data)),
+		}
+		if err := uc.saveAsset(ctx, asset); err != nil {
+			return err
+		}
+	}
+
+	// Peaks come from the source so the waveform matches what was uploaded
+	intervalMs := uc.config.WaveformMs
+	if intervalMs <= 0 {
+		intervalMs = 20
+	}
+	waveform, err := uc.transcodeRepo.Waveform(ctx, inputPath, streamIndex, intervalMs)
+	if err != nil {
+		return fmt.Errorf("failed to compute waveform: %w", err)
+	}
+
+	data, err := json.Marshal(waveform)
+	if err != nil {
+		return fmt.Errorf("failed to encode waveform: %w", err)
+	}
+
+	waveformURL, err := uc.storageRepo.UploadFile(ctx, fmt.Sprintf("videos/%s/audio/waveform.json", videoID), data, "application/json")
+	if err != nil {
+		return fmt.Errorf("failed to upload waveform: %w", err)
+	}
+
+	return uc.saveAsset(ctx, &entity.Asset{
+		VideoID:  videoID,
+		Type:     entity.AssetWaveform,
+		Name:     fmt.Sprintf("%dms", intervalMs),
+		URL:      waveformURL,
+		MimeType: "application/json",
+		FileSize: int64(len(data)),
+	})
+}
+
+// runQC detects black frames, frozen frames and silence in a video and stores the
+// report, flagged if any of them exceeds its threshold
+func (uc *TranscodeUseCase) runQC(
+	ctx context.Context,
+	videoID string,
+	inputPath string,
+	duration float64,
+	audio bool,
+) error {
+	report, err := uc.transcodeRepo.DetectDefects(ctx, inputPath, duration, audio)
+	if err != nil {
+		return err
+	}
+
+	report.VideoID = videoID
+	report.Evaluate(duration, uc.config.QCThresholds)
+	report.CreatedAt = time.Now()
+	if report.Flagged {
+		fmt.Printf("Video %s flagged by QC: %v\n", videoID, report.Reasons)
+	}
+
+	if err := uc.qcRepo.Save(ctx, report); err != nil {
+		return fmt.Errorf("failed to save QC report: %w", err)
+	}
+
+	return nil
+}
+
+// createSceneChapters replaces the automatic chapters of a video with chapters
+// starting at scene changes at least MinChapter seconds apart. Videos with manual
+// chapters are left alone.
+func (uc *TranscodeUseCase) createSceneChapters(
+	ctx context.Context,
+	videoID string,
+	scenes []float64,
+	duration float64,
+) error {
+	chapters, err := uc.chapterRepo.GetByVideoID(ctx, videoID)
+	if err != nil {
+		return fmt.Errorf("failed to get chapters: %w", err)
+	}
+
+	manual := false
+	for _, chapter := range chapters {
+		if chapter.Source != entity.ChapterSourceScene {
+			manual = true
+			continue
+		}
+		if err := uc.chapterRepo.Delete(ctx, chapter.ID); err != nil {
+			return fmt.Errorf("failed to delete chapter: %w", err)
+		}
+	}
+	if manual {
+		return nil
+	}
+
+	// Keep the scene changes that leave every chapter long enough
+	starts := []float64{0}
+	for _, scene := range scenes {
+		if scene-starts[len(starts)-1] >= uc.config.MinChapter && duration-scene >= uc.config.MinChapter {
+			starts = append(starts, scene)
+		}
+	}
+	if len(starts) < 2 {
+		return nil // A single chapter adds nothing
+	}
+
+	for i, start := range starts {
+		end := duration
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+
+		chapter := &entity.Chapter{
+			ID:        uuid.New().String(),
+			VideoID:   videoID,
+			Title:     fmt.Sprintf("Chapter %d", i+1),
+			Start:     start,
+			End:       end,
+			Source:    entity.ChapterSourceScene,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if err := uc.chapterRepo.Create(ctx, chapter); err != nil {
+			return fmt.Errorf("failed to create chapter record: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// createMP4Asset uploads a rendition's MP4 and stores it as a download asset
+func (uc *TranscodeUseCase) createMP4Asset(
+	ctx context.Context,
+	videoID string,
+	name entity.Resolution,
+	path string,
+) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s MP4: %w", name, err)
+	}
+
+	storagePath := fmt.Sprintf("videos/%s/downloads/%s.mp4", videoID, name)
+	mp4URL, err := uc.storageRepo.UploadFile(ctx, storagePath, data, "video/mp4")
+	if err != nil {
+		return fmt.Errorf("failed to upload %s MP4: %w", name, err)
+	}
+
+	return uc.saveAsset(ctx, &entity.Asset{
+		VideoID:  videoID,
+		Type:     entity.AssetMP4,
+		Name:     string(name),
+		URL:      mp4URL,
+		MimeType: "video/mp4",
+		FileSize: int64(len(data)),
+	})
+}
+
+// saveAsset stores an asset, replacing an earlier one with the same type, name and
+// language
+func (uc *TranscodeUseCase) saveAsset(ctx context.Context, asset *entity.Asset) error {
+	assets, err := uc.assetRepo.GetByVideoID(ctx, asset.VideoID)
+	if err != nil {
+		return fmt.Errorf("failed to get assets: %w", err)
+	}
+
+	for _, existing := range assets {
+		if existing.Type == asset.Type && existing.Name == asset.Name && existing.Language == asset.Language {
+			existing.URL = asset.URL
+			existing.MimeType = asset.MimeType
+			existing.FileSize = asset.FileSize
+			if err := uc.assetRepo.Update(ctx, existing); err != nil {
+				return fmt.Errorf("failed to update asset record: %w", err)
+			}
+			*asset = *existing
+			return nil
+		}
+	}
+
+	asset.ID = uuid.New().String()
+	asset.CreatedAt = time.Now()
+	asset.UpdatedAt = time.Now()
+	if err := uc.assetRepo.Create(ctx, asset); err != nil {
+		return fmt.Errorf("failed to create asset record: %w", err)
+	}
+
+	return nil
+}
+
+// probeComplexity encodes short excerpts spread across the video at constant quality
+// and derives a bitrate factor from how many bits they needed
+func (uc *TranscodeUseCase) probeComplexity(
+	ctx context.Context,
+	inputPath string,
+	duration float64,
+	toneMap *entity.ColorInfo,
+) (*entity.ComplexityAnalysis, error) {
+	profile := entity.TranscodeProfile{
+		Name:       entity.Resolution720p,
+		Resolution: entity.Resolution720p,
+		Codec:      entity.CodecH264,
+		FPS:        24,
+		RateControl: entity.RateControl{
+			Mode:   entity.RateControlCRF,
+			CRF:    23,
+			Preset: "veryfast",
+		},
+	}
+	opts := entity.TranscodeOptions{ToneMap: toneMap}
+
+	// Short videos are probed as a whole
+	starts := []float64{0}
+	sampleDuration := duration
+	if duration > complexitySamples*complexitySampleDuration {
+		starts = nil
+		sampleDuration = complexitySampleDuration
+
+		// Centre each sample within an equal slice of the video
+		slice := duration / complexitySamples
+		for i := 0; i < complexitySamples; i++ {
+			starts = append(starts, float64(i)*slice+(slice-sampleDuration)/2)
+		}
+	}
+
+	analysis := &entity.ComplexityAnalysis{}
+	total := 0
+	for _, start := range starts {
+		bitrate, err := uc.transcodeRepo.EncodeSample(ctx, inputPath, start, sampleDuration, profile, opts)
+		if err != nil {
+			return nil, err
+		}
+		analysis.SampleBitrates = append(analysis.SampleBitrates, bitrate)
+		total += bitrate
+	}
+
+	analysis.Bitrate = total / len(starts)
+	analysis.Factor = math.Min(
+		maxComplexityFactor,
+		math.Max(minComplexityFactor, float64(analysis.Bitrate)/complexityReferenceBitrate),
+	)
+
+	return analysis, nil
+}
+
+// downloadWatermark saves a watermark image into tempDir and returns its path
+func (uc *TranscodeUseCase) downloadWatermark(
+	ctx context.Context,
+	watermark *entity.Watermark,
+	tempDir string,
+) (string, error) {
+	data, err := uc.storageRepo.GetFile(ctx, watermark.ImageURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to download watermark: %w", err)
+	}
+
+	path := filepath.Join(tempDir, "watermark"+filepath.Ext(watermark.ImageURL))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to save watermark to temp dir: %w", err)
+	}
+
+	return path, nil
+}
+
+// extractCaption extracts an embedded subtitle stream, uploads it as WebVTT and
+// stores it as a caption track
+func (uc *TranscodeUseCase) extractCaption(
+	ctx context.Context,
+	videoID string,
+	inputPath string,
+	tempDir string,
+	stream entity.SubtitleStream,
+) error {
+	outputPath := filepath.Join(tempDir, fmt.Sprintf("subtitle_%d.vtt", stream.Index))
+	if err := uc.transcodeRepo.ExtractSubtitle(ctx, inputPath, outputPath, stream.Index); err != nil {
+		return err
+	}
+
+	vttData, err := os.ReadFile(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read subtitle file: %w", err)
+	}
+
+	storagePath := fmt.Sprintf("videos/%s/captions/embedded_%d.vtt", videoID, stream.Index)
+	captionURL, err := uc.storageRepo.UploadFile(ctx, storagePath, vttData, "text/vtt")
+	if err != nil {
+		return fmt.Errorf("failed to upload caption: %w", err)
+	}
+
+	language := streamLanguage(stream.Language)
+	if language == "" {
+		language = "und"
+	}
+	label := stream.Title
+	if label == "" {
+		label = fmt.Sprintf("Subtitles %d", stream.Index+1)
+		if language != "und" {
+			label = language
+		}
+	}
+
+	caption := &entity.Caption{
+		ID:        uuid.New().String(),
+		VideoID:   videoID,
+		Language:  language,
+		Label:     label,
+		Source:    entity.CaptionSourceEmbedded,
+		URL:       captionURL,
+		IsDefault: stream.Default,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	if err := uc.captionRepo.Create(ctx, caption); err != nil {
+		return fmt.Errorf("failed to create caption record: %w", err)
+	}
+
+	return nil
+}
+
+// renditionBitrate holds the bitrates measured over the uploaded segments of a
+// rendition, in bits per second
+type renditionBitrate struct {
+	Peak    int
+	Average int
+}
+
+// segmentAndUpload segments a transcoded rendition into MPEG-TS, uploads the segments
+// and stores their metadata. It returns the bitrates measured across the segments.
+func (uc *TranscodeUseCase) segmentAndUpload(
+	ctx context.Context,
+	videoID string,
+	rendition entity.Resolution,
+	inputPath string,
+	tempDir string,
+	duration float64,
+	key *entity.EncryptionKey,
+) (renditionBitrate, error) {
+	// Segment the transcoded file
+	segmentsDirPath := filepath.Join(tempDir, string(rendition))
+	if err := os.MkdirAll(segmentsDirPath, 0755); err != nil {
+		return renditionBitrate{}, fmt.Errorf("failed to create segments directory: %w", err)
+	}
+
+	// Create 10-second segments
+	segmentPattern := filepath.Join(segmentsDirPath, "segment_%03d.ts")
+	segmentFiles, err := uc.transcodeRepo.Segment(ctx, inputPath, segmentDuration, segmentPattern)
+	if err != nil {
+		return renditionBitrate{}, fmt.Errorf("failed to segment %s: %w", rendition, err)
+	}
+
+	return uc.uploadSegments(ctx, videoID, rendition, segmentFiles, "video/mp2t", duration, key, false)
+}
+
+// segmentAndUploadFMP4 segments a transcoded rendition into fragmented MP4, uploads the
+// initialization and media segments and stores their metadata. It returns the bitrates
+// measured across the segments and the URL of the initialization segment.
+func (uc *TranscodeUseCase) segmentAndUploadFMP4(
+	ctx context.Context,
+	videoID string,
+	rendition entity.Resolution,
+	inputPath string,
+	tempDir string,
+	duration float64,
+	key *entity.EncryptionKey,
+) (renditionBitrate, string, error) {
+	segmentsDirPath := filepath.Join(tempDir, string(rendition))
+	initPath, segmentFiles, err := uc.transcodeRepo.SegmentFMP4(ctx, inputPath, segmentDuration, segmentsDirPath)
+	if err != nil {
+		return renditionBitrate{}, "", fmt.Errorf("failed to segment %s: %w", rendition, err)
+	}
+
+	// Upload the initialization segment
+	initData, err := os.ReadFile(initPath)
+	if err != nil {
+		return renditionBitrate{}, "", fmt.Errorf("failed to read init segment: %w", err)
+	}
+	storagePath := fmt.Sprintf("videos/%s/%s/%s", videoID, rendition, filepath.Base(initPath))
+	initURL, err := uc.storageRepo.UploadFile(ctx, storagePath, initData, "video/mp4")
+	if err != nil {
+		return renditionBitrate{}, "", fmt.Errorf("failed to upload init segment: %w", err)
+	}
+
+	bitrate, err := uc.uploadSegments(ctx, videoID, rendition, segmentFiles, "video/iso.segment", duration, key, true)
+	if err != nil {
+		return renditionBitrate{}, "", err
+	}
+
+	return bitrate, initURL, nil
+}
+
+// uploadSegments uploads segment files of a rendition, encrypted with key if set,
+// and stores their metadata. It returns the peak and average bitrates measured
+// across the segments.
+func (uc *TranscodeUseCase) uploadSegments(
+	ctx context.Context,
+	videoID string,
+	rendition entity.Resolution,
+	segmentFiles []string,
+	contentType string,
+	duration float64,
+	key *entity.EncryptionKey,
+	fmp4 bool,
+) (renditionBitrate, error) {
+	var bitrate renditionBitrate
+	var totalBytes int
+	var totalDuration float64
+
+	// Upload each segment and store metadata
+	for i, segmentPath := range segmentFiles {
+		segmentFileName := filepath.Base(segmentPath)
+
+		// Read segment file
+		segmentData, err := os.ReadFile(segmentPath)
+		if err != nil {
+			return renditionBitrate{}, fmt.Errorf("failed to read segment file: %w", err)
+		}
+
+		// Segments are numbered from media sequence 0
+		segmentData, err = encryptSegment(segmentData, key, fmp4, i)
+		if err != nil {
+			return renditionBitrate{}, fmt.Errorf("failed to encrypt segment: %w", err)
+		}
+
+		// Upload to storage
+		storagePath := fmt.Sprintf("videos/%s/%s/%s", videoID, rendition, segmentFileName)
+		segmentURL, err := uc.storageRepo.UploadFile(ctx, storagePath, segmentData, contentType)
+		if err != nil {
+			return renditionBitrate{}, fmt.Errorf("failed to upload segment: %w", err)
+		}
+
+		// Create segment record
+		segment := &entity.Segment{
+			ID:           uuid.New().String(),
+			VideoID:      videoID,
+			FileName:     segmentFileName,
+			URL:          segmentURL,
+			Resolution:   rendition,
+			StartTime:    float64(i) * segmentDuration,
+			Duration:     segmentDuration,
+			SegmentIndex: i,
+			CreatedAt:    time.Now(),
+		}
+
+		// For the last segment, adjust duration if needed
+		if i == len(segmentFiles)-1 && duration-segment.StartTime < segmentDuration {
+			segment.Duration = duration - segment.StartTime
+		}
+
+		// Track the peak bitrate for the playlist BANDWIDTH attribute
+		if segment.Duration > 0 {
+			if peak := int(float64(len(segmentData)*8) / segment.Duration); peak > bitrate.Peak {
+				bitrate.Peak = peak
+			}
+		}
+		totalBytes += len(segmentData)
+		totalDuration += segment.Duration
+
+		// Save segment metadata
+		if err := uc.segmentRepo.Create(ctx, segment); err != nil {
+			return renditionBitrate{}, fmt.Errorf("failed to create segment record: %w", err)
+		}
+	}
+
+	// Average over the whole rendition for AVERAGE-BANDWIDTH
+	if totalDuration > 0 {
+		bitrate.Average = int(float64(totalBytes*8) / totalDuration)
+	}
+
+	return bitrate, nil
+}
+
+// createKey generates and stores a new content key for a video
+func (uc *TranscodeUseCase) createKey(
+	ctx context.Context,
+	videoID string,
+	method entity.EncryptionMethod,
+) (*entity.EncryptionKey, error) {
+	key := &entity.EncryptionKey{
+		VideoID:   videoID,
+		Method:    method,
+		Key:       make([]byte, entity.EncryptionKeySize),
+		CreatedAt: time.Now(),
+	}
+	if _, err := rand.Read(key.Key); err != nil {
+		return nil, fmt.Errorf("failed to generate encryption key: %w", err)
+	}
+
+	if err := uc.keyRepo.Save(ctx, key); err != nil {
+		return nil, fmt.Errorf("failed to save encryption key: %w", err)
+	}
+
+	return key, nil
+}
+
+// encryptSegment encrypts a segment with the video's content key, if any, using
+// its media sequence number as the IV
+func encryptSegment(data []byte, key *entity.EncryptionKey, fmp4 bool, sequence int) ([]byte, error) {
+	if key == nil {
+		return data, nil
+	}
+
+	switch key.Method.ForSegments(fmp4) {
+	case entity.EncryptionAES128:
+		return hls.EncryptAES128(data, key.Key, sequence)
+	case entity.EncryptionSampleAES:
+		return hls.EncryptSampleAES(data, key.Key, sequence)
+	default:
+		return data, nil
+	}
+}
+
+// audioBitrate returns the AAC bitrate in bits per second for a channel count
+func audioBitrate(channels int) int {
+	if channels > 2 {
+		return 384000
+	}
+	return 128000
+}
+
+// defaultAudioStream returns the index of the audio stream flagged as default,
+// falling back to the first audio stream
+func defaultAudioStream(streams []entity.AudioStream) int {
+	for _, stream := range streams {
+		if stream.Default {
+			return stream.Index
+		}
+	}
+	return 0
+}
+
+// streamLanguage normalizes a stream language tag, dropping the "undetermined" code
+func streamLanguage(language string) string {
+	if language == "und" {
+		return ""
+	}
+	return language
+}
+
+// audioLabel returns a human readable name for an audio stream
+func audioLabel(stream entity.AudioStream) string {
+	switch {
+	case stream.Title != "":
+		return stream.Title
+	case streamLanguage(stream.Language) != "":
+		return stream.Language
+	default:
+		return fmt.Sprintf("Track %d", stream.Index+1)
+	}
+}
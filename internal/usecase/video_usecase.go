@@ -2,8 +2,13 @@ package usecase
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net/http"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,6 +21,9 @@ import (
 type VideoUseCase struct {
 	videoRepo        repository.VideoRepository
 	segmentRepo      repository.SegmentRepository
+	renditionRepo    repository.RenditionRepository
+	assetRepo        repository.AssetRepository
+	qcRepo           repository.QCRepository
 	storageRepo      repository.StorageRepository
 	transcodeUseCase *TranscodeUseCase
 }
@@ -24,12 +32,18 @@ type VideoUseCase struct {
 func NewVideoUseCase(
 	videoRepo repository.VideoRepository,
 	segmentRepo repository.SegmentRepository,
+	renditionRepo repository.RenditionRepository,
+	assetRepo repository.AssetRepository,
+	qcRepo repository.QCRepository,
 	storageRepo repository.StorageRepository,
 	transcodeUseCase *TranscodeUseCase,
 ) *VideoUseCase {
 	return &VideoUseCase{
 		videoRepo:        videoRepo,
 		segmentRepo:      segmentRepo,
+		renditionRepo:    renditionRepo,
+		assetRepo:        assetRepo,
+		qcRepo:           qcRepo,
 		storageRepo:      storageRepo,
 		transcodeUseCase: transcodeUseCase,
 	}
@@ -44,6 +58,25 @@ type VideoUploadInput struct {
 	FileSize    int64
 	MimeType    string
 	UserID      string
+	Watermark   *WatermarkInput         // Overrides the default watermark, if set
+	Encryption  entity.EncryptionMethod // Overrides the default segment encryption, if set
+}
+
+// VideoUpdateInput represents the editable details of a video. Nil fields are left unchanged.
+type VideoUpdateInput struct {
+	VideoID     string
+	UserID      string
+	Title       *string
+	Description *string
+}
+
+// WatermarkInput represents a watermark image and its overlay settings
+type WatermarkInput struct {
+	ImageData []byte
+	FileName  string
+	Position  entity.WatermarkPosition
+	Opacity   float64
+	Margin    int
 }
 
 // UploadVideo handles the video upload process
@@ -51,6 +84,21 @@ func (uc *VideoUseCase) UploadVideo(ctx context.Context, input VideoUploadInput)
 	// Generate a unique ID for the video
 	videoID := uuid.New().String()
 
+	// Content keys are sealed with KEY_ENCRYPTION_SECRET, so encryption needs it
+	if input.Encryption.Enabled() && !uc.transcodeUseCase.config.KeySealing {
+		return nil, fmt.Errorf("invalid encryption: %s is not available on this server", input.Encryption)
+	}
+
+	// Store the watermark image alongside the upload
+	var watermark *entity.Watermark
+	if input.Watermark != nil {
+		var err error
+		watermark, err = uc.uploadWatermark(ctx, videoID, input.Watermark)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Create storage path for the original video
 	originalVideoPath := fmt.Sprintf("uploads/%s/original/%s", videoID, filepath.Base(input.FileName))
 
@@ -60,6 +108,13 @@ func (uc *VideoUseCase) UploadVideo(ctx context.Context, input VideoUploadInput)
 		return nil, fmt.Errorf("failed to upload video: %w", err)
 	}
 
+	// Look for an earlier upload of the same file by this user
+	checksum := sha256.Sum256(input.FileData)
+	duplicates, err := uc.videoRepo.GetByChecksum(ctx, input.UserID, hex.EncodeToString(checksum[:]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for duplicates: %w", err)
+	}
+
 	// Create a new video entity
 	video := &entity.Video{
 		ID:          videoID,
@@ -70,45 +125,63 @@ func (uc *VideoUseCase) UploadVideo(ctx context.Context, input VideoUploadInput)
 		FileSize:    input.FileSize,
 		MimeType:    input.MimeType,
 		UserID:      input.UserID,
+		Checksum:    hex.EncodeToString(checksum[:]),
+		Metadata:    entity.VideoMetadata{Watermark: watermark, Encryption: input.Encryption},
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
 
+	// Offer the processed copy instead of transcoding the same file again
+	for _, duplicate := range duplicates {
+		if duplicate.Status == entity.StatusComplete {
+			video.Status = entity.StatusDuplicate
+			video.Metadata.DuplicateOf = duplicate.ID
+			break
+		}
+	}
+
 	// Persist the video information
 	if err := uc.videoRepo.Create(ctx, video); err != nil {
 		return nil, fmt.Errorf("failed to create video record: %w", err)
 	}
 
 	// Start the transcoding process asynchronously
-	go func() {
-		// Create a new context since the request context will be cancelled
-		bgCtx := context.Background()
-
-		// Update status to processing
-		video.Status = entity.StatusProcessing
-		if err := uc.videoRepo.Update(bgCtx, video); err != nil {
-			// Log error but continue
-			fmt.Printf("Failed to update video status: %v\n", err)
-		}
+	if video.Status != entity.StatusDuplicate {
+		uc.transcodeUseCase.ProcessAsync(video, nil)
+	}
 
-		// Process the video (transcode and segment)
-		err := uc.transcodeUseCase.ProcessVideo(bgCtx, videoID, uploadURL)
+	return video, nil
+}
 
-		// Update status based on the result
-		if err != nil {
-			video.Status = entity.StatusFailed
-			fmt.Printf("Video processing failed: %v\n", err)
-		} else {
-			video.Status = entity.StatusComplete
-		}
+// uploadWatermark validates a watermark and uploads its image to storage
+func (uc *VideoUseCase) uploadWatermark(
+	ctx context.Context,
+	videoID string,
+	input *WatermarkInput,
+) (*entity.Watermark, error) {
+	watermark := &entity.Watermark{
+		Position: input.Position,
+		Opacity:  input.Opacity,
+		Margin:   input.Margin,
+	}
+	if err := watermark.Validate(); err != nil {
+		return nil, err
+	}
 
-		// Update the video status
-		if updateErr := uc.videoRepo.Update(bgCtx, video); updateErr != nil {
-			fmt.Printf("Failed to update video status: %v\n", updateErr)
-		}
-	}()
+	// Only still images with well supported decoders are accepted
+	contentType := http.DetectContentType(input.ImageData)
+	if contentType != "image/png" && contentType != "image/jpeg" {
+		return nil, errors.New("invalid watermark image: must be PNG or JPEG")
+	}
 
-	return video, nil
+	storagePath := fmt.Sprintf("uploads/%s/watermark/%s", videoID, filepath.Base(input.FileName))
+	imageURL, err := uc.storageRepo.UploadFile(ctx, storagePath, input.ImageData, contentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload watermark: %w", err)
+	}
+	watermark.ImageURL = imageURL
+
+	return watermark, nil
 }
 
 // GetVideoByID retrieves a video by its ID
@@ -130,7 +203,138 @@ func (uc *VideoUseCase) GetVideoSegmentsByResolution(
 	return uc.segmentRepo.GetByVideoIDAndResolution(ctx, videoID, resolution)
 }
 
+// GetVideoRenditions retrieves the renditions available for a video
+func (uc *VideoUseCase) GetVideoRenditions(ctx context.Context, videoID string) ([]*entity.Rendition, error) {
+	return uc.renditionRepo.GetByVideoID(ctx, videoID)
+}
+
+// GetVideoAssets retrieves the downloadable assets of a video
+func (uc *VideoUseCase) GetVideoAssets(ctx context.Context, videoID string) ([]*entity.Asset, error) {
+	return uc.assetRepo.GetByVideoID(ctx, videoID)
+}
+
+// GetQCReport retrieves the black frame, frozen frame and silence report of a video
+func (uc *VideoUseCase) GetQCReport(ctx context.Context, videoID string) (*entity.QCReport, error) {
+	if _, err := uc.videoRepo.GetByID(ctx, videoID); err != nil {
+		return nil, err
+	}
+	return uc.qcRepo.GetByVideoID(ctx, videoID)
+}
+
+// GetQualityReport retrieves the video renditions of a video with their encoder
+// settings, bitrates and quality scores
+func (uc *VideoUseCase) GetQualityReport(ctx context.Context, videoID string) ([]*entity.Rendition, error) {
+	if _, err := uc.videoRepo.GetByID(ctx, videoID); err != nil {
+		return nil, err
+	}
+
+	renditions, err := uc.renditionRepo.GetByVideoID(ctx, videoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get renditions: %w", err)
+	}
+
+	var report []*entity.Rendition
+	for _, rendition := range renditions {
+		if rendition.Type == entity.RenditionVideo {
+			report = append(report, rendition)
+		}
+	}
+
+	return report, nil
+}
+
 // ListVideos retrieves a paginated list of videos
 func (uc *VideoUseCase) ListVideos(ctx context.Context, userID string, limit, offset int) ([]*entity.Video, error) {
 	return uc.videoRepo.List(ctx, userID, limit, offset)
 }
+
+// UpdateVideo changes the title or description of a user's video
+func (uc *VideoUseCase) UpdateVideo(ctx context.Context, input VideoUpdateInput) (*entity.Video, error) {
+	video, err := uc.editableVideo(ctx, input.VideoID, input.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Title != nil {
+		title := strings.TrimSpace(*input.Title)
+		if title == "" {
+			return nil, errors.New("invalid video: title must not be empty")
+		}
+		video.Title = title
+	}
+	if input.Description != nil {
+		video.Description = *input.Description
+	}
+
+	if err := uc.videoRepo.Update(ctx, video); err != nil {
+		return nil, fmt.Errorf("failed to update video: %w", err)
+	}
+
+	return video, nil
+}
+
+// DeleteVideo deletes a user's video with its records and stored files. Files
+// played by linked duplicates are kept until the last of them is deleted.
+func (uc *VideoUseCase) DeleteVideo(ctx context.Context, videoID, userID string) error {
+	video, err := uc.editableVideo(ctx, videoID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.videoRepo.Delete(ctx, video.ID); err != nil {
+		return fmt.Errorf("failed to delete video: %w", err)
+	}
+
+	// The records are gone, so files left behind are only logged
+	if err := uc.storageRepo.DeletePrefix(ctx, fmt.Sprintf("uploads/%s/", video.ID)); err != nil {
+		fmt.Printf("Failed to delete uploads of video %s: %v\n", video.ID, err)
+	}
+	uc.deleteProcessedFiles(ctx, video.ID)
+
+	// A linked duplicate may have been the last to play the files of a deleted source
+	if source := video.Metadata.DuplicateOf; source != "" && video.Status != entity.StatusDuplicate {
+		if _, err := uc.videoRepo.GetByID(ctx, source); err != nil && strings.Contains(err.Error(), "not found") {
+			uc.deleteProcessedFiles(ctx, source)
+		}
+	}
+
+	return nil
+}
+
+// deleteProcessedFiles deletes the stored files under videos/<id>/ unless
+// linked duplicates still play them
+func (uc *VideoUseCase) deleteProcessedFiles(ctx context.Context, videoID string) {
+	duplicates, err := uc.videoRepo.ListByDuplicateOf(ctx, videoID)
+	if err != nil {
+		fmt.Printf("Failed to list duplicates of video %s: %v\n", videoID, err)
+		return
+	}
+	for _, duplicate := range duplicates {
+		if duplicate.Status != entity.StatusDuplicate {
+			return
+		}
+	}
+
+	if err := uc.storageRepo.DeletePrefix(ctx, fmt.Sprintf("videos/%s/", videoID)); err != nil {
+		fmt.Printf("Failed to delete files of video %s: %v\n", videoID, err)
+	}
+}
+
+// editableVideo retrieves a user's video that is not being processed. Processing
+// writes the whole video when it finishes, which would undo edits made meanwhile.
+func (uc *VideoUseCase) editableVideo(ctx context.Context, videoID, userID string) (*entity.Video, error) {
+	video, err := uc.videoRepo.GetByID(ctx, videoID)
+	if err != nil {
+		return nil, err
+	}
+	if video.UserID != userID {
+		return nil, fmt.Errorf("forbidden: video %s belongs to another user", video.ID)
+	}
+
+	switch video.Status {
+	case entity.StatusComplete, entity.StatusFailed, entity.StatusDuplicate:
+		return video, nil
+	default:
+		return nil, fmt.Errorf("invalid video: %s is still being processed", video.ID)
+	}
+}
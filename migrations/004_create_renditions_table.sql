@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS renditions (
+    id VARCHAR(36) PRIMARY KEY,
+    video_id VARCHAR(36) NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+    name VARCHAR(32) NOT NULL,
+    type VARCHAR(10) NOT NULL,
+    width INT NOT NULL DEFAULT 0,
+    height INT NOT NULL DEFAULT 0,
+    bandwidth INT NOT NULL DEFAULT 0,
+    codecs VARCHAR(100) NOT NULL DEFAULT '',
+    language VARCHAR(16) NOT NULL DEFAULT '',
+    label VARCHAR(100) NOT NULL DEFAULT '',
+    channels INT NOT NULL DEFAULT 0,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (video_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_renditions_video_id ON renditions(video_id);
+
+-- Audio track names such as audio_0 are stored alongside video resolutions
+ALTER TABLE segments ALTER COLUMN resolution TYPE VARCHAR(32);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS captions (
+    id VARCHAR(36) PRIMARY KEY,
+    video_id VARCHAR(36) NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+    language VARCHAR(16) NOT NULL,
+    label VARCHAR(100) NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    url TEXT NOT NULL,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_captions_video_id ON captions(video_id);
@@ -0,0 +1 @@
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';
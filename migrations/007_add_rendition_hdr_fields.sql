@@ -0,0 +1,2 @@
+ALTER TABLE renditions ADD COLUMN IF NOT EXISTS video_range VARCHAR(8) NOT NULL DEFAULT '';
+ALTER TABLE renditions ADD COLUMN IF NOT EXISTS init_url TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+ALTER TABLE renditions ADD COLUMN IF NOT EXISTS codec VARCHAR(10) NOT NULL DEFAULT '';
+
+-- Backfill video renditions created before codecs were configurable
+UPDATE renditions SET codec = 'h264' WHERE type = 'video' AND codec = '' AND codecs LIKE 'avc1%';
+UPDATE renditions SET codec = 'hevc' WHERE type = 'video' AND codec = '' AND codecs LIKE 'hvc1%';
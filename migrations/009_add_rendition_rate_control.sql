@@ -0,0 +1,4 @@
+ALTER TABLE renditions ADD COLUMN IF NOT EXISTS average_bandwidth INTEGER NOT NULL DEFAULT 0;
+
+-- Encoder rate control settings of video renditions as JSON
+ALTER TABLE renditions ADD COLUMN IF NOT EXISTS rate_control JSONB;
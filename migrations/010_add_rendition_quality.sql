@@ -0,0 +1,2 @@
+-- Objective quality scores of video renditions against their source as JSON
+ALTER TABLE renditions ADD COLUMN IF NOT EXISTS quality JSONB;
@@ -0,0 +1,15 @@
+-- Downloadable files derived from a video such as burned-in subtitle MP4s
+CREATE TABLE IF NOT EXISTS assets (
+    id VARCHAR(36) PRIMARY KEY,
+    video_id VARCHAR(36) NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+    type VARCHAR(32) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    language VARCHAR(16) NOT NULL DEFAULT '',
+    url TEXT NOT NULL,
+    mime_type VARCHAR(100) NOT NULL,
+    file_size BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_assets_video_id ON assets(video_id);
@@ -0,0 +1,13 @@
+-- Chapter markers, either detected from scene changes or added by the owner
+CREATE TABLE IF NOT EXISTS chapters (
+    id VARCHAR(36) PRIMARY KEY,
+    video_id VARCHAR(36) NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    start_time DOUBLE PRECISION NOT NULL,
+    end_time DOUBLE PRECISION NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_chapters_video_id ON chapters(video_id);
@@ -0,0 +1,9 @@
+-- Black frame, frozen frame and silence analysis with one report per video
+CREATE TABLE IF NOT EXISTS qc_reports (
+    video_id VARCHAR(36) PRIMARY KEY REFERENCES videos(id) ON DELETE CASCADE,
+    report JSONB NOT NULL,
+    flagged BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_qc_reports_flagged ON qc_reports(flagged);
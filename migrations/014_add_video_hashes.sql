@@ -0,0 +1,5 @@
+-- SHA-256 of the original file and perceptual hash of sampled frames for duplicate detection
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS checksum VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS perceptual_hash TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_videos_checksum ON videos(checksum);
@@ -0,0 +1,7 @@
+-- HLS content keys with one key per video, sealed with the server's key encryption secret
+CREATE TABLE IF NOT EXISTS encryption_keys (
+    video_id VARCHAR(36) PRIMARY KEY REFERENCES videos(id) ON DELETE CASCADE,
+    method VARCHAR(16) NOT NULL,
+    encrypted_key BYTEA NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
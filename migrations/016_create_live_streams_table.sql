@@ -0,0 +1,16 @@
+-- Live events published over RTMP, recorded as a video when they end
+CREATE TABLE IF NOT EXISTS live_streams (
+    id VARCHAR(36) PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    stream_key VARCHAR(64) NOT NULL UNIQUE,
+    status VARCHAR(20) NOT NULL DEFAULT 'idle',
+    user_id VARCHAR(36) NOT NULL,
+    video_id VARCHAR(36) NOT NULL DEFAULT '',
+    started_at TIMESTAMP,
+    ended_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_live_streams_user_id ON live_streams(user_id);
@@ -16,6 +16,7 @@ type Config struct {
 	Storage   StorageConfig
 	Transcode TranscodeConfig
 	Auth      AuthConfig
+	Live      LiveConfig
 }
 
 // ServerConfig holds server configuration
@@ -44,20 +45,67 @@ type StorageConfig struct {
 	BucketName string
 	Endpoint   string
 	UseSSL     bool
+	// PlaybackURLMode and PlaybackURLExpiry control the URIs written into playlists
+	PlaybackURLMode   string
+	PlaybackURLExpiry string
 }
 
 // TranscodeConfig holds FFmpeg configuration
 type TranscodeConfig struct {
-	FFmpegPath        string
-	FFprobePath       string
-	MaxConcurrentJobs int
-	SegmentDuration   int
+	FFmpegPath           string
+	FFprobePath          string
+	MaxConcurrentJobs    int
+	SegmentDuration      int
+	LoudnormEnabled      bool
+	LoudnormTargetI      float64
+	LoudnormTargetTP     float64
+	LoudnormTargetLRA    float64
+	HDRRendition         bool
+	Codecs               []string
+	AV1Encoder           string
+	RateControl          string
+	EncoderPreset        string
+	EncoderTune          string
+	QualityMetrics       bool
+	PerTitle             bool
+	AudioAssets          bool
+	WaveformMs           int
+	MP4Downloads         bool
+	SceneDetection       bool
+	SceneThreshold       float64
+	MinChapter           float64
+	QC                   bool
+	QCMaxBlackPercent    float64
+	QCMaxFreezePercent   float64
+	QCMaxSilencePercent  float64
+	PerceptualHash       bool
+	DuplicateMaxDistance float64
+	Encryption           string
+	WatermarkImage       string
+	WatermarkPosition    string
+	WatermarkOpacity     float64
+	WatermarkMargin      int
 }
 
 // AuthConfig holds authentication configuration
 type AuthConfig struct {
-	JWTSecret string
-	JWTExpiry string
+	JWTSecret           string
+	JWTExpiry           string
+	KeyEncryptionSecret string
+	URLSigningSecret    string
+	PlaybackTokenExpiry string
+	// PlaybackTokenMaxExpiry caps the lifetime clients may request for playback tokens
+	PlaybackTokenMaxExpiry string
+}
+
+// LiveConfig holds live streaming configuration
+type LiveConfig struct {
+	RTMPAddr         string
+	SegmentDuration  float64
+	PartDuration     float64
+	PlaylistSegments int
+	DVRWindow        string
+	EncoderPreset    string
 }
 
 // Load loads configuration from environment variables
@@ -82,22 +130,65 @@ func Load() (*Config, error) {
 			SSLMode:  getEnvOrDefault("DB_SSL_MODE", "disable"),
 		},
 		Storage: StorageConfig{
-			AccessKey:  getEnvOrDefault("STORAGE_ACCESS_KEY", "minioadmin"),
-			SecretKey:  getEnvOrDefault("STORAGE_SECRET_KEY", "minioadmin"),
-			Region:     getEnvOrDefault("STORAGE_REGION", "us-east-1"),
-			BucketName: getEnvOrDefault("STORAGE_BUCKET_NAME", "videos"),
-			Endpoint:   getEnvOrDefault("STORAGE_ENDPOINT", "http://localhost:9000"),
-			UseSSL:     getEnvBoolOrDefault("STORAGE_USE_SSL", false),
+			AccessKey:         getEnvOrDefault("STORAGE_ACCESS_KEY", "minioadmin"),
+			SecretKey:         getEnvOrDefault("STORAGE_SECRET_KEY", "minioadmin"),
+			Region:            getEnvOrDefault("STORAGE_REGION", "us-east-1"),
+			BucketName:        getEnvOrDefault("STORAGE_BUCKET_NAME", "videos"),
+			Endpoint:          getEnvOrDefault("STORAGE_ENDPOINT", "http://localhost:9000"),
+			UseSSL:            getEnvBoolOrDefault("STORAGE_USE_SSL", false),
+			PlaybackURLMode:   getEnvOrDefault("PLAYBACK_URL_MODE", "public"),
+			PlaybackURLExpiry: getEnvOrDefault("PLAYBACK_URL_EXPIRY", "1h"),
 		},
 		Transcode: TranscodeConfig{
-			FFmpegPath:        getEnvOrDefault("FFMPEG_PATH", "ffmpeg"),
-			FFprobePath:       getEnvOrDefault("FFPROBE_PATH", "ffprobe"),
-			MaxConcurrentJobs: getEnvIntOrDefault("MAX_CONCURRENT_TRANSCODES", 2),
-			SegmentDuration:   getEnvIntOrDefault("SEGMENT_DURATION", 10),
+			FFmpegPath:           getEnvOrDefault("FFMPEG_PATH", "ffmpeg"),
+			FFprobePath:          getEnvOrDefault("FFPROBE_PATH", "ffprobe"),
+			MaxConcurrentJobs:    getEnvIntOrDefault("MAX_CONCURRENT_TRANSCODES", 2),
+			SegmentDuration:      getEnvIntOrDefault("SEGMENT_DURATION", 10),
+			LoudnormEnabled:      getEnvBoolOrDefault("LOUDNORM_ENABLED", false),
+			LoudnormTargetI:      getEnvFloatOrDefault("LOUDNORM_TARGET_I", -16),
+			LoudnormTargetTP:     getEnvFloatOrDefault("LOUDNORM_TARGET_TP", -1.5),
+			LoudnormTargetLRA:    getEnvFloatOrDefault("LOUDNORM_TARGET_LRA", 11),
+			HDRRendition:         getEnvBoolOrDefault("HDR_HEVC_RENDITION", false),
+			Codecs:               getEnvListOrDefault("TRANSCODE_CODECS", []string{"h264"}),
+			AV1Encoder:           getEnvOrDefault("AV1_ENCODER", "libsvtav1"),
+			RateControl:          getEnvOrDefault("RATE_CONTROL", "crf"),
+			EncoderPreset:        getEnvOrDefault("ENCODER_PRESET", ""),
+			EncoderTune:          getEnvOrDefault("ENCODER_TUNE", ""),
+			QualityMetrics:       getEnvBoolOrDefault("QUALITY_METRICS_ENABLED", false),
+			PerTitle:             getEnvBoolOrDefault("PER_TITLE_ENCODING", false),
+			AudioAssets:          getEnvBoolOrDefault("AUDIO_ASSETS_ENABLED", false),
+			WaveformMs:           getEnvIntOrDefault("WAVEFORM_INTERVAL_MS", 20),
+			MP4Downloads:         getEnvBoolOrDefault("MP4_DOWNLOADS_ENABLED", false),
+			SceneDetection:       getEnvBoolOrDefault("SCENE_DETECTION_ENABLED", false),
+			SceneThreshold:       getEnvFloatOrDefault("SCENE_THRESHOLD", 0.4),
+			MinChapter:           getEnvFloatOrDefault("CHAPTER_MIN_DURATION", 60),
+			QC:                   getEnvBoolOrDefault("QC_ENABLED", false),
+			QCMaxBlackPercent:    getEnvFloatOrDefault("QC_MAX_BLACK_PERCENT", 5),
+			QCMaxFreezePercent:   getEnvFloatOrDefault("QC_MAX_FREEZE_PERCENT", 5),
+			QCMaxSilencePercent:  getEnvFloatOrDefault("QC_MAX_SILENCE_PERCENT", 20),
+			PerceptualHash:       getEnvBoolOrDefault("PERCEPTUAL_HASH_ENABLED", false),
+			DuplicateMaxDistance: getEnvFloatOrDefault("DUPLICATE_MAX_DISTANCE", 8),
+			Encryption:           getEnvOrDefault("HLS_ENCRYPTION", "none"),
+			WatermarkImage:       getEnvOrDefault("WATERMARK_IMAGE", ""),
+			WatermarkPosition:    getEnvOrDefault("WATERMARK_POSITION", "bottom-right"),
+			WatermarkOpacity:     getEnvFloatOrDefault("WATERMARK_OPACITY", 0.8),
+			WatermarkMargin:      getEnvIntOrDefault("WATERMARK_MARGIN", 24),
 		},
 		Auth: AuthConfig{
-			JWTSecret: getEnvOrDefault("JWT_SECRET", "your-secret-key-change-this-in-production"),
-			JWTExpiry: getEnvOrDefault("JWT_EXPIRY", "24h"),
+			JWTSecret:              getEnvOrDefault("JWT_SECRET", "your-secret-key-change-this-in-production"),
+			JWTExpiry:              getEnvOrDefault("JWT_EXPIRY", "24h"),
+			KeyEncryptionSecret:    os.Getenv("KEY_ENCRYPTION_SECRET"),
+			URLSigningSecret:       os.Getenv("URL_SIGNING_SECRET"),
+			PlaybackTokenExpiry:    getEnvOrDefault("PLAYBACK_TOKEN_EXPIRY", "15m"),
+			PlaybackTokenMaxExpiry: getEnvOrDefault("PLAYBACK_TOKEN_MAX_EXPIRY", "24h"),
+		},
+		Live: LiveConfig{
+			RTMPAddr:         getEnvOrDefault("RTMP_ADDR", ":1935"),
+			SegmentDuration:  getEnvFloatOrDefault("LIVE_SEGMENT_DURATION", 2),
+			PartDuration:     getEnvFloatOrDefault("LIVE_PART_DURATION", 0),
+			PlaylistSegments: getEnvIntOrDefault("LIVE_PLAYLIST_SEGMENTS", 6),
+			DVRWindow:        getEnvOrDefault("LIVE_DVR_WINDOW", "0s"),
+			EncoderPreset:    getEnvOrDefault("LIVE_ENCODER_PRESET", "veryfast"),
 		},
 	}
 
@@ -105,6 +196,12 @@ func Load() (*Config, error) {
 	if config.Storage.AccessKey == "" || config.Storage.SecretKey == "" {
 		return nil, fmt.Errorf("storage access key and secret key are required")
 	}
+	if encryption := strings.ToLower(config.Transcode.Encryption); encryption != "" && encryption != "none" && config.Auth.KeyEncryptionSecret == "" {
+		return nil, fmt.Errorf("KEY_ENCRYPTION_SECRET is required when HLS_ENCRYPTION is enabled")
+	}
+	if strings.EqualFold(strings.TrimSpace(config.Storage.PlaybackURLMode), "signed") && config.Auth.URLSigningSecret == "" {
+		return nil, fmt.Errorf("URL_SIGNING_SECRET is required when PLAYBACK_URL_MODE is signed")
+	}
 
 	return config, nil
 }
@@ -133,6 +230,42 @@ func getEnvIntOrDefault(key string, defaultValue int) int {
 	return value
 }
 
+// getEnvFloatOrDefault gets a float environment variable or returns a default value
+func getEnvFloatOrDefault(key string, defaultValue float64) float64 {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return defaultValue
+	}
+
+	return value
+}
+
+// getEnvListOrDefault gets a comma-separated environment variable or returns a default value
+func getEnvListOrDefault(key string, defaultValue []string) []string {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	var values []string
+	for _, value := range strings.Split(valueStr, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	if len(values) == 0 {
+		return defaultValue
+	}
+
+	return values
+}
+
 // getEnvBoolOrDefault gets a boolean environment variable or returns a default value
 func getEnvBoolOrDefault(key string, defaultValue bool) bool {
 	valueStr := os.Getenv(key)
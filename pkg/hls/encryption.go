@@ -0,0 +1,47 @@
+package hls
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+)
+
+// Values of the EXT-X-KEY METHOD attribute
+const (
+	MethodAES128    = "AES-128"
+	MethodSampleAES = "SAMPLE-AES"
+)
+
+// Key represents an EXT-X-KEY tag. Without an IV, clients use the media
+// sequence number of each segment.
+type Key struct {
+	Method string
+	URI    string
+}
+
+// SequenceIV returns the IV clients derive from a media sequence number when
+// EXT-X-KEY has no IV attribute
+func SequenceIV(sequence int) []byte {
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[8:], uint64(sequence))
+	return iv
+}
+
+// EncryptAES128 encrypts a whole segment with AES-128-CBC and PKCS7 padding,
+// using the segment's media sequence number as the IV
+func EncryptAES128(data, key []byte, sequence int) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AES-128 key: %w", err)
+	}
+
+	padding := aes.BlockSize - len(data)%aes.BlockSize
+	out := make([]byte, len(data), len(data)+padding)
+	copy(out, data)
+	out = append(out, bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	cipher.NewCBCEncrypter(block, SequenceIV(sequence)).CryptBlocks(out, out)
+	return out, nil
+}
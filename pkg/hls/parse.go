@@ -0,0 +1,63 @@
+package hls
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseMediaPlaylist reads the segments of a media playlist, such as one being
+// written by FFmpeg's HLS muxer. Tags other than the media sequence, playlist
+// type and end list are ignored.
+func ParseMediaPlaylist(data string) (*MediaPlaylist, error) {
+	lines := strings.Split(data, "\n")
+	if strings.TrimSpace(lines[0]) != "#EXTM3U" {
+		return nil, errors.New("invalid playlist: missing #EXTM3U")
+	}
+
+	playlist := &MediaPlaylist{}
+	var duration float64
+	pending := false
+
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			sequence, err := strconv.Atoi(strings.TrimPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid media sequence: %w", err)
+			}
+			playlist.MediaSequence = sequence
+		case line == "#EXT-X-PLAYLIST-TYPE:VOD":
+			playlist.VOD = true
+		case line == "#EXT-X-ENDLIST":
+			playlist.Ended = true
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value := strings.TrimPrefix(line, "#EXTINF:")
+			if i := strings.IndexByte(value, ','); i >= 0 {
+				value = value[:i]
+			}
+
+			var err error
+			duration, err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid segment duration: %w", err)
+			}
+			pending = true
+		case strings.HasPrefix(line, "#"):
+			continue
+		default:
+			if !pending {
+				return nil, fmt.Errorf("invalid playlist: segment %s has no #EXTINF", line)
+			}
+			playlist.Segments = append(playlist.Segments, Segment{Duration: duration, URI: line})
+			pending = false
+		}
+	}
+
+	return playlist, nil
+}
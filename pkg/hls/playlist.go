@@ -0,0 +1,282 @@
+package hls
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MediaType defines the type of an alternative rendition
+type MediaType string
+
+const (
+	MediaTypeAudio     MediaType = "AUDIO"
+	MediaTypeSubtitles MediaType = "SUBTITLES"
+)
+
+// Media represents an EXT-X-MEDIA alternative rendition
+type Media struct {
+	Type       MediaType
+	GroupID    string
+	Name       string
+	Language   string
+	Channels   string
+	URI        string
+	Default    bool
+	Autoselect bool
+}
+
+// Variant represents an EXT-X-STREAM-INF variant stream
+type Variant struct {
+	Bandwidth        int
+	AverageBandwidth int
+	Codecs           string
+	Width            int
+	Height           int
+	VideoRange       string
+	Audio            string
+	Subtitles        string
+	URI              string
+}
+
+// MasterPlaylist represents an HLS master (multivariant) playlist
+type MasterPlaylist struct {
+	Media    []Media
+	Variants []Variant
+}
+
+// String renders the master playlist in m3u8 format
+func (p *MasterPlaylist) String() string {
+	var b strings.Builder
+
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:4\n")
+	b.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+
+	for _, m := range p.Media {
+		attrs := []string{
+			"TYPE=" + string(m.Type),
+			fmt.Sprintf("GROUP-ID=%q", m.GroupID),
+			fmt.Sprintf("NAME=%q", m.Name),
+		}
+		if m.Language != "" {
+			attrs = append(attrs, fmt.Sprintf("LANGUAGE=%q", m.Language))
+		}
+		attrs = append(attrs, "DEFAULT="+yesNo(m.Default), "AUTOSELECT="+yesNo(m.Autoselect))
+		if m.Channels != "" {
+			attrs = append(attrs, fmt.Sprintf("CHANNELS=%q", m.Channels))
+		}
+		if m.URI != "" {
+			attrs = append(attrs, fmt.Sprintf("URI=%q", m.URI))
+		}
+		b.WriteString("#EXT-X-MEDIA:" + strings.Join(attrs, ",") + "\n")
+	}
+
+	for _, v := range p.Variants {
+		attrs := []string{fmt.Sprintf("BANDWIDTH=%d", v.Bandwidth)}
+		if v.AverageBandwidth > 0 {
+			attrs = append(attrs, fmt.Sprintf("AVERAGE-BANDWIDTH=%d", v.AverageBandwidth))
+		}
+		if v.Codecs != "" {
+			attrs = append(attrs, fmt.Sprintf("CODECS=%q", v.Codecs))
+		}
+		if v.Width > 0 && v.Height > 0 {
+			attrs = append(attrs, fmt.Sprintf("RESOLUTION=%dx%d", v.Width, v.Height))
+		}
+		if v.VideoRange != "" {
+			attrs = append(attrs, "VIDEO-RANGE="+v.VideoRange)
+		}
+		if v.Audio != "" {
+			attrs = append(attrs, fmt.Sprintf("AUDIO=%q", v.Audio))
+		}
+		if v.Subtitles != "" {
+			attrs = append(attrs, fmt.Sprintf("SUBTITLES=%q", v.Subtitles))
+		}
+		b.WriteString("#EXT-X-STREAM-INF:" + strings.Join(attrs, ",") + "\n")
+		b.WriteString(v.URI + "\n")
+	}
+
+	return b.String()
+}
+
+// Segment represents a single media segment entry
+type Segment struct {
+	Duration float64
+	URI      string
+	Parts    []Part // Partial segments, listed for recent segments of low-latency playlists
+}
+
+// Part represents an EXT-X-PART partial segment of a low-latency playlist
+type Part struct {
+	Duration    float64
+	URI         string
+	Independent bool // Starts with a keyframe
+}
+
+// ServerControl represents the EXT-X-SERVER-CONTROL delivery directives of a live playlist
+type ServerControl struct {
+	CanBlockReload bool
+	PartHoldBack   float64 // Seconds from the end where low-latency playback starts, zero to omit
+}
+
+// DateRange represents an EXT-X-DATERANGE tag such as a chapter marker
+type DateRange struct {
+	ID         string
+	Class      string
+	StartDate  time.Time
+	Duration   float64           // Seconds, zero if unknown
+	Attributes map[string]string // Client attributes, keys start with "X-"
+}
+
+// MediaPlaylist represents an HLS media playlist for a single rendition
+type MediaPlaylist struct {
+	MediaSequence   int
+	MapURI          string    // fMP4 initialization segment, empty for MPEG-TS
+	Key             *Key      // Segment encryption, nil for clear segments
+	ProgramDateTime time.Time // Wall-clock time of the first segment, required by DateRanges
+	DateRanges      []DateRange
+	Segments        []Segment
+	VOD             bool
+	Ended           bool           // A live playlist that will get no more segments
+	ServerControl   *ServerControl // Delivery directives of live playlists, nil for none
+	PartTarget      float64        // Longest part duration of low-latency playlists, zero for none
+	Parts           []Part         // Parts of the segment being written, after the last segment
+	PreloadHint     string         // URI of the next part, empty for none
+}
+
+// TargetDuration returns the EXT-X-TARGETDURATION value for the playlist
+func (p *MediaPlaylist) TargetDuration() int {
+	target := 0
+	for _, s := range p.Segments {
+		if d := int(math.Ceil(s.Duration)); d > target {
+			target = d
+		}
+	}
+	return target
+}
+
+// String renders the media playlist in m3u8 format
+func (p *MediaPlaylist) String() string {
+	var b strings.Builder
+
+	version := 3
+	if p.Key != nil && p.Key.Method == MethodSampleAES {
+		version = 5
+	}
+	if p.PartTarget > 0 {
+		version = 6
+	}
+	if p.MapURI != "" {
+		version = 7 // EXT-X-MAP outside of I-frame playlists
+	}
+
+	b.WriteString("#EXTM3U\n")
+	b.WriteString(fmt.Sprintf("#EXT-X-VERSION:%d\n", version))
+	b.WriteString(fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", p.TargetDuration()))
+	b.WriteString(fmt.Sprintf("#EXT-X-MEDIA-SEQUENCE:%d\n", p.MediaSequence))
+	if p.VOD {
+		b.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	}
+	if p.ServerControl != nil {
+		attrs := []string{}
+		if p.ServerControl.CanBlockReload {
+			attrs = append(attrs, "CAN-BLOCK-RELOAD=YES")
+		}
+		if p.ServerControl.PartHoldBack > 0 {
+			attrs = append(attrs, fmt.Sprintf("PART-HOLD-BACK=%.3f", p.ServerControl.PartHoldBack))
+		}
+		if len(attrs) > 0 {
+			b.WriteString("#EXT-X-SERVER-CONTROL:" + strings.Join(attrs, ",") + "\n")
+		}
+	}
+	if p.PartTarget > 0 {
+		b.WriteString(fmt.Sprintf("#EXT-X-PART-INF:PART-TARGET=%.3f\n", p.PartTarget))
+	}
+	if p.MapURI != "" {
+		b.WriteString(fmt.Sprintf("#EXT-X-MAP:URI=%q\n", p.MapURI))
+	}
+	// The key follows EXT-X-MAP so the initialization segment stays in the clear
+	if p.Key != nil {
+		b.WriteString(fmt.Sprintf("#EXT-X-KEY:METHOD=%s,URI=%q\n", p.Key.Method, p.Key.URI))
+	}
+	if !p.ProgramDateTime.IsZero() {
+		b.WriteString("#EXT-X-PROGRAM-DATE-TIME:" + formatDate(p.ProgramDateTime) + "\n")
+	}
+
+	for _, d := range p.DateRanges {
+		attrs := []string{
+			fmt.Sprintf("ID=%q", d.ID),
+		}
+		if d.Class != "" {
+			attrs = append(attrs, fmt.Sprintf("CLASS=%q", d.Class))
+		}
+		attrs = append(attrs, fmt.Sprintf("START-DATE=%q", formatDate(d.StartDate)))
+		if d.Duration > 0 {
+			attrs = append(attrs, fmt.Sprintf("DURATION=%.3f", d.Duration))
+		}
+
+		// Sort client attributes so the playlist is stable
+		keys := make([]string, 0, len(d.Attributes))
+		for key := range d.Attributes {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			attrs = append(attrs, fmt.Sprintf("%s=%s", key, quotedString(d.Attributes[key])))
+		}
+
+		b.WriteString("#EXT-X-DATERANGE:" + strings.Join(attrs, ",") + "\n")
+	}
+
+	// Parts come before the segment they make up
+	for _, s := range p.Segments {
+		for _, part := range s.Parts {
+			writePart(&b, part)
+		}
+		b.WriteString(fmt.Sprintf("#EXTINF:%.3f,\n", s.Duration))
+		b.WriteString(s.URI + "\n")
+	}
+	for _, part := range p.Parts {
+		writePart(&b, part)
+	}
+	if p.PreloadHint != "" {
+		b.WriteString(fmt.Sprintf("#EXT-X-PRELOAD-HINT:TYPE=PART,URI=%q\n", p.PreloadHint))
+	}
+
+	if p.VOD || p.Ended {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+
+	return b.String()
+}
+
+// writePart writes an EXT-X-PART tag
+func writePart(b *strings.Builder, part Part) {
+	b.WriteString(fmt.Sprintf("#EXT-X-PART:DURATION=%.3f,URI=%q", part.Duration, part.URI))
+	if part.Independent {
+		b.WriteString(",INDEPENDENT=YES")
+	}
+	b.WriteString("\n")
+}
+
+// formatDate formats a time as an ISO 8601 date with milliseconds
+func formatDate(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z07:00")
+}
+
+// quotedString formats a value as an HLS quoted-string, which cannot contain
+// double quotes or line breaks
+func quotedString(value string) string {
+	value = strings.NewReplacer(`"`, "'", "\n", " ", "\r", " ").Replace(value)
+	return `"` + value + `"`
+}
+
+// yesNo formats a boolean as an HLS enumerated string
+func yesNo(v bool) string {
+	if v {
+		return "YES"
+	}
+	return "NO"
+}
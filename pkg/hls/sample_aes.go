@@ -0,0 +1,649 @@
+package hls
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+)
+
+// MPEG-TS packet layout
+const (
+	tsPacketSize  = 188
+	tsHeaderSize  = 4
+	tsSyncByte    = 0x47
+	patPID        = 0x0000
+	pmtTableID    = 0x02
+	crcSize       = 4
+	pesHeaderSize = 9
+)
+
+// Elementary stream types in the clear and with SAMPLE-AES
+const (
+	streamTypeH264          = 0x1b
+	streamTypeAAC           = 0x0f
+	streamTypeH264SampleAES = 0xdb
+	streamTypeAACSampleAES  = 0xcf
+)
+
+// SAMPLE-AES leaves the start of each NAL unit and audio frame in the clear and,
+// for video, encrypts one block in every ten
+const (
+	sampleAESVideoLeader  = 32
+	sampleAESVideoMinimum = 48
+	sampleAESVideoSkip    = 144
+	sampleAESAudioLeader  = 16
+)
+
+// EncryptSampleAES encrypts the H.264 and AAC samples of an MPEG-TS segment as
+// described in Apple's MPEG-2 Stream Encryption Format for HTTP Live Streaming.
+// The PMT announces the encrypted streams and the segment's media sequence number
+// is the IV. Other streams are copied unchanged.
+func EncryptSampleAES(data, key []byte, sequence int) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SAMPLE-AES key: %w", err)
+	}
+	if len(data)%tsPacketSize != 0 {
+		return nil, fmt.Errorf("segment is not a whole number of MPEG-TS packets")
+	}
+
+	e := &sampleEncrypter{
+		block:   block,
+		iv:      SequenceIV(sequence),
+		pmtPID:  -1,
+		streams: make(map[int]byte),
+	}
+	if err := e.scan(data); err != nil {
+		return nil, err
+	}
+
+	return e.encrypt(data)
+}
+
+// sampleEncrypter rewrites one MPEG-TS segment with encrypted samples
+type sampleEncrypter struct {
+	block       cipher.Block
+	iv          []byte
+	pmtPID      int
+	pmt         []byte       // Rewritten PMT section
+	streams     map[int]byte // Clear stream type of each elementary PID
+	audioConfig []byte       // AudioSpecificConfig of the AAC stream
+}
+
+// tsPacket is a parsed MPEG-TS packet
+type tsPacket struct {
+	pid        int
+	start      bool   // payload_unit_start_indicator
+	cc         byte   // continuity_counter
+	adaptation []byte // Adaptation field with its length byte, nil if absent
+	payload    []byte
+}
+
+// pesUnit is a PES packet collected from the TS packets of an encrypted stream
+type pesUnit struct {
+	pid        int
+	slot       int      // Position of the rewritten packets in the output
+	adaptation []byte   // Adaptation field of the first packet, e.g. with the PCR
+	data       []byte   // PES header and payload
+	trailing   [][]byte // Adaptation fields of payload-less packets, e.g. PCR only
+}
+
+// scan finds the elementary streams and the audio configuration, then rewrites
+// the PMT they are announced in
+func (e *sampleEncrypter) scan(data []byte) error {
+	var pmt []byte
+
+	for offset := 0; offset < len(data); offset += tsPacketSize {
+		pkt, err := parseTSPacket(data[offset : offset+tsPacketSize])
+		if err != nil {
+			return err
+		}
+		if !pkt.start {
+			continue
+		}
+
+		switch {
+		case pkt.pid == patPID && e.pmtPID < 0:
+			section, err := psiSection(pkt.payload)
+			if err != nil {
+				return err
+			}
+			e.pmtPID, err = parsePAT(section)
+			if err != nil {
+				return err
+			}
+		case pkt.pid == e.pmtPID && pmt == nil:
+			pmt, err = psiSection(pkt.payload)
+			if err != nil {
+				return err
+			}
+			if err := e.parsePMT(pmt); err != nil {
+				return err
+			}
+		case e.streams[pkt.pid] == streamTypeAAC && e.audioConfig == nil:
+			es, err := pesPayload(pkt.payload)
+			if err != nil {
+				return err
+			}
+			if len(es) >= 7 && es[0] == 0xff && es[1]&0xf0 == 0xf0 {
+				e.audioConfig = audioSpecificConfig(es)
+			}
+		}
+	}
+
+	if pmt == nil {
+		return fmt.Errorf("segment has no PMT")
+	}
+
+	var err error
+	e.pmt, err = e.rewritePMT(pmt)
+	return err
+}
+
+// encrypt rewrites the segment. PES packets of encrypted streams may grow, so
+// they are packetized again at the position of their first packet.
+func (e *sampleEncrypter) encrypt(data []byte) ([]byte, error) {
+	var chunks [][]byte
+	pending := make(map[int]*pesUnit)
+	counters := make(map[int]byte)
+
+	flush := func(unit *pesUnit) error {
+		pes, err := e.encryptPES(unit.data, e.streams[unit.pid])
+		if err != nil {
+			return err
+		}
+		chunks[unit.slot] = packetizePES(unit, pes, counters)
+		return nil
+	}
+
+	for offset := 0; offset < len(data); offset += tsPacketSize {
+		raw := data[offset : offset+tsPacketSize]
+		pkt, err := parseTSPacket(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		streamType := e.streams[pkt.pid]
+		encrypted := streamType == streamTypeH264 || streamType == streamTypeAAC
+		unit := pending[pkt.pid]
+
+		switch {
+		case pkt.pid == e.pmtPID && pkt.start:
+			chunks = append(chunks, psiPacket(pkt.pid, pkt.cc, e.pmt))
+		case encrypted && pkt.start:
+			if unit != nil {
+				if err := flush(unit); err != nil {
+					return nil, err
+				}
+			}
+			if _, ok := counters[pkt.pid]; !ok {
+				counters[pkt.pid] = pkt.cc
+			}
+			pending[pkt.pid] = &pesUnit{
+				pid:        pkt.pid,
+				slot:       len(chunks),
+				adaptation: trimAdaptation(pkt.adaptation),
+				data:       append([]byte(nil), pkt.payload...),
+			}
+			chunks = append(chunks, nil)
+		case encrypted && unit != nil && len(pkt.payload) == 0:
+			unit.trailing = append(unit.trailing, pkt.adaptation)
+		case encrypted && unit != nil:
+			unit.data = append(unit.data, pkt.payload...)
+		case encrypted && len(pkt.payload) > 0:
+			// The end of a PES packet started in the previous segment can't be decrypted
+			continue
+		default:
+			chunks = append(chunks, raw)
+		}
+	}
+
+	for _, unit := range pending {
+		if err := flush(unit); err != nil {
+			return nil, err
+		}
+	}
+
+	return bytes.Join(chunks, nil), nil
+}
+
+// parsePMT records the stream type of every elementary PID
+func (e *sampleEncrypter) parsePMT(section []byte) error {
+	if len(section) < 12 || section[0] != pmtTableID {
+		return fmt.Errorf("invalid PMT")
+	}
+
+	end := len(section) - crcSize
+	programInfoLength := int(section[10]&0x0f)<<8 | int(section[11])
+	for i := 12 + programInfoLength; i+5 <= end; {
+		pid := int(section[i+1]&0x1f)<<8 | int(section[i+2])
+		infoLength := int(section[i+3]&0x0f)<<8 | int(section[i+4])
+		e.streams[pid] = section[i]
+		i += 5 + infoLength
+	}
+
+	return nil
+}
+
+// rewritePMT marks H.264 and AAC streams as SAMPLE-AES and adds the descriptors
+// that identify their encryption
+func (e *sampleEncrypter) rewritePMT(section []byte) ([]byte, error) {
+	end := len(section) - crcSize
+	programInfoLength := int(section[10]&0x0f)<<8 | int(section[11])
+	out := append([]byte(nil), section[:12+programInfoLength]...)
+
+	for i := 12 + programInfoLength; i+5 <= end; {
+		streamType := section[i]
+		pid := int(section[i+1]&0x1f)<<8 | int(section[i+2])
+		infoLength := int(section[i+3]&0x0f)<<8 | int(section[i+4])
+		if i+5+infoLength > end {
+			return nil, fmt.Errorf("invalid PMT stream descriptors")
+		}
+		descriptors := append([]byte(nil), section[i+5:i+5+infoLength]...)
+
+		switch streamType {
+		case streamTypeH264:
+			streamType = streamTypeH264SampleAES
+			descriptors = append(descriptors, privateDataIndicator("zavc")...)
+		case streamTypeAAC:
+			if e.audioConfig == nil {
+				return nil, fmt.Errorf("no ADTS header found for AAC stream %d", pid)
+			}
+			streamType = streamTypeAACSampleAES
+			descriptors = append(descriptors, privateDataIndicator("aacd")...)
+			descriptors = append(descriptors, audioSetupInformation(e.audioConfig)...)
+		}
+
+		out = append(out, streamType, 0xe0|byte(pid>>8), byte(pid))
+		out = append(out, 0xf0|byte(len(descriptors)>>8), byte(len(descriptors)))
+		out = append(out, descriptors...)
+		i += 5 + infoLength
+	}
+
+	// section_length counts the bytes after it, including the CRC
+	length := len(out) - 3 + crcSize
+	out[1] = out[1]&0xf0 | byte(length>>8)&0x0f
+	out[2] = byte(length)
+	out = binary.BigEndian.AppendUint32(out, crc32MPEG(out))
+
+	if 1+len(out) > tsPacketSize-tsHeaderSize {
+		return nil, fmt.Errorf("rewritten PMT does not fit in one packet")
+	}
+
+	return out, nil
+}
+
+// encryptPES encrypts the payload of a PES packet and updates its length
+func (e *sampleEncrypter) encryptPES(data []byte, streamType byte) ([]byte, error) {
+	if len(data) < pesHeaderSize || data[0] != 0 || data[1] != 0 || data[2] != 1 {
+		return nil, fmt.Errorf("invalid PES header")
+	}
+	headerLength := pesHeaderSize + int(data[8])
+	if headerLength > len(data) {
+		return nil, fmt.Errorf("truncated PES header")
+	}
+
+	var es []byte
+	var err error
+	if streamType == streamTypeH264 {
+		es = e.encryptH264(data[headerLength:])
+	} else {
+		es, err = e.encryptADTS(data[headerLength:])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pes := append(append([]byte(nil), data[:headerLength]...), es...)
+	if binary.BigEndian.Uint16(pes[4:6]) != 0 {
+		length := len(pes) - 6
+		if length > 0xffff {
+			// Unbounded, which is only allowed for video
+			length = 0
+		}
+		binary.BigEndian.PutUint16(pes[4:6], uint16(length))
+	}
+
+	return pes, nil
+}
+
+// encryptH264 encrypts the slice NAL units of an Annex B access unit. Encryption
+// can produce start code prefixes, so emulation prevention is applied afterwards.
+func (e *sampleEncrypter) encryptH264(es []byte) []byte {
+	out := make([]byte, 0, len(es)+len(es)/64)
+
+	i := findStartCode(es, 0)
+	out = append(out, es[:i]...)
+	for i < len(es) {
+		nalStart := i + 3
+		next := findStartCode(es, nalStart)
+
+		// Zeros before a start code belong to it rather than the NAL unit
+		nalEnd := next
+		for next < len(es) && nalEnd > nalStart && es[nalEnd-1] == 0 {
+			nalEnd--
+		}
+
+		out = append(out, es[i:nalStart]...)
+		out = append(out, e.encryptNAL(es[nalStart:nalEnd])...)
+		out = append(out, es[nalEnd:next]...)
+		i = next
+	}
+
+	return out
+}
+
+// encryptNAL encrypts a coded slice NAL unit, leaving short units in the clear.
+// Players remove emulation prevention before decrypting, so the leader and the
+// encrypted blocks are counted without it.
+func (e *sampleEncrypter) encryptNAL(nal []byte) []byte {
+	if nalType := nal[0] & 0x1f; nalType != 1 && nalType != 5 {
+		return nal
+	}
+	enc := removeEmulationPrevention(nal)
+	if len(enc) <= sampleAESVideoMinimum {
+		return nal
+	}
+
+	cbc := cipher.NewCBCEncrypter(e.block, e.iv)
+	for pos := sampleAESVideoLeader; pos < len(enc); {
+		if len(enc)-pos > aes.BlockSize {
+			cbc.CryptBlocks(enc[pos:pos+aes.BlockSize], enc[pos:pos+aes.BlockSize])
+			pos += aes.BlockSize
+		}
+		pos += min(sampleAESVideoSkip, len(enc)-pos)
+	}
+
+	return insertEmulationPrevention(enc)
+}
+
+// encryptADTS encrypts the whole blocks of every ADTS frame after its leader
+func (e *sampleEncrypter) encryptADTS(es []byte) ([]byte, error) {
+	out := append([]byte(nil), es...)
+
+	for pos := 0; pos+7 <= len(out); {
+		if out[pos] != 0xff || out[pos+1]&0xf0 != 0xf0 {
+			return nil, fmt.Errorf("lost ADTS sync")
+		}
+		headerLength := 7
+		if out[pos+1]&0x01 == 0 {
+			headerLength = 9 // With CRC
+		}
+		frameLength := int(out[pos+3]&0x03)<<11 | int(out[pos+4])<<3 | int(out[pos+5])>>5
+		if frameLength < headerLength || pos+frameLength > len(out) {
+			return nil, fmt.Errorf("truncated ADTS frame")
+		}
+
+		if clear := headerLength + sampleAESAudioLeader; frameLength > clear {
+			payload := out[pos+clear : pos+frameLength]
+			n := len(payload) / aes.BlockSize * aes.BlockSize
+			cipher.NewCBCEncrypter(e.block, e.iv).CryptBlocks(payload[:n], payload[:n])
+		}
+		pos += frameLength
+	}
+
+	return out, nil
+}
+
+// parseTSPacket splits an MPEG-TS packet into its fields
+func parseTSPacket(p []byte) (tsPacket, error) {
+	if p[0] != tsSyncByte {
+		return tsPacket{}, fmt.Errorf("lost MPEG-TS sync")
+	}
+
+	pkt := tsPacket{
+		pid:   int(p[1]&0x1f)<<8 | int(p[2]),
+		start: p[1]&0x40 != 0,
+		cc:    p[3] & 0x0f,
+	}
+
+	offset := tsHeaderSize
+	control := p[3] >> 4 & 0x03
+	if control&0x02 != 0 {
+		length := int(p[4])
+		if tsHeaderSize+1+length > tsPacketSize {
+			return tsPacket{}, fmt.Errorf("invalid adaptation field length")
+		}
+		pkt.adaptation = p[tsHeaderSize : tsHeaderSize+1+length]
+		offset += 1 + length
+	}
+	if control&0x01 != 0 {
+		pkt.payload = p[offset:]
+	}
+
+	return pkt, nil
+}
+
+// writeTSPacket builds an MPEG-TS packet. The adaptation field and payload must
+// fill the packet.
+func writeTSPacket(pid int, start bool, cc byte, adaptation, payload []byte) []byte {
+	flags := byte(pid>>8) & 0x1f
+	if start {
+		flags |= 0x40
+	}
+	control := byte(0)
+	if adaptation != nil {
+		control |= 0x02
+	}
+	if len(payload) > 0 {
+		control |= 0x01
+	}
+
+	p := make([]byte, 0, tsPacketSize)
+	p = append(p, tsSyncByte, flags, byte(pid), control<<4|cc&0x0f)
+	p = append(p, adaptation...)
+	return append(p, payload...)
+}
+
+// packetizePES splits a PES packet into MPEG-TS packets, continuing the PID's
+// continuity counter
+func packetizePES(unit *pesUnit, pes []byte, counters map[int]byte) []byte {
+	var out []byte
+
+	for start := true; len(pes) > 0; start = false {
+		var adaptation []byte
+		if start {
+			adaptation = unit.adaptation
+		}
+
+		n := tsPacketSize - tsHeaderSize - len(adaptation)
+		if len(pes) < n {
+			adaptation = stuffAdaptation(adaptation, n-len(pes))
+			n = len(pes)
+		}
+
+		out = append(out, writeTSPacket(unit.pid, start, counters[unit.pid], adaptation, pes[:n])...)
+		counters[unit.pid] = (counters[unit.pid] + 1) & 0x0f
+		pes = pes[n:]
+	}
+
+	// Packets without payload repeat the previous continuity counter
+	for _, adaptation := range unit.trailing {
+		out = append(out, writeTSPacket(unit.pid, false, (counters[unit.pid]+0x0f)&0x0f, adaptation, nil)...)
+	}
+
+	return out
+}
+
+// trimAdaptation drops the stuffing bytes of an adaptation field so its packet
+// can carry more payload
+func trimAdaptation(adaptation []byte) []byte {
+	if len(adaptation) < 2 {
+		return nil
+	}
+
+	flags := adaptation[1]
+	used := 2
+	if flags&0x10 != 0 {
+		used += 6 // PCR
+	}
+	if flags&0x08 != 0 {
+		used += 6 // OPCR
+	}
+	if flags&0x04 != 0 {
+		used++ // Splice countdown
+	}
+	for _, flag := range []byte{0x02, 0x01} {
+		// Private data and the adaptation field extension carry their own length
+		if flags&flag != 0 && used < len(adaptation) {
+			used += 1 + int(adaptation[used])
+		}
+	}
+	if used > len(adaptation) {
+		return append([]byte(nil), adaptation...)
+	}
+
+	out := append([]byte(nil), adaptation[:used]...)
+	out[0] = byte(used - 1)
+	return out
+}
+
+// stuffAdaptation grows an adaptation field by n bytes of stuffing
+func stuffAdaptation(adaptation []byte, n int) []byte {
+	if adaptation == nil {
+		if n == 1 {
+			return []byte{0x00}
+		}
+		out := bytes.Repeat([]byte{0xff}, n)
+		out[0] = byte(n - 1)
+		out[1] = 0x00 // No flags
+		return out
+	}
+
+	out := append(append([]byte(nil), adaptation...), bytes.Repeat([]byte{0xff}, n)...)
+	out[0] = byte(len(out) - 1)
+	return out
+}
+
+// psiSection returns the section that starts in a PSI packet payload
+func psiSection(payload []byte) ([]byte, error) {
+	if len(payload) < 1 || 1+int(payload[0])+3 > len(payload) {
+		return nil, fmt.Errorf("invalid PSI pointer field")
+	}
+
+	section := payload[1+int(payload[0]):]
+	length := 3 + (int(section[1]&0x0f)<<8 | int(section[2]))
+	if length > len(section) || length < 3+crcSize {
+		return nil, fmt.Errorf("PSI section spans several packets")
+	}
+
+	return section[:length], nil
+}
+
+// psiPacket builds the packet carrying a PSI section
+func psiPacket(pid int, cc byte, section []byte) []byte {
+	payload := bytes.Repeat([]byte{0xff}, tsPacketSize-tsHeaderSize)
+	payload[0] = 0x00 // Pointer field
+	copy(payload[1:], section)
+	return writeTSPacket(pid, true, cc, nil, payload)
+}
+
+// parsePAT returns the PMT PID of the first program
+func parsePAT(section []byte) (int, error) {
+	for i := 8; i+4 <= len(section)-crcSize; i += 4 {
+		if program := int(section[i])<<8 | int(section[i+1]); program != 0 {
+			return int(section[i+2]&0x1f)<<8 | int(section[i+3]), nil
+		}
+	}
+
+	return 0, fmt.Errorf("PAT lists no program")
+}
+
+// pesPayload returns the elementary stream data in the first packet of a PES packet
+func pesPayload(payload []byte) ([]byte, error) {
+	if len(payload) < pesHeaderSize || pesHeaderSize+int(payload[8]) > len(payload) {
+		return nil, fmt.Errorf("invalid PES header")
+	}
+	return payload[pesHeaderSize+int(payload[8]):], nil
+}
+
+// findStartCode returns the position of the next 00 00 01 start code prefix at
+// or after from, or the length of b
+func findStartCode(b []byte, from int) int {
+	if i := bytes.Index(b[min(from, len(b)):], []byte{0, 0, 1}); i >= 0 {
+		return from + i
+	}
+	return len(b)
+}
+
+// removeEmulationPrevention drops the emulation prevention byte that follows
+// two zero bytes, returning a new slice
+func removeEmulationPrevention(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	zeros := 0
+	for _, c := range b {
+		if zeros >= 2 && c == 0x03 {
+			zeros = 0
+			continue
+		}
+		out = append(out, c)
+		if c == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return out
+}
+
+// insertEmulationPrevention escapes byte sequences that would read as start codes
+func insertEmulationPrevention(b []byte) []byte {
+	out := make([]byte, 0, len(b)+len(b)/128)
+	zeros := 0
+	for _, c := range b {
+		if zeros >= 2 && c <= 0x03 {
+			out = append(out, 0x03)
+			zeros = 0
+		}
+		out = append(out, c)
+		if c == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return out
+}
+
+// audioSpecificConfig derives the MPEG-4 AudioSpecificConfig from an ADTS header
+func audioSpecificConfig(header []byte) []byte {
+	objectType := header[2]>>6&0x03 + 1
+	samplingIndex := header[2] >> 2 & 0x0f
+	channels := (header[2]&0x01)<<2 | header[3]>>6
+	return []byte{objectType<<3 | samplingIndex>>1, samplingIndex<<7 | channels<<3}
+}
+
+// privateDataIndicator builds a private_data_indicator_descriptor
+func privateDataIndicator(format string) []byte {
+	return append([]byte{0x0f, 4}, format...)
+}
+
+// audioSetupInformation builds the registration descriptor carrying the
+// audio_setup_information of a SAMPLE-AES AAC stream
+func audioSetupInformation(config []byte) []byte {
+	info := []byte("apad")
+	info = append(info, "zaac"...)
+	info = append(info, 0x00, 0x00) // Priming
+	info = append(info, 0x01)       // Version
+	info = append(info, byte(len(config)))
+	info = append(info, config...)
+	return append([]byte{0x05, byte(len(info))}, info...)
+}
+
+// crc32MPEG computes the CRC-32/MPEG-2 checksum of a PSI section
+func crc32MPEG(b []byte) uint32 {
+	crc := uint32(0xffffffff)
+	for _, c := range b {
+		crc ^= uint32(c) << 24
+		for i := 0; i < 8; i++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
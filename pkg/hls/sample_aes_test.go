@@ -0,0 +1,611 @@
+package hls
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+)
+
+// Fixture stream layout
+const (
+	testPMTPID   = 0x1000
+	testVideoPID = 0x100
+	testAudioPID = 0x101
+)
+
+var testKey = []byte("0123456789abcdef")
+
+// testSegment is a small MPEG-TS segment with one H.264 and one AAC stream
+type testSegment struct {
+	data     []byte
+	videoPES [][]byte // Clear PES packets in stream order
+	audioPES [][]byte
+	pcr      []byte // Adaptation field of the first video packet
+}
+
+func TestSequenceIV(t *testing.T) {
+	want := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01, 0x02}
+	if got := SequenceIV(0x0102); !bytes.Equal(got, want) {
+		t.Errorf("SequenceIV() = %x, want %x", got, want)
+	}
+}
+
+func TestCRC32MPEG(t *testing.T) {
+	// PAT of a single program with PMT PID 0x1000, as written by ffmpeg
+	pat := []byte{0x00, 0xb0, 0x0d, 0x00, 0x01, 0xc1, 0x00, 0x00, 0x00, 0x01, 0xf0, 0x00}
+	if got := crc32MPEG(pat); got != 0x2ab104b2 {
+		t.Errorf("crc32MPEG() = %08x, want 2ab104b2", got)
+	}
+}
+
+func TestEncryptSampleAESRoundTrip(t *testing.T) {
+	seg := buildTestSegment(t)
+	const sequence = 7
+
+	out, err := EncryptSampleAES(seg.data, testKey, sequence)
+	if err != nil {
+		t.Fatalf("EncryptSampleAES() error = %v", err)
+	}
+	if len(out)%tsPacketSize != 0 {
+		t.Fatalf("output is %d bytes, not whole packets", len(out))
+	}
+
+	packets := splitPackets(t, out)
+	checkContinuity(t, packets)
+
+	// The PMT announces the encrypted streams and their descriptors
+	pmt := findPMT(t, packets)
+	streams := pmtStreams(t, pmt)
+	video, audio := streams[testVideoPID], streams[testAudioPID]
+	if video.streamType != streamTypeH264SampleAES {
+		t.Errorf("video stream type = %#x, want %#x", video.streamType, streamTypeH264SampleAES)
+	}
+	if !bytes.Contains(video.descriptors, []byte{0x0f, 4, 'z', 'a', 'v', 'c'}) {
+		t.Errorf("video descriptors %x lack the zavc private data indicator", video.descriptors)
+	}
+	if audio.streamType != streamTypeAACSampleAES {
+		t.Errorf("audio stream type = %#x, want %#x", audio.streamType, streamTypeAACSampleAES)
+	}
+	if !bytes.Contains(audio.descriptors, []byte{0x0f, 4, 'a', 'a', 'c', 'd'}) {
+		t.Errorf("audio descriptors %x lack the aacd private data indicator", audio.descriptors)
+	}
+	// AAC-LC, 44.1 kHz, stereo
+	setup := append([]byte("apadzaac"), 0x00, 0x00, 0x01, 0x02, 0x12, 0x10)
+	if !bytes.Contains(audio.descriptors, append([]byte{0x05, byte(len(setup))}, setup...)) {
+		t.Errorf("audio descriptors %x lack the audio setup information", audio.descriptors)
+	}
+
+	// The tail of a PES packet from the previous segment can't be decrypted and is dropped
+	for _, pkt := range packets {
+		if pkt.pid == testVideoPID {
+			if !pkt.start {
+				t.Error("video packet continuing a PES packet of the previous segment was kept")
+			}
+			break
+		}
+	}
+
+	// The PCR survives on the first video packet
+	for _, pkt := range packets {
+		if pkt.pid == testVideoPID && pkt.start {
+			if pcr := trimAdaptation(pkt.adaptation); !bytes.Equal(pcr, seg.pcr) {
+				t.Errorf("first video adaptation field = %x, want %x", pcr, seg.pcr)
+			}
+			break
+		}
+	}
+
+	iv := SequenceIV(sequence)
+
+	videoPES := collectPES(packets, testVideoPID)
+	if len(videoPES) != len(seg.videoPES) {
+		t.Fatalf("got %d video PES packets, want %d", len(videoPES), len(seg.videoPES))
+	}
+	for i, pes := range videoPES {
+		header, es := splitPES(t, pes)
+		wantHeader, wantES := splitPES(t, seg.videoPES[i])
+		if !bytes.Equal(header, wantHeader) {
+			t.Errorf("video PES %d header = %x, want %x", i, header, wantHeader)
+		}
+		if bytes.Equal(es, wantES) {
+			t.Errorf("video PES %d was not encrypted", i)
+		}
+		if got := decryptH264(t, es, iv); !bytes.Equal(got, wantES) {
+			t.Errorf("video PES %d does not decrypt to the input", i)
+		}
+	}
+
+	audioPES := collectPES(packets, testAudioPID)
+	if len(audioPES) != len(seg.audioPES) {
+		t.Fatalf("got %d audio PES packets, want %d", len(audioPES), len(seg.audioPES))
+	}
+	for i, pes := range audioPES {
+		if length := int(binary.BigEndian.Uint16(pes[4:6])); length != len(pes)-6 {
+			t.Errorf("audio PES %d length = %d, want %d", i, length, len(pes)-6)
+		}
+		_, es := splitPES(t, pes)
+		_, wantES := splitPES(t, seg.audioPES[i])
+		if bytes.Equal(es, wantES) {
+			t.Errorf("audio PES %d was not encrypted", i)
+		}
+		if got := decryptADTS(t, es, iv); !bytes.Equal(got, wantES) {
+			t.Errorf("audio PES %d does not decrypt to the input", i)
+		}
+	}
+}
+
+func TestEncryptSampleAESErrors(t *testing.T) {
+	seg := buildTestSegment(t)
+
+	noPMT := append([]byte(nil), seg.data[tsPacketSize*2:]...)
+	lostSync := append([]byte(nil), seg.data...)
+	lostSync[tsPacketSize*3] = 0x00
+
+	tests := []struct {
+		name string
+		data []byte
+		key  []byte
+	}{
+		{name: "short key", data: seg.data, key: testKey[:8]},
+		{name: "partial packet", data: seg.data[:len(seg.data)-1], key: testKey},
+		{name: "no PMT", data: noPMT, key: testKey},
+		{name: "lost sync", data: lostSync, key: testKey},
+		{name: "truncated ADTS frame", data: truncatedADTSSegment(t), key: testKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := EncryptSampleAES(tt.data, tt.key, 0); err == nil {
+				t.Error("EncryptSampleAES() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestEmulationPrevention(t *testing.T) {
+	tests := []struct {
+		in, want []byte
+	}{
+		{in: []byte{0x00, 0x00, 0x01}, want: []byte{0x00, 0x00, 0x03, 0x01}},
+		{in: []byte{0x00, 0x00, 0x00}, want: []byte{0x00, 0x00, 0x03, 0x00}},
+		{in: []byte{0x00, 0x00, 0x03}, want: []byte{0x00, 0x00, 0x03, 0x03}},
+		{in: []byte{0x00, 0x00, 0x04}, want: []byte{0x00, 0x00, 0x04}},
+		{in: []byte{0x00, 0x01, 0x00, 0x00, 0x02}, want: []byte{0x00, 0x01, 0x00, 0x00, 0x03, 0x02}},
+	}
+
+	for _, tt := range tests {
+		if got := insertEmulationPrevention(tt.in); !bytes.Equal(got, tt.want) {
+			t.Errorf("insertEmulationPrevention(%x) = %x, want %x", tt.in, got, tt.want)
+		}
+		if got := removeEmulationPrevention(tt.want); !bytes.Equal(got, tt.in) {
+			t.Errorf("removeEmulationPrevention(%x) = %x, want %x", tt.want, got, tt.in)
+		}
+	}
+}
+
+// buildTestSegment writes a segment with a PAT, a PMT, two video access units
+// and two audio PES packets of two ADTS frames each. It starts with the tail of
+// a video PES packet from a previous segment.
+func buildTestSegment(t *testing.T) *testSegment {
+	t.Helper()
+	rng := rand.New(rand.NewSource(1))
+	seg := &testSegment{}
+	counters := map[int]byte{testVideoPID: 3, testAudioPID: 9}
+
+	var ts []byte
+	ts = append(ts, testPacket(patPID, true, 0, nil, psiPayload(testPAT()))...)
+	ts = append(ts, testPacket(testPMTPID, true, 0, nil, psiPayload(testPMT(t)))...)
+
+	// The end of a PES packet begun in the previous segment
+	ts = append(ts, testPacket(testVideoPID, false, counters[testVideoPID], nil, bytes.Repeat([]byte{0xaa}, tsPacketSize-tsHeaderSize))...)
+	counters[testVideoPID]++
+
+	seg.pcr = []byte{0x07, 0x10, 0x00, 0x00, 0x7e, 0x90, 0x7e, 0x00}
+	for i := 0; i < 2; i++ {
+		var es []byte
+		es = append(es, 0x00, 0x00, 0x00, 0x01, 0x09, 0xf0) // Access unit delimiter
+		if i == 0 {
+			es = append(es, 0x00, 0x00, 0x01)
+			es = append(es, testNAL(rng, 0x67, 20)...) // SPS
+			es = append(es, 0x00, 0x00, 0x01)
+			es = append(es, testNAL(rng, 0x68, 4)...) // PPS
+			es = append(es, 0x00, 0x00, 0x01)
+			es = append(es, testNAL(rng, 0x65, 1500)...) // IDR slice
+		} else {
+			es = append(es, 0x00, 0x00, 0x01)
+			es = append(es, testNAL(rng, 0x41, 40)...) // Slice too short to encrypt
+			es = append(es, 0x00, 0x00, 0x00, 0x01)
+			es = append(es, testNAL(rng, 0x41, 333)...) // Slice
+		}
+
+		pes := testPES(0xe0, es, false)
+		seg.videoPES = append(seg.videoPES, pes)
+
+		var adaptation []byte
+		if i == 0 {
+			adaptation = seg.pcr
+		}
+		ts = append(ts, testPacketize(testVideoPID, adaptation, pes, counters)...)
+
+		var audio []byte
+		audio = append(audio, testADTS(rng, 210)...)
+		audio = append(audio, testADTS(rng, 23)...) // Too short to encrypt
+		audio = append(audio, testADTS(rng, 187)...)
+		pes = testPES(0xc0, audio, true)
+		seg.audioPES = append(seg.audioPES, pes)
+		ts = append(ts, testPacketize(testAudioPID, nil, pes, counters)...)
+	}
+
+	// A PCR-only packet without payload after the last video PES packet
+	ts = append(ts, testPacket(testVideoPID, false, counters[testVideoPID]-1, testStuffedAdaptation(seg.pcr, tsPacketSize-tsHeaderSize), nil)...)
+
+	seg.data = ts
+	return seg
+}
+
+// truncatedADTSSegment builds a segment whose audio PES packet ends inside an ADTS frame
+func truncatedADTSSegment(t *testing.T) []byte {
+	t.Helper()
+	rng := rand.New(rand.NewSource(2))
+	counters := map[int]byte{}
+
+	var ts []byte
+	ts = append(ts, testPacket(patPID, true, 0, nil, psiPayload(testPAT()))...)
+	ts = append(ts, testPacket(testPMTPID, true, 0, nil, psiPayload(testPMT(t)))...)
+	frame := testADTS(rng, 200)
+	ts = append(ts, testPacketize(testAudioPID, nil, testPES(0xc0, frame[:150], true), counters)...)
+	return ts
+}
+
+// testNAL returns a NAL unit with a random payload of n bytes, escaped as in an
+// Annex B stream and ending with the RBSP stop bit
+func testNAL(rng *rand.Rand, header byte, n int) []byte {
+	payload := make([]byte, n)
+	for i := range payload {
+		// Plenty of zeros so the payload needs emulation prevention
+		if rng.Intn(4) == 0 {
+			payload[i] = 0
+		} else {
+			payload[i] = byte(rng.Intn(256))
+		}
+	}
+	payload[n-1] = 0x80
+
+	return testEscape(append([]byte{header}, payload...))
+}
+
+// testEscape adds emulation prevention to a NAL unit
+func testEscape(b []byte) []byte {
+	var out []byte
+	zeros := 0
+	for _, c := range b {
+		if zeros >= 2 && c <= 0x03 {
+			out = append(out, 0x03)
+			zeros = 0
+		}
+		out = append(out, c)
+		if c == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return out
+}
+
+// testADTS returns an AAC-LC 44.1 kHz stereo ADTS frame of n bytes without CRC
+func testADTS(rng *rand.Rand, n int) []byte {
+	frame := make([]byte, n)
+	rng.Read(frame)
+	frame[0] = 0xff
+	frame[1] = 0xf1
+	frame[2] = 0x50
+	frame[3] = 0x80 | byte(n>>11)&0x03
+	frame[4] = byte(n >> 3)
+	frame[5] = byte(n)<<5 | 0x1f
+	frame[6] = 0xfc
+	return frame
+}
+
+// testPES wraps elementary stream data in a PES packet with a PTS
+func testPES(streamID byte, es []byte, bounded bool) []byte {
+	pes := []byte{0x00, 0x00, 0x01, streamID, 0x00, 0x00, 0x80, 0x80, 0x05, 0x21, 0x00, 0x01, 0x00, 0x01}
+	pes = append(pes, es...)
+	if bounded {
+		binary.BigEndian.PutUint16(pes[4:6], uint16(len(pes)-6))
+	}
+	return pes
+}
+
+// testPAT returns a PAT section listing program 1 with the fixture's PMT PID
+func testPAT() []byte {
+	section := []byte{0x00, 0xb0, 0x0d, 0x00, 0x01, 0xc1, 0x00, 0x00, 0x00, 0x01, 0xe0 | testPMTPID>>8, testPMTPID & 0xff}
+	return binary.BigEndian.AppendUint32(section, crc32MPEG(section))
+}
+
+// testPMT returns a PMT section with an H.264 and an AAC stream
+func testPMT(t *testing.T) []byte {
+	t.Helper()
+	section := []byte{
+		0x02, 0xb0, 0x00, 0x00, 0x01, 0xc1, 0x00, 0x00,
+		0xe0 | testVideoPID>>8, testVideoPID & 0xff, // PCR PID
+		0xf0, 0x00, // No program descriptors
+		streamTypeH264, 0xe0 | testVideoPID>>8, testVideoPID & 0xff, 0xf0, 0x00,
+		streamTypeAAC, 0xe0 | testAudioPID>>8, testAudioPID & 0xff, 0xf0, 0x06,
+		0x0a, 0x04, 'e', 'n', 'g', 0x00, // Language descriptor
+	}
+	section[2] = byte(len(section) - 3 + crcSize)
+	return binary.BigEndian.AppendUint32(section, crc32MPEG(section))
+}
+
+// psiPayload returns a packet payload carrying a PSI section
+func psiPayload(section []byte) []byte {
+	payload := bytes.Repeat([]byte{0xff}, tsPacketSize-tsHeaderSize)
+	payload[0] = 0x00
+	copy(payload[1:], section)
+	return payload
+}
+
+// testPacket writes a 188-byte MPEG-TS packet
+func testPacket(pid int, start bool, cc byte, adaptation, payload []byte) []byte {
+	control := byte(0)
+	if adaptation != nil {
+		control |= 0x20
+	}
+	if payload != nil {
+		control |= 0x10
+	}
+	b1 := byte(pid>>8) & 0x1f
+	if start {
+		b1 |= 0x40
+	}
+	p := []byte{tsSyncByte, b1, byte(pid), control | cc&0x0f}
+	p = append(p, adaptation...)
+	p = append(p, payload...)
+	if len(p) != tsPacketSize {
+		panic("test packet is not 188 bytes")
+	}
+	return p
+}
+
+// testPacketize splits a PES packet into MPEG-TS packets, stuffing the last one
+func testPacketize(pid int, adaptation, pes []byte, counters map[int]byte) []byte {
+	var out []byte
+	for start := true; len(pes) > 0; start = false {
+		var af []byte
+		if start {
+			af = adaptation
+		}
+		room := tsPacketSize - tsHeaderSize - len(af)
+		n := min(room, len(pes))
+		if n < room {
+			af = testStuffedAdaptation(af, tsPacketSize-tsHeaderSize-n)
+		}
+		out = append(out, testPacket(pid, start, counters[pid], af, pes[:n])...)
+		counters[pid] = (counters[pid] + 1) & 0x0f
+		pes = pes[n:]
+	}
+	return out
+}
+
+// testStuffedAdaptation returns an adaptation field of size bytes based on af
+func testStuffedAdaptation(af []byte, size int) []byte {
+	if af == nil {
+		if size == 1 {
+			return []byte{0x00}
+		}
+		af = []byte{0x00, 0x00}
+	}
+	out := append([]byte(nil), af...)
+	for len(out) < size {
+		out = append(out, 0xff)
+	}
+	out[0] = byte(size - 1)
+	return out
+}
+
+// splitPackets parses every packet of a segment
+func splitPackets(t *testing.T, data []byte) []tsPacket {
+	t.Helper()
+	var packets []tsPacket
+	for offset := 0; offset < len(data); offset += tsPacketSize {
+		pkt, err := parseTSPacket(data[offset : offset+tsPacketSize])
+		if err != nil {
+			t.Fatalf("packet %d: %v", offset/tsPacketSize, err)
+		}
+		packets = append(packets, pkt)
+	}
+	return packets
+}
+
+// checkContinuity checks that the continuity counter of every PID increases by
+// one with each packet carrying payload and repeats on packets without
+func checkContinuity(t *testing.T, packets []tsPacket) {
+	t.Helper()
+	last := make(map[int]byte)
+	for i, pkt := range packets {
+		prev, seen := last[pkt.pid]
+		last[pkt.pid] = pkt.cc
+		if !seen {
+			continue
+		}
+		want := (prev + 1) & 0x0f
+		if len(pkt.payload) == 0 {
+			want = prev
+		}
+		if pkt.cc != want {
+			t.Errorf("packet %d on PID %#x has continuity counter %d, want %d", i, pkt.pid, pkt.cc, want)
+		}
+	}
+}
+
+// findPMT returns the PMT section of a segment and checks its CRC
+func findPMT(t *testing.T, packets []tsPacket) []byte {
+	t.Helper()
+	for _, pkt := range packets {
+		if pkt.pid == testPMTPID && pkt.start {
+			section, err := psiSection(pkt.payload)
+			if err != nil {
+				t.Fatalf("PMT: %v", err)
+			}
+			// The CRC of a section including its own CRC is zero
+			if crc32MPEG(section) != 0 {
+				t.Errorf("PMT CRC is invalid")
+			}
+			return section
+		}
+	}
+	t.Fatal("segment has no PMT")
+	return nil
+}
+
+type testStream struct {
+	streamType  byte
+	descriptors []byte
+}
+
+// pmtStreams parses the elementary streams of a PMT section
+func pmtStreams(t *testing.T, section []byte) map[int]testStream {
+	t.Helper()
+	streams := make(map[int]testStream)
+	end := len(section) - crcSize
+	i := 12 + (int(section[10]&0x0f)<<8 | int(section[11]))
+	for i < end {
+		if i+5 > end {
+			t.Fatalf("PMT stream entry at %d is truncated", i)
+		}
+		pid := int(section[i+1]&0x1f)<<8 | int(section[i+2])
+		length := int(section[i+3]&0x0f)<<8 | int(section[i+4])
+		if i+5+length > end {
+			t.Fatalf("PMT descriptors of PID %#x are truncated", pid)
+		}
+		streams[pid] = testStream{streamType: section[i], descriptors: section[i+5 : i+5+length]}
+		i += 5 + length
+	}
+	if i != end {
+		t.Errorf("PMT streams end at %d, want %d", i, end)
+	}
+	return streams
+}
+
+// collectPES reassembles the PES packets of a PID, ignoring packets before the first start
+func collectPES(packets []tsPacket, pid int) [][]byte {
+	var out [][]byte
+	for _, pkt := range packets {
+		if pkt.pid != pid {
+			continue
+		}
+		if pkt.start {
+			out = append(out, nil)
+		}
+		if len(out) > 0 {
+			out[len(out)-1] = append(out[len(out)-1], pkt.payload...)
+		}
+	}
+	return out
+}
+
+// splitPES splits a PES packet into its header and elementary stream data
+func splitPES(t *testing.T, pes []byte) ([]byte, []byte) {
+	t.Helper()
+	if len(pes) < pesHeaderSize || !bytes.HasPrefix(pes, []byte{0, 0, 1}) {
+		t.Fatalf("invalid PES packet %x", pes[:min(len(pes), 16)])
+	}
+	n := pesHeaderSize + int(pes[8])
+	return pes[:n], pes[n:]
+}
+
+// decryptH264 decrypts the slices of an Annex B access unit the way players do:
+// emulation prevention is removed first, then from byte 32 of each slice longer
+// than 48 bytes one block in ten is decrypted, the CBC chain restarting with
+// every NAL unit
+func decryptH264(t *testing.T, es, iv []byte) []byte {
+	t.Helper()
+	block, _ := aes.NewCipher(testKey)
+
+	var out []byte
+	i := bytes.Index(es, []byte{0, 0, 1})
+	if i < 0 {
+		t.Fatal("access unit has no start code")
+	}
+	out = append(out, es[:i]...)
+	for i < len(es) {
+		nalStart := i + 3
+		next := len(es)
+		if j := bytes.Index(es[nalStart:], []byte{0, 0, 1}); j >= 0 {
+			next = nalStart + j
+		}
+		nalEnd := next
+		for next < len(es) && nalEnd > nalStart && es[nalEnd-1] == 0 {
+			nalEnd--
+		}
+
+		nal := es[nalStart:nalEnd]
+		if nalType := nal[0] & 0x1f; nalType == 1 || nalType == 5 {
+			nal = testUnescape(nal)
+			if len(nal) > 48 {
+				cbc := cipher.NewCBCDecrypter(block, iv)
+				for pos := 32; pos < len(nal); {
+					if len(nal)-pos > 16 {
+						cbc.CryptBlocks(nal[pos:pos+16], nal[pos:pos+16])
+						pos += 16
+					}
+					pos += min(144, len(nal)-pos)
+				}
+			}
+			// Escape the decrypted slice again to compare it with the input stream
+			nal = testEscape(nal)
+		}
+
+		out = append(out, es[i:nalStart]...)
+		out = append(out, nal...)
+		out = append(out, es[nalEnd:next]...)
+		i = next
+	}
+	return out
+}
+
+// testUnescape removes emulation prevention from a NAL unit
+func testUnescape(b []byte) []byte {
+	var out []byte
+	zeros := 0
+	for _, c := range b {
+		if zeros >= 2 && c == 0x03 {
+			zeros = 0
+			continue
+		}
+		out = append(out, c)
+		if c == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return out
+}
+
+// decryptADTS decrypts the ADTS frames of an audio PES payload following the
+// SAMPLE-AES rules: after the header and 16 clear bytes, every whole block of
+// the frame is decrypted, the CBC chain restarting with every frame
+func decryptADTS(t *testing.T, es, iv []byte) []byte {
+	t.Helper()
+	block, _ := aes.NewCipher(testKey)
+
+	out := append([]byte(nil), es...)
+	for pos := 0; pos < len(out); {
+		if pos+7 > len(out) || out[pos] != 0xff || out[pos+1]&0xf0 != 0xf0 {
+			t.Fatalf("lost ADTS sync at %d", pos)
+		}
+		length := int(out[pos+3]&0x03)<<11 | int(out[pos+4])<<3 | int(out[pos+5])>>5
+		if clear := 7 + 16; length > clear {
+			payload := out[pos+clear : pos+length]
+			n := len(payload) / 16 * 16
+			cipher.NewCBCDecrypter(block, iv).CryptBlocks(payload[:n], payload[:n])
+		}
+		pos += length
+	}
+	return out
+}
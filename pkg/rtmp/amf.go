@@ -0,0 +1,224 @@
+package rtmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// AMF0 type markers
+const (
+	amfNumber      = 0x00
+	amfBoolean     = 0x01
+	amfString      = 0x02
+	amfObject      = 0x03
+	amfNull        = 0x05
+	amfUndefined   = 0x06
+	amfECMAArray   = 0x08
+	amfObjectEnd   = 0x09
+	amfStrictArray = 0x0a
+	amfDate        = 0x0b
+	amfLongString  = 0x0c
+)
+
+// amfObjectValue is an AMF0 object or ECMA array. Properties are encoded in key order.
+type amfObjectValue map[string]interface{}
+
+// decodeAMF decodes a sequence of AMF0 values. Numbers decode to float64,
+// strings to string, objects to amfObjectValue and null or undefined to nil.
+func decodeAMF(data []byte) ([]interface{}, error) {
+	d := &amfDecoder{data: data}
+
+	var values []interface{}
+	for d.pos < len(d.data) {
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+// amfDecoder reads AMF0 values from a buffer
+type amfDecoder struct {
+	data []byte
+	pos  int
+}
+
+// read consumes the next n bytes
+func (d *amfDecoder) read(n int) ([]byte, error) {
+	if d.pos+n > len(d.data) {
+		return nil, errors.New("truncated AMF0 data")
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// value decodes the next value including its type marker
+func (d *amfDecoder) value() (interface{}, error) {
+	marker, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch marker[0] {
+	case amfNumber:
+		b, err := d.read(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case amfBoolean:
+		b, err := d.read(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case amfString:
+		return d.string(2)
+	case amfLongString:
+		return d.string(4)
+	case amfObject:
+		return d.properties()
+	case amfECMAArray:
+		// The count is only a hint, the properties end with an object end marker
+		if _, err := d.read(4); err != nil {
+			return nil, err
+		}
+		return d.properties()
+	case amfStrictArray:
+		b, err := d.read(4)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, 0)
+		for i := uint32(0); i < binary.BigEndian.Uint32(b); i++ {
+			value, err := d.value()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case amfDate:
+		// Milliseconds since the epoch followed by an unused time zone
+		b, err := d.read(10)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case amfNull, amfUndefined:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported AMF0 type marker 0x%02x", marker[0])
+	}
+}
+
+// string decodes a string whose length prefix has lengthSize bytes
+func (d *amfDecoder) string(lengthSize int) (string, error) {
+	b, err := d.read(lengthSize)
+	if err != nil {
+		return "", err
+	}
+
+	length := 0
+	for _, v := range b {
+		length = length<<8 | int(v)
+	}
+
+	s, err := d.read(length)
+	if err != nil {
+		return "", err
+	}
+	return string(s), nil
+}
+
+// properties decodes object properties up to the object end marker
+func (d *amfDecoder) properties() (amfObjectValue, error) {
+	object := amfObjectValue{}
+	for {
+		key, err := d.string(2)
+		if err != nil {
+			return nil, err
+		}
+
+		if key == "" {
+			marker, err := d.read(1)
+			if err != nil {
+				return nil, err
+			}
+			if marker[0] != amfObjectEnd {
+				return nil, errors.New("missing AMF0 object end marker")
+			}
+			return object, nil
+		}
+
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		object[key] = value
+	}
+}
+
+// encodeAMF encodes values as AMF0. Supported types are nil, bool, float64,
+// int, string and amfObjectValue.
+func encodeAMF(values ...interface{}) []byte {
+	var b bytes.Buffer
+	for _, value := range values {
+		writeAMF(&b, value)
+	}
+	return b.Bytes()
+}
+
+// writeAMF encodes a single value with its type marker
+func writeAMF(b *bytes.Buffer, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		b.WriteByte(amfNull)
+	case bool:
+		b.WriteByte(amfBoolean)
+		if v {
+			b.WriteByte(1)
+		} else {
+			b.WriteByte(0)
+		}
+	case int:
+		writeAMF(b, float64(v))
+	case float64:
+		b.WriteByte(amfNumber)
+		_ = binary.Write(b, binary.BigEndian, math.Float64bits(v))
+	case string:
+		if len(v) > math.MaxUint16 {
+			b.WriteByte(amfLongString)
+			_ = binary.Write(b, binary.BigEndian, uint32(len(v)))
+		} else {
+			b.WriteByte(amfString)
+			_ = binary.Write(b, binary.BigEndian, uint16(len(v)))
+		}
+		b.WriteString(v)
+	case amfObjectValue:
+		b.WriteByte(amfObject)
+
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			_ = binary.Write(b, binary.BigEndian, uint16(len(key)))
+			b.WriteString(key)
+			writeAMF(b, v[key])
+		}
+		b.Write([]byte{0, 0, amfObjectEnd})
+	default:
+		b.WriteByte(amfUndefined)
+	}
+}
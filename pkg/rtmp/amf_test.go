@@ -0,0 +1,153 @@
+package rtmp
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testNumber encodes the eight bytes of an AMF0 number
+func testNumber(v float64) []byte {
+	return binary.BigEndian.AppendUint64(nil, math.Float64bits(v))
+}
+
+// testBytes joins byte slices
+func testBytes(parts ...[]byte) []byte {
+	var b []byte
+	for _, part := range parts {
+		b = append(b, part...)
+	}
+	return b
+}
+
+func TestDecodeAMF(t *testing.T) {
+	longString := strings.Repeat("a", math.MaxUint16+1)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []interface{}
+		wantErr bool
+	}{
+		{
+			name: "connect command",
+			data: encodeAMF("connect", 1, amfObjectValue{"app": "live", "tcUrl": "rtmp://localhost/live"}),
+			want: []interface{}{"connect", 1.0, amfObjectValue{"app": "live", "tcUrl": "rtmp://localhost/live"}},
+		},
+		{
+			name: "publish command",
+			data: encodeAMF("publish", 5, nil, "stream-key", "live"),
+			want: []interface{}{"publish", 5.0, nil, "stream-key", "live"},
+		},
+		{
+			name: "booleans",
+			data: encodeAMF(true, false),
+			want: []interface{}{true, false},
+		},
+		{
+			name: "long string",
+			data: encodeAMF(longString),
+			want: []interface{}{longString},
+		},
+		{
+			name: "nested object",
+			data: encodeAMF(amfObjectValue{"outer": amfObjectValue{"inner": 2}}),
+			want: []interface{}{amfObjectValue{"outer": amfObjectValue{"inner": 2.0}}},
+		},
+		{
+			name: "ecma array",
+			data: testBytes([]byte{amfECMAArray, 0, 0, 0, 1, 0, 8}, []byte("duration"), []byte{amfNumber}, testNumber(1.5), []byte{0, 0, amfObjectEnd}),
+			want: []interface{}{amfObjectValue{"duration": 1.5}},
+		},
+		{
+			name: "strict array",
+			data: testBytes([]byte{amfStrictArray, 0, 0, 0, 2, amfNumber}, testNumber(1), []byte{amfNull}),
+			want: []interface{}{[]interface{}{1.0, nil}},
+		},
+		{
+			name: "date",
+			data: testBytes([]byte{amfDate}, testNumber(1.6e12), []byte{0, 0}),
+			want: []interface{}{1.6e12},
+		},
+		{
+			name: "undefined",
+			data: []byte{amfUndefined},
+			want: []interface{}{nil},
+		},
+		{
+			name: "empty",
+			data: nil,
+			want: nil,
+		},
+		{
+			name:    "truncated number",
+			data:    []byte{amfNumber, 0x3f, 0xf0},
+			wantErr: true,
+		},
+		{
+			name:    "truncated string",
+			data:    []byte{amfString, 0, 5, 'a'},
+			wantErr: true,
+		},
+		{
+			name:    "truncated strict array",
+			data:    []byte{amfStrictArray, 0, 0, 0, 3, amfNull},
+			wantErr: true,
+		},
+		{
+			name:    "object without end marker",
+			data:    []byte{amfObject, 0, 1, 'a', amfNull},
+			wantErr: true,
+		},
+		{
+			name:    "wrong object end marker",
+			data:    []byte{amfObject, 0, 0, amfNull},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported type marker",
+			data:    []byte{0x0d},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeAMF(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeAMF() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeAMF() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeAMFTruncated(t *testing.T) {
+	values := []interface{}{
+		"connect",
+		1,
+		amfObjectValue{"app": "live", "flashVer": "FMLE/3.0", "nested": amfObjectValue{"ok": true}},
+		nil,
+	}
+
+	// Cuts between values decode the values before them, all others must fail
+	var data []byte
+	boundaries := map[int]bool{}
+	for _, value := range values {
+		data = append(data, encodeAMF(value)...)
+		boundaries[len(data)] = true
+	}
+
+	for n := 1; n < len(data); n++ {
+		if boundaries[n] {
+			continue
+		}
+		if _, err := decodeAMF(data[:n]); err == nil {
+			t.Errorf("decodeAMF() of %d of %d bytes succeeded, want an error", n, len(data))
+		}
+	}
+}
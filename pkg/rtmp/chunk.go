@@ -0,0 +1,312 @@
+package rtmp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Message type IDs
+const (
+	msgSetChunkSize     = 1
+	msgAbort            = 2
+	msgAcknowledgement  = 3
+	msgUserControl      = 4
+	msgWindowAckSize    = 5
+	msgSetPeerBandwidth = 6
+	msgAudio            = 8
+	msgVideo            = 9
+	msgDataAMF3         = 15
+	msgCommandAMF3      = 17
+	msgDataAMF0         = 18
+	msgCommandAMF0      = 20
+)
+
+// defaultChunkSize is the chunk size of both directions until changed
+const defaultChunkSize = 128
+
+// extendedTimestamp marks a timestamp carried in the extended timestamp field
+const extendedTimestamp = 0xffffff
+
+// Limits on what a client can make the server buffer. Message headers may claim
+// up to 16 MiB for each of 65,599 chunk streams, long before a stream key is checked.
+const (
+	maxMessageLength = 4 << 20  // Well above the keyframes of the highest live rendition
+	maxChunkStreams  = 64       // Encoders use a handful
+	payloadStep      = 64 << 10 // Largest payload growth before its bytes are read
+)
+
+// message is a complete RTMP message
+type message struct {
+	typeID    uint8
+	streamID  uint32
+	timestamp uint32 // Milliseconds
+	payload   []byte
+}
+
+// chunkStream is the state of a chunk stream while its messages are read
+type chunkStream struct {
+	timestamp uint32
+	delta     uint32
+	length    uint32
+	typeID    uint8
+	streamID  uint32
+	extended  bool
+	payload   []byte // Message being assembled
+}
+
+// chunkReader reassembles messages from interleaved chunk streams
+type chunkReader struct {
+	r         *bufio.Reader
+	chunkSize uint32
+	streams   map[uint32]*chunkStream
+}
+
+// newChunkReader creates a chunk reader
+func newChunkReader(r *bufio.Reader) *chunkReader {
+	return &chunkReader{
+		r:         r,
+		chunkSize: defaultChunkSize,
+		streams:   make(map[uint32]*chunkStream),
+	}
+}
+
+// readMessage reads chunks until a message is complete
+func (cr *chunkReader) readMessage() (*message, error) {
+	for {
+		msg, err := cr.readChunk()
+		if err != nil {
+			return nil, err
+		}
+		if msg != nil {
+			return msg, nil
+		}
+	}
+}
+
+// abort discards the partly received message of a chunk stream
+func (cr *chunkReader) abort(csid uint32) {
+	if cs, ok := cr.streams[csid]; ok {
+		cs.payload = nil
+	}
+}
+
+// assembling reports whether a message has been partly received
+func (cr *chunkReader) assembling() bool {
+	for _, cs := range cr.streams {
+		if len(cs.payload) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// readChunk reads a single chunk, returning the message it completes, if any
+func (cr *chunkReader) readChunk() (msg *message, err error) {
+	b0, err := cr.r.ReadByte()
+	if err != nil {
+		if errors.Is(err, io.EOF) && cr.assembling() {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	// Input may only end between chunks
+	defer func() {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+	}()
+
+	// Basic header: format and chunk stream ID, which may take one or two more bytes
+	format := b0 >> 6
+	csid := uint32(b0 & 0x3f)
+	switch csid {
+	case 0:
+		b, err := cr.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		csid = 64 + uint32(b)
+	case 1:
+		var b [2]byte
+		if _, err := io.ReadFull(cr.r, b[:]); err != nil {
+			return nil, err
+		}
+		csid = 64 + uint32(b[0]) + uint32(b[1])<<8
+	}
+
+	cs, ok := cr.streams[csid]
+	if !ok {
+		if format != 0 {
+			return nil, fmt.Errorf("chunk stream %d starts without a full header", csid)
+		}
+		if len(cr.streams) >= maxChunkStreams {
+			return nil, fmt.Errorf("too many chunk streams, at most %d are allowed", maxChunkStreams)
+		}
+		cs = &chunkStream{}
+		cr.streams[csid] = cs
+	}
+
+	// Message header, whose size depends on the format
+	var header [11]byte
+	headerSize := [4]int{11, 7, 3, 0}[format]
+	if _, err := io.ReadFull(cr.r, header[:headerSize]); err != nil {
+		return nil, err
+	}
+
+	timestamp := cs.delta
+	if format < 3 {
+		timestamp = uint24(header[0:3])
+		cs.extended = timestamp == extendedTimestamp
+	}
+	if format < 2 {
+		cs.length = uint24(header[3:6])
+		cs.typeID = header[6]
+		if cs.length > maxMessageLength {
+			return nil, fmt.Errorf("message of %d bytes exceeds the %d byte limit", cs.length, maxMessageLength)
+		}
+	}
+	if format == 0 {
+		cs.streamID = binary.LittleEndian.Uint32(header[7:11])
+	}
+	if cs.extended {
+		var b [4]byte
+		if _, err := io.ReadFull(cr.r, b[:]); err != nil {
+			return nil, err
+		}
+		timestamp = binary.BigEndian.Uint32(b[:])
+	}
+
+	// Chunks with a header start a new message, continuation chunks have none
+	if format < 3 {
+		cs.payload = nil
+	}
+	if len(cs.payload) == 0 {
+		switch format {
+		case 0:
+			// A following format 3 chunk repeats the timestamp as its delta
+			cs.timestamp = timestamp
+			cs.delta = timestamp
+		default:
+			cs.delta = timestamp
+			cs.timestamp += timestamp
+		}
+	}
+
+	size := cs.length - uint32(len(cs.payload))
+	if size > cr.chunkSize {
+		size = cr.chunkSize
+	}
+	if err := cs.readPayload(cr.r, size); err != nil {
+		return nil, err
+	}
+
+	if uint32(len(cs.payload)) < cs.length {
+		return nil, nil
+	}
+
+	msg = &message{
+		typeID:    cs.typeID,
+		streamID:  cs.streamID,
+		timestamp: cs.timestamp,
+		payload:   cs.payload,
+	}
+	cs.payload = nil
+
+	return msg, nil
+}
+
+// readPayload appends size bytes to the message being assembled. The payload
+// grows in steps as the bytes arrive rather than by the length in the header.
+func (cs *chunkStream) readPayload(r io.Reader, size uint32) error {
+	for size > 0 {
+		step := min(size, payloadStep)
+		start := len(cs.payload)
+		cs.payload = append(cs.payload, make([]byte, step)...)
+		if _, err := io.ReadFull(r, cs.payload[start:]); err != nil {
+			return err
+		}
+		size -= step
+	}
+	return nil
+}
+
+// chunkWriter splits messages into chunks
+type chunkWriter struct {
+	w         *bufio.Writer
+	chunkSize uint32
+}
+
+// newChunkWriter creates a chunk writer
+func newChunkWriter(w *bufio.Writer) *chunkWriter {
+	return &chunkWriter{
+		w:         w,
+		chunkSize: defaultChunkSize,
+	}
+}
+
+// writeMessage writes a message on a chunk stream below 64, using a full
+// header for the first chunk and continuation headers for the rest
+func (cw *chunkWriter) writeMessage(csid uint32, msg *message) error {
+	if csid < 2 || csid > 63 {
+		return errors.New("unsupported chunk stream ID")
+	}
+
+	timestamp := msg.timestamp
+	if timestamp >= extendedTimestamp {
+		timestamp = extendedTimestamp
+	}
+
+	header := make([]byte, 12, 16)
+	header[0] = byte(csid)
+	putUint24(header[1:4], timestamp)
+	putUint24(header[4:7], uint32(len(msg.payload)))
+	header[7] = msg.typeID
+	binary.LittleEndian.PutUint32(header[8:12], msg.streamID)
+	if timestamp == extendedTimestamp {
+		header = binary.BigEndian.AppendUint32(header, msg.timestamp)
+	}
+	if _, err := cw.w.Write(header); err != nil {
+		return err
+	}
+
+	for offset := 0; ; {
+		end := offset + int(cw.chunkSize)
+		if end > len(msg.payload) {
+			end = len(msg.payload)
+		}
+		if _, err := cw.w.Write(msg.payload[offset:end]); err != nil {
+			return err
+		}
+
+		offset = end
+		if offset >= len(msg.payload) {
+			break
+		}
+
+		continuation := []byte{0xc0 | byte(csid)}
+		if timestamp == extendedTimestamp {
+			continuation = binary.BigEndian.AppendUint32(continuation, msg.timestamp)
+		}
+		if _, err := cw.w.Write(continuation); err != nil {
+			return err
+		}
+	}
+
+	return cw.w.Flush()
+}
+
+// uint24 decodes a big-endian 24-bit integer
+func uint24(b []byte) uint32 {
+	return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+}
+
+// putUint24 encodes a big-endian 24-bit integer
+func putUint24(b []byte, v uint32) {
+	b[0] = byte(v >> 16)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v)
+}
@@ -0,0 +1,432 @@
+package rtmp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testBasicHeader encodes a chunk basic header in its one, two or three byte form
+func testBasicHeader(format byte, csid uint32) []byte {
+	switch {
+	case csid < 64:
+		return []byte{format<<6 | byte(csid)}
+	case csid < 320:
+		return []byte{format << 6, byte(csid - 64)}
+	default:
+		return []byte{format<<6 | 1, byte(csid - 64), byte((csid - 64) >> 8)}
+	}
+}
+
+// testTimestamp encodes a timestamp or delta field and its extended timestamp, if needed
+func testTimestamp(timestamp uint32) ([]byte, []byte) {
+	field := make([]byte, 3)
+	if timestamp < extendedTimestamp {
+		putUint24(field, timestamp)
+		return field, nil
+	}
+	putUint24(field, extendedTimestamp)
+	return field, binary.BigEndian.AppendUint32(nil, timestamp)
+}
+
+// testChunk0 encodes a chunk with a full message header
+func testChunk0(csid, timestamp, length uint32, typeID uint8, streamID uint32, data string) []byte {
+	field, extended := testTimestamp(timestamp)
+	chunk := append(testBasicHeader(0, csid), field...)
+	chunk = append(chunk, byte(length>>16), byte(length>>8), byte(length), typeID)
+	chunk = binary.LittleEndian.AppendUint32(chunk, streamID)
+	chunk = append(chunk, extended...)
+	return append(chunk, data...)
+}
+
+// testChunk1 encodes a chunk with a header that keeps the message stream ID
+func testChunk1(csid, delta, length uint32, typeID uint8, data string) []byte {
+	field, extended := testTimestamp(delta)
+	chunk := append(testBasicHeader(1, csid), field...)
+	chunk = append(chunk, byte(length>>16), byte(length>>8), byte(length), typeID)
+	chunk = append(chunk, extended...)
+	return append(chunk, data...)
+}
+
+// testChunk2 encodes a chunk with a header carrying only the timestamp delta
+func testChunk2(csid, delta uint32, data string) []byte {
+	field, extended := testTimestamp(delta)
+	chunk := append(testBasicHeader(2, csid), field...)
+	chunk = append(chunk, extended...)
+	return append(chunk, data...)
+}
+
+// testChunk3 encodes a chunk without a message header. extended repeats an
+// extended timestamp, as required while the chunk stream uses one.
+func testChunk3(csid uint32, extended []byte, data string) []byte {
+	chunk := append(testBasicHeader(3, csid), extended...)
+	return append(chunk, data...)
+}
+
+// testChunks joins chunks into a single input
+func testChunks(chunks ...[]byte) []byte {
+	return bytes.Join(chunks, nil)
+}
+
+// readTestMessages reads messages until the input ends
+func readTestMessages(data []byte, chunkSize uint32) ([]*message, error) {
+	reader := newChunkReader(bufio.NewReader(bytes.NewReader(data)))
+	reader.chunkSize = chunkSize
+
+	var messages []*message
+	for {
+		msg, err := reader.readMessage()
+		if errors.Is(err, io.EOF) {
+			return messages, nil
+		}
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, msg)
+	}
+}
+
+// checkMessages compares received messages with the expected ones
+func checkMessages(t *testing.T, got, want []*message) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].typeID != want[i].typeID ||
+			got[i].streamID != want[i].streamID ||
+			got[i].timestamp != want[i].timestamp ||
+			!bytes.Equal(got[i].payload, want[i].payload) {
+			t.Errorf("message %d = {type %d, stream %d, time %d, %q}, want {type %d, stream %d, time %d, %q}", i,
+				got[i].typeID, got[i].streamID, got[i].timestamp, got[i].payload,
+				want[i].typeID, want[i].streamID, want[i].timestamp, want[i].payload)
+		}
+	}
+}
+
+func TestChunkReader(t *testing.T) {
+	long := strings.Repeat("0123456789", 20)
+	_, extended := testTimestamp(0x1000000)
+
+	tests := []struct {
+		name      string
+		data      []byte
+		chunkSize uint32
+		want      []*message
+	}{
+		{
+			name: "format 0",
+			data: testChunk0(3, 1000, 5, msgCommandAMF0, 0, "hello"),
+			want: []*message{{typeID: msgCommandAMF0, timestamp: 1000, payload: []byte("hello")}},
+		},
+		{
+			name: "format 3 continues a message",
+			data: testChunks(
+				testChunk0(4, 40, uint32(len(long)), msgVideo, 1, long[:128]),
+				testChunk3(4, nil, long[128:]),
+			),
+			want: []*message{{typeID: msgVideo, streamID: 1, timestamp: 40, payload: []byte(long)}},
+		},
+		{
+			name: "formats 1 to 3 start messages with a delta",
+			data: testChunks(
+				testChunk0(4, 1000, 3, msgVideo, 1, "abc"),
+				testChunk1(4, 40, 2, msgAudio, "de"),
+				testChunk2(4, 20, "fg"),
+				testChunk3(4, nil, "hi"),
+			),
+			want: []*message{
+				{typeID: msgVideo, streamID: 1, timestamp: 1000, payload: []byte("abc")},
+				{typeID: msgAudio, streamID: 1, timestamp: 1040, payload: []byte("de")},
+				{typeID: msgAudio, streamID: 1, timestamp: 1060, payload: []byte("fg")},
+				{typeID: msgAudio, streamID: 1, timestamp: 1080, payload: []byte("hi")},
+			},
+		},
+		{
+			name: "format 3 after format 0 repeats the timestamp as its delta",
+			data: testChunks(
+				testChunk0(4, 40, 1, msgAudio, 1, "a"),
+				testChunk3(4, nil, "b"),
+			),
+			want: []*message{
+				{typeID: msgAudio, streamID: 1, timestamp: 40, payload: []byte("a")},
+				{typeID: msgAudio, streamID: 1, timestamp: 80, payload: []byte("b")},
+			},
+		},
+		{
+			name:      "interleaved chunk streams",
+			chunkSize: 4,
+			data: testChunks(
+				testChunk0(4, 0, 6, msgVideo, 1, "AAAA"),
+				testChunk0(6, 0, 6, msgAudio, 1, "BBBB"),
+				testChunk3(4, nil, "aa"),
+				testChunk3(6, nil, "bb"),
+			),
+			want: []*message{
+				{typeID: msgVideo, streamID: 1, payload: []byte("AAAAaa")},
+				{typeID: msgAudio, streamID: 1, payload: []byte("BBBBbb")},
+			},
+		},
+		{
+			name: "two byte chunk stream ID",
+			data: testChunk0(100, 0, 1, msgAudio, 1, "a"),
+			want: []*message{{typeID: msgAudio, streamID: 1, payload: []byte("a")}},
+		},
+		{
+			name: "three byte chunk stream ID",
+			data: testChunk0(400, 0, 1, msgAudio, 1, "a"),
+			want: []*message{{typeID: msgAudio, streamID: 1, payload: []byte("a")}},
+		},
+		{
+			name:      "extended timestamp repeated by continuation chunks",
+			chunkSize: 4,
+			data: testChunks(
+				testChunk0(4, 0x1000000, 6, msgVideo, 1, "abcd"),
+				testChunk3(4, extended, "ef"),
+			),
+			want: []*message{{typeID: msgVideo, streamID: 1, timestamp: 0x1000000, payload: []byte("abcdef")}},
+		},
+		{
+			name: "extended timestamp delta",
+			data: testChunks(
+				testChunk0(4, 10, 1, msgVideo, 1, "a"),
+				testChunk1(4, 0x1000000, 1, msgVideo, "b"),
+			),
+			want: []*message{
+				{typeID: msgVideo, streamID: 1, timestamp: 10, payload: []byte("a")},
+				{typeID: msgVideo, streamID: 1, timestamp: 0x100000a, payload: []byte("b")},
+			},
+		},
+		{
+			name: "empty message",
+			data: testChunk0(4, 0, 0, msgDataAMF0, 1, ""),
+			want: []*message{{typeID: msgDataAMF0, streamID: 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunkSize := tt.chunkSize
+			if chunkSize == 0 {
+				chunkSize = defaultChunkSize
+			}
+
+			got, err := readTestMessages(tt.data, chunkSize)
+			if err != nil {
+				t.Fatalf("readMessage() error = %v", err)
+			}
+			checkMessages(t, got, tt.want)
+		})
+	}
+}
+
+func TestChunkReaderErrors(t *testing.T) {
+	var manyStreams [][]byte
+	for csid := uint32(2); csid < 2+maxChunkStreams+1; csid++ {
+		manyStreams = append(manyStreams, testChunk0(csid, 0, 0, msgAudio, 1, ""))
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{
+			name:    "chunk stream starting without a full header",
+			data:    testChunk1(4, 0, 1, msgAudio, "a"),
+			wantErr: "starts without a full header",
+		},
+		{
+			name:    "message over the length limit",
+			data:    testChunk0(4, 0, maxMessageLength+1, msgVideo, 1, ""),
+			wantErr: "exceeds the",
+		},
+		{
+			name:    "message header over the length limit",
+			data:    testChunks(testChunk0(4, 0, 1, msgVideo, 1, "a"), testChunk1(4, 0, 0xffffff, msgVideo, "")),
+			wantErr: "exceeds the",
+		},
+		{
+			name:    "too many chunk streams",
+			data:    testChunks(manyStreams...),
+			wantErr: "too many chunk streams",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readTestMessages(tt.data, defaultChunkSize)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("readMessage() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChunkReaderTruncated(t *testing.T) {
+	_, extended := testTimestamp(0x1000000)
+	data := testChunks(
+		testChunk0(400, 0x1000000, 6, msgVideo, 1, "abcd"),
+		testChunk3(400, extended, "ef"),
+	)
+
+	// Every cut falls inside the message, in a header field, in the payload or
+	// between its chunks
+	for n := 1; n < len(data); n++ {
+		if _, err := readTestMessages(data[:n], 4); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("readMessage() of %d bytes error = %v, want unexpected EOF", n, err)
+		}
+	}
+}
+
+func TestChunkReaderGrowsPayload(t *testing.T) {
+	data := testChunk0(4, 0, maxMessageLength, msgVideo, 1, strings.Repeat("a", defaultChunkSize))
+	reader := newChunkReader(bufio.NewReader(bytes.NewReader(data)))
+
+	msg, err := reader.readChunk()
+	if err != nil {
+		t.Fatalf("readChunk() error = %v", err)
+	}
+	if msg != nil {
+		t.Fatal("readChunk() completed a message after its first chunk")
+	}
+
+	// Only the bytes received are buffered, not the length in the header
+	if size := cap(reader.streams[4].payload); size > payloadStep {
+		t.Errorf("payload capacity = %d after %d bytes, want at most %d", size, defaultChunkSize, payloadStep)
+	}
+}
+
+func TestChunkReaderAbort(t *testing.T) {
+	reader := newChunkReader(bufio.NewReader(bytes.NewReader(testChunks(
+		testChunk0(4, 0, 6, msgVideo, 1, "abcd"),
+		testChunk0(4, 0, 2, msgVideo, 1, "ef"),
+	))))
+	reader.chunkSize = 4
+
+	if _, err := reader.readChunk(); err != nil {
+		t.Fatalf("readChunk() error = %v", err)
+	}
+	reader.abort(4)
+
+	msg, err := reader.readMessage()
+	if err != nil {
+		t.Fatalf("readMessage() error = %v", err)
+	}
+	checkMessages(t, []*message{msg}, []*message{{typeID: msgVideo, streamID: 1, payload: []byte("ef")}})
+}
+
+func TestSetChunkSize(t *testing.T) {
+	long := strings.Repeat("x", 300)
+
+	tests := []struct {
+		name     string
+		size     []byte
+		wantSize uint32
+		wantErr  bool
+	}{
+		{
+			name:     "larger chunks",
+			size:     binary.BigEndian.AppendUint32(nil, 4096),
+			wantSize: 4096,
+		},
+		{
+			name:     "reserved bit is ignored",
+			size:     binary.BigEndian.AppendUint32(nil, 0x80001000),
+			wantSize: 4096,
+		},
+		{
+			name:    "zero",
+			size:    binary.BigEndian.AppendUint32(nil, 0),
+			wantErr: true,
+		},
+		{
+			name:    "over the message length field",
+			size:    binary.BigEndian.AppendUint32(nil, 0x1000000),
+			wantErr: true,
+		},
+		{
+			name:    "truncated",
+			size:    []byte{0, 0, 16},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testChunks(
+				testChunk0(csidProtocol, 0, uint32(len(tt.size)), msgSetChunkSize, 0, string(tt.size)),
+				testChunk0(4, 0, uint32(len(long)), msgVideo, 1, long),
+			)
+			c := &conn{reader: newChunkReader(bufio.NewReader(bytes.NewReader(data)))}
+
+			msg, err := c.reader.readMessage()
+			if err != nil {
+				t.Fatalf("readMessage() error = %v", err)
+			}
+			err = c.handle(msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("handle() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if c.reader.chunkSize != tt.wantSize {
+				t.Fatalf("chunk size = %d, want %d", c.reader.chunkSize, tt.wantSize)
+			}
+
+			// The message now fits in a single chunk
+			msg, err = c.reader.readMessage()
+			if err != nil {
+				t.Fatalf("readMessage() error = %v", err)
+			}
+			checkMessages(t, []*message{msg}, []*message{{typeID: msgVideo, streamID: 1, payload: []byte(long)}})
+		})
+	}
+}
+
+func TestChunkWriterRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		chunkSize uint32
+		msg       *message
+	}{
+		{
+			name:      "single chunk",
+			chunkSize: defaultChunkSize,
+			msg:       &message{typeID: msgCommandAMF0, timestamp: 1000, payload: []byte("hello")},
+		},
+		{
+			name:      "several chunks",
+			chunkSize: defaultChunkSize,
+			msg:       &message{typeID: msgVideo, streamID: 1, timestamp: 40, payload: bytes.Repeat([]byte("abc"), 200)},
+		},
+		{
+			name:      "extended timestamp",
+			chunkSize: 16,
+			msg:       &message{typeID: msgAudio, streamID: 1, timestamp: 0x1234567, payload: bytes.Repeat([]byte("z"), 50)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			writer := newChunkWriter(bufio.NewWriter(&buf))
+			writer.chunkSize = tt.chunkSize
+			if err := writer.writeMessage(csidCommand, tt.msg); err != nil {
+				t.Fatalf("writeMessage() error = %v", err)
+			}
+
+			got, err := readTestMessages(buf.Bytes(), tt.chunkSize)
+			if err != nil {
+				t.Fatalf("readMessage() error = %v", err)
+			}
+			checkMessages(t, got, []*message{tt.msg})
+		})
+	}
+}
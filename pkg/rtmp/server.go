@@ -0,0 +1,397 @@
+// Package rtmp implements the publishing side of an RTMP server, enough to
+// accept streams from encoders such as OBS or FFmpeg and pass them on as FLV.
+package rtmp
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Handler receives the streams published to a Server
+type Handler interface {
+	// Publish is called when a client starts publishing under a stream key. The
+	// stream is written to the returned writer as FLV, and the writer is closed
+	// when the client stops. An error rejects the client.
+	Publish(streamKey string) (io.WriteCloser, error)
+}
+
+// Connection settings
+const (
+	rtmpVersion      = 3
+	handshakeSize    = 1536
+	handshakeTimeout = 10 * time.Second
+	idleTimeout      = 30 * time.Second
+	outChunkSize     = 4096
+	windowAckSize    = 2500000
+	publishStreamID  = 1
+)
+
+// Chunk streams used for outgoing messages
+const (
+	csidProtocol = 2
+	csidCommand  = 3
+	csidStatus   = 5
+)
+
+// flvHeader starts an FLV file with audio and video, followed by the first
+// previous tag size
+var flvHeader = []byte{'F', 'L', 'V', 0x01, 0x05, 0x00, 0x00, 0x00, 0x09, 0x00, 0x00, 0x00, 0x00}
+
+// setDataFrame prefixes metadata sent by publishers, which FLV files omit
+var setDataFrame = encodeAMF("@setDataFrame")
+
+// errUnpublished ends a connection whose client stopped publishing
+var errUnpublished = errors.New("stream unpublished")
+
+// Server accepts RTMP publishers and passes their streams to a Handler
+type Server struct {
+	addr     string
+	handler  Handler
+	mu       sync.Mutex
+	listener net.Listener
+}
+
+// NewServer creates an RTMP server listening on addr, e.g. ":1935"
+func NewServer(addr string, handler Handler) *Server {
+	return &Server{
+		addr:    addr,
+		handler: handler,
+	}
+}
+
+// ListenAndServe accepts connections until the server is closed
+func (s *Server) ListenAndServe() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for RTMP: %w", err)
+	}
+
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	for {
+		netConn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("failed to accept RTMP connection: %w", err)
+		}
+
+		go func() {
+			defer netConn.Close()
+
+			if err := newConn(netConn, s.handler).run(); err != nil {
+				fmt.Printf("RTMP connection from %s failed: %v\n", netConn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// Close stops accepting connections. Streams being published continue.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// conn is a connection from a publishing client
+type conn struct {
+	netConn net.Conn
+	handler Handler
+	counter *countingReader
+	br      *bufio.Reader
+	bw      *bufio.Writer
+	reader  *chunkReader
+	writer  *chunkWriter
+	stream  io.WriteCloser // FLV output once publishing
+	ackSize uint32         // Window after which received bytes are acknowledged
+	ackedAt uint64
+	started time.Time
+}
+
+// countingReader counts the bytes read from a connection for acknowledgements
+type countingReader struct {
+	r     io.Reader
+	count uint64
+}
+
+// Read implements io.Reader
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.count += uint64(n)
+	return n, err
+}
+
+// newConn wraps a network connection
+func newConn(netConn net.Conn, handler Handler) *conn {
+	counter := &countingReader{r: netConn}
+	br := bufio.NewReaderSize(counter, 64*1024)
+	bw := bufio.NewWriter(netConn)
+
+	return &conn{
+		netConn: netConn,
+		handler: handler,
+		counter: counter,
+		br:      br,
+		bw:      bw,
+		reader:  newChunkReader(br),
+		writer:  newChunkWriter(bw),
+		ackSize: windowAckSize,
+		started: time.Now(),
+	}
+}
+
+// run serves the connection until the client disconnects or stops publishing
+func (c *conn) run() error {
+	defer func() {
+		if c.stream != nil {
+			_ = c.stream.Close()
+		}
+	}()
+
+	_ = c.netConn.SetDeadline(time.Now().Add(handshakeTimeout))
+	if err := c.handshake(); err != nil {
+		return fmt.Errorf("handshake failed: %w", err)
+	}
+	_ = c.netConn.SetWriteDeadline(time.Time{})
+
+	for {
+		_ = c.netConn.SetReadDeadline(time.Now().Add(idleTimeout))
+
+		msg, err := c.reader.readMessage()
+		if err != nil {
+			// Encoders often just hang up when they stop
+			if c.stream != nil && errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		if err := c.handle(msg); err != nil {
+			if errors.Is(err, errUnpublished) {
+				return nil
+			}
+			return err
+		}
+
+		if err := c.acknowledge(); err != nil {
+			return err
+		}
+	}
+}
+
+// handshake performs the simple RTMP handshake, echoing the client's C1 as S2
+func (c *conn) handshake() error {
+	c0c1 := make([]byte, 1+handshakeSize)
+	if _, err := io.ReadFull(c.br, c0c1); err != nil {
+		return err
+	}
+	if c0c1[0] != rtmpVersion {
+		return fmt.Errorf("unsupported RTMP version %d", c0c1[0])
+	}
+
+	// S1 holds our time, four zero bytes and random data
+	s0s1s2 := make([]byte, 1+2*handshakeSize)
+	s0s1s2[0] = rtmpVersion
+	if _, err := rand.Read(s0s1s2[9 : 1+handshakeSize]); err != nil {
+		return err
+	}
+	copy(s0s1s2[1+handshakeSize:], c0c1[1:])
+	if _, err := c.bw.Write(s0s1s2); err != nil {
+		return err
+	}
+	if err := c.bw.Flush(); err != nil {
+		return err
+	}
+
+	c2 := make([]byte, handshakeSize)
+	_, err := io.ReadFull(c.br, c2)
+	return err
+}
+
+// handle processes a received message
+func (c *conn) handle(msg *message) error {
+	switch msg.typeID {
+	case msgSetChunkSize:
+		if len(msg.payload) < 4 {
+			return errors.New("invalid set chunk size message")
+		}
+		size := binary.BigEndian.Uint32(msg.payload) & 0x7fffffff
+		if size == 0 || size > 0xffffff {
+			return fmt.Errorf("invalid chunk size %d", size)
+		}
+		c.reader.chunkSize = size
+	case msgAbort:
+		if len(msg.payload) >= 4 {
+			c.reader.abort(binary.BigEndian.Uint32(msg.payload))
+		}
+	case msgWindowAckSize:
+		if len(msg.payload) >= 4 {
+			c.ackSize = binary.BigEndian.Uint32(msg.payload)
+		}
+	case msgCommandAMF3:
+		// AMF3 commands are AMF0 values behind a format byte
+		if len(msg.payload) > 0 {
+			return c.command(msg.payload[1:])
+		}
+	case msgCommandAMF0:
+		return c.command(msg.payload)
+	case msgDataAMF0:
+		if c.stream != nil {
+			return c.writeTag(msgDataAMF0, msg.timestamp, bytes.TrimPrefix(msg.payload, setDataFrame))
+		}
+	case msgAudio, msgVideo:
+		if c.stream != nil {
+			return c.writeTag(msg.typeID, msg.timestamp, msg.payload)
+		}
+	}
+
+	return nil
+}
+
+// command handles the NetConnection and NetStream commands of a publisher
+func (c *conn) command(payload []byte) error {
+	values, err := decodeAMF(payload)
+	if err != nil {
+		return fmt.Errorf("invalid command: %w", err)
+	}
+	if len(values) < 2 {
+		return errors.New("invalid command: missing name or transaction ID")
+	}
+	name, _ := values[0].(string)
+	transactionID, _ := values[1].(float64)
+
+	switch name {
+	case "connect":
+		if err := c.writeControl(msgWindowAckSize, windowAckSize); err != nil {
+			return err
+		}
+		if err := c.writeMessage(csidProtocol, msgSetPeerBandwidth, 0, append(binary.BigEndian.AppendUint32(nil, windowAckSize), 2)); err != nil {
+			return err
+		}
+		if err := c.writeControl(msgSetChunkSize, outChunkSize); err != nil {
+			return err
+		}
+		c.writer.chunkSize = outChunkSize
+
+		return c.writeMessage(csidCommand, msgCommandAMF0, 0, encodeAMF(
+			"_result",
+			transactionID,
+			amfObjectValue{"fmsVer": "FMS/3,0,1,123", "capabilities": 31},
+			amfObjectValue{
+				"level":          "status",
+				"code":           "NetConnection.Connect.Success",
+				"description":    "Connection succeeded.",
+				"objectEncoding": 0,
+			},
+		))
+	case "createStream":
+		return c.writeMessage(csidCommand, msgCommandAMF0, 0, encodeAMF("_result", transactionID, nil, publishStreamID))
+	case "releaseStream", "FCPublish":
+		if transactionID != 0 {
+			return c.writeMessage(csidCommand, msgCommandAMF0, 0, encodeAMF("_result", transactionID, nil))
+		}
+	case "publish":
+		return c.publish(values)
+	case "FCUnpublish", "deleteStream", "closeStream":
+		if c.stream != nil {
+			return errUnpublished
+		}
+	}
+
+	return nil
+}
+
+// publish starts passing the client's stream to the handler
+func (c *conn) publish(values []interface{}) error {
+	if c.stream != nil {
+		return errors.New("client is already publishing")
+	}
+	if len(values) < 4 {
+		return errors.New("invalid publish command: missing stream key")
+	}
+
+	// Encoders may append query parameters to the stream key
+	streamKey, _ := values[3].(string)
+	if i := strings.IndexByte(streamKey, '?'); i >= 0 {
+		streamKey = streamKey[:i]
+	}
+
+	stream, err := c.handler.Publish(streamKey)
+	if err != nil {
+		_ = c.onStatus("error", "NetStream.Publish.BadName", err.Error())
+		return fmt.Errorf("publish rejected: %w", err)
+	}
+	c.stream = stream
+
+	if _, err := c.stream.Write(flvHeader); err != nil {
+		return fmt.Errorf("failed to write FLV header: %w", err)
+	}
+
+	return c.onStatus("status", "NetStream.Publish.Start", "Publishing "+streamKey)
+}
+
+// onStatus sends a NetStream status event
+func (c *conn) onStatus(level, code, description string) error {
+	return c.writeMessage(csidStatus, msgCommandAMF0, publishStreamID, encodeAMF(
+		"onStatus",
+		0,
+		nil,
+		amfObjectValue{"level": level, "code": code, "description": description},
+	))
+}
+
+// writeTag writes an audio, video or script message to the stream as an FLV tag
+func (c *conn) writeTag(tagType uint8, timestamp uint32, data []byte) error {
+	tag := make([]byte, 11, 11+len(data)+4)
+	tag[0] = tagType
+	putUint24(tag[1:4], uint32(len(data)))
+	putUint24(tag[4:7], timestamp&0xffffff)
+	tag[7] = byte(timestamp >> 24)
+	tag = append(tag, data...)
+	tag = binary.BigEndian.AppendUint32(tag, uint32(11+len(data)))
+
+	if _, err := c.stream.Write(tag); err != nil {
+		return fmt.Errorf("failed to write FLV tag: %w", err)
+	}
+	return nil
+}
+
+// acknowledge reports the bytes received once the client's window is used up
+func (c *conn) acknowledge() error {
+	if c.ackSize == 0 || c.counter.count-c.ackedAt < uint64(c.ackSize) {
+		return nil
+	}
+	c.ackedAt = c.counter.count
+	return c.writeControl(msgAcknowledgement, uint32(c.counter.count))
+}
+
+// writeControl sends a protocol control message with a 32-bit value
+func (c *conn) writeControl(typeID uint8, value uint32) error {
+	return c.writeMessage(csidProtocol, typeID, 0, binary.BigEndian.AppendUint32(nil, value))
+}
+
+// writeMessage sends a message, timestamped with the connection's age
+func (c *conn) writeMessage(csid uint32, typeID uint8, streamID uint32, payload []byte) error {
+	return c.writer.writeMessage(csid, &message{
+		typeID:    typeID,
+		streamID:  streamID,
+		timestamp: uint32(time.Since(c.started).Milliseconds()),
+		payload:   payload,
+	})
+}
@@ -0,0 +1,137 @@
+package subtitle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Format defines a subtitle file format
+type Format string
+
+const (
+	FormatSRT    Format = "srt"
+	FormatWebVTT Format = "vtt"
+)
+
+// srtTiming matches an SRT cue timing line, e.g. "00:00:01,000 --> 00:00:04,250"
+var srtTiming = regexp.MustCompile(`^(\d{1,2}:\d{2}:\d{2})[,.](\d{1,3})\s*-->\s*(\d{1,2}:\d{2}:\d{2})[,.](\d{1,3})`)
+
+// ErrUnknownFormat is returned when subtitle data is neither SRT nor WebVTT
+var ErrUnknownFormat = errors.New("unsupported subtitle format, expected SRT or WebVTT")
+
+// DetectFormat determines whether subtitle data is WebVTT or SRT
+func DetectFormat(data []byte) (Format, error) {
+	text := normalize(data)
+	if strings.HasPrefix(text, "WEBVTT") {
+		return FormatWebVTT, nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if srtTiming.MatchString(strings.TrimSpace(line)) {
+			return FormatSRT, nil
+		}
+	}
+
+	return "", ErrUnknownFormat
+}
+
+// ToWebVTT converts SRT or WebVTT subtitle data into WebVTT
+func ToWebVTT(data []byte) ([]byte, error) {
+	format, err := DetectFormat(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == FormatWebVTT {
+		return []byte(normalize(data)), nil
+	}
+
+	return convertSRT(normalize(data))
+}
+
+// convertSRT converts normalized SRT text into WebVTT
+func convertSRT(text string) ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteString("WEBVTT\n")
+
+	cues := 0
+	for _, block := range strings.Split(text, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+
+		// Find the timing line, skipping the optional numeric cue identifier
+		timingLine := -1
+		for i := 0; i < len(lines) && i < 2; i++ {
+			if srtTiming.MatchString(strings.TrimSpace(lines[i])) {
+				timingLine = i
+				break
+			}
+		}
+		if timingLine == -1 {
+			continue
+		}
+
+		m := srtTiming.FindStringSubmatch(strings.TrimSpace(lines[timingLine]))
+		out.WriteString("\n")
+		out.WriteString(fmt.Sprintf("%s --> %s\n", vttTimestamp(m[1], m[2]), vttTimestamp(m[3], m[4])))
+		for _, line := range lines[timingLine+1:] {
+			out.WriteString(line + "\n")
+		}
+		cues++
+	}
+
+	if cues == 0 {
+		return nil, errors.New("no subtitle cues found")
+	}
+
+	return out.Bytes(), nil
+}
+
+// vttTimestamp formats SRT timestamp parts as a WebVTT timestamp
+func vttTimestamp(clock, millis string) string {
+	if len(strings.SplitN(clock, ":", 2)[0]) == 1 {
+		clock = "0" + clock
+	}
+	for len(millis) < 3 {
+		millis += "0"
+	}
+	return clock + "." + millis
+}
+
+// normalize strips the byte order mark and converts line endings to "\n"
+func normalize(data []byte) string {
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return strings.TrimLeft(text, "\n")
+}
+
+// Cue is a timed WebVTT cue, e.g. a chapter title
+type Cue struct {
+	Start float64 // Seconds
+	End   float64 // Seconds
+	Text  string
+}
+
+// WriteWebVTT renders cues as a WebVTT file
+func WriteWebVTT(cues []Cue) []byte {
+	var out bytes.Buffer
+	out.WriteString("WEBVTT\n")
+
+	for i, cue := range cues {
+		// Line breaks would end the cue early
+		text := strings.Join(strings.Fields(cue.Text), " ")
+		out.WriteString(fmt.Sprintf("\n%d\n%s --> %s\n%s\n", i+1, formatTimestamp(cue.Start), formatTimestamp(cue.End), text))
+	}
+
+	return out.Bytes()
+}
+
+// formatTimestamp formats seconds as a WebVTT timestamp, e.g. "01:02:03.450"
+func formatTimestamp(seconds float64) string {
+	millis := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", millis/3600000, millis/60000%60, millis/1000%60, millis%1000)
+}
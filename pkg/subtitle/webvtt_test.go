@@ -0,0 +1,152 @@
+package subtitle
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    Format
+		wantErr error
+	}{
+		{
+			name: "webvtt",
+			data: "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHello\n",
+			want: FormatWebVTT,
+		},
+		{
+			name: "webvtt with bom",
+			data: "\ufeffWEBVTT\n",
+			want: FormatWebVTT,
+		},
+		{
+			name: "srt",
+			data: "1\n00:00:01,000 --> 00:00:02,000\nHello\n",
+			want: FormatSRT,
+		},
+		{
+			name: "srt with bom and crlf",
+			data: "\ufeff1\r\n00:00:01,000 --> 00:00:02,000\r\nHello\r\n",
+			want: FormatSRT,
+		},
+		{
+			name:    "plain text",
+			data:    "just some text\n",
+			wantErr: ErrUnknownFormat,
+		},
+		{
+			name:    "empty",
+			data:    "",
+			wantErr: ErrUnknownFormat,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DetectFormat([]byte(tt.data))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DetectFormat() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("DetectFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToWebVTT(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "single cue",
+			data: "1\n00:00:01,000 --> 00:00:04,250\nHello\n",
+			want: "WEBVTT\n\n00:00:01.000 --> 00:00:04.250\nHello\n",
+		},
+		{
+			name: "bom",
+			data: "\ufeff1\n00:00:01,000 --> 00:00:02,000\nHello\n",
+			want: "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHello\n",
+		},
+		{
+			name: "crlf line endings",
+			data: "1\r\n00:00:01,000 --> 00:00:02,000\r\nHello\r\n\r\n2\r\n00:00:03,000 --> 00:00:04,000\r\nWorld\r\n",
+			want: "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHello\n\n00:00:03.000 --> 00:00:04.000\nWorld\n",
+		},
+		{
+			name: "bare cr line endings",
+			data: "1\r00:00:01,000 --> 00:00:02,000\rHello\r",
+			want: "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHello\n",
+		},
+		{
+			name: "multi-line cue",
+			data: "1\n00:00:01,000 --> 00:00:02,000\nFirst line\nSecond line\n<i>Third</i>\n",
+			want: "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nFirst line\nSecond line\n<i>Third</i>\n",
+		},
+		{
+			name: "cue without identifier",
+			data: "00:00:01,000 --> 00:00:02,000\nHello\n",
+			want: "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHello\n",
+		},
+		{
+			name: "single digit hours and short milliseconds",
+			data: "1\n1:02:03,5 --> 1:02:04,25\nHello\n",
+			want: "WEBVTT\n\n01:02:03.500 --> 01:02:04.250\nHello\n",
+		},
+		{
+			name: "dot timestamps and cue settings",
+			data: "1\n00:00:01.000 --> 00:00:02.000 X1:100 X2:200\nHello\n",
+			want: "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHello\n",
+		},
+		{
+			name: "leading and trailing blank lines",
+			data: "\n\n1\n00:00:01,000 --> 00:00:02,000\nHello\n\n\n\n",
+			want: "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHello\n",
+		},
+		{
+			name: "malformed block is skipped",
+			data: "1\nnot a timing line\nHello\n\n2\n00:00:03,000 --> 00:00:04,000\nWorld\n",
+			want: "WEBVTT\n\n00:00:03.000 --> 00:00:04.000\nWorld\n",
+		},
+		{
+			name: "webvtt is passed through normalized",
+			data: "\ufeffWEBVTT\r\n\r\n00:00:01.000 --> 00:00:02.000\r\nHello\r\n",
+			want: "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHello\n",
+		},
+		{
+			name:    "unknown format",
+			data:    "hello\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToWebVTT([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ToWebVTT() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("ToWebVTT() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteWebVTT(t *testing.T) {
+	got := WriteWebVTT([]Cue{
+		{Start: 0, End: 61.5, Text: "Intro"},
+		{Start: 3723.45, End: 3725, Text: "Line\nbreaks  collapse"},
+	})
+
+	want := "WEBVTT\n\n1\n00:00:00.000 --> 00:01:01.500\nIntro\n\n2\n01:02:03.450 --> 01:02:05.000\nLine breaks collapse\n"
+	if string(got) != want {
+		t.Errorf("WriteWebVTT() =\n%q\nwant\n%q", got, want)
+	}
+}